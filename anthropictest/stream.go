@@ -0,0 +1,182 @@
+// Package anthropictest provides utilities for testing code which uses the anthropic package, e.g. a builder for
+// realistic server-sent event streams to serve from a fake server or http.RoundTripper.
+package anthropictest
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// Stream builds the server-sent events of a streamed message: message_start, then the start, deltas, and stop of each
+// content block, then message_delta and message_stop. The zero value is not usable; use NewStream.
+type Stream struct {
+	id           string
+	model        string
+	blocks       []*block
+	stopReason   string
+	inputTokens  int
+	outputTokens int
+	multiline    bool
+	crlf         bool
+}
+
+// block is a content block of a Stream.
+type block struct {
+	start  map[string]any
+	deltas []map[string]any
+}
+
+// NewStream returns a Stream for a message with the ID |id| generated by |model| (e.g. "claude-3-5-sonnet-20241022").
+// By default the message stops with "end_turn" and reports 10 input and 1 output tokens.
+func NewStream(id, model string) *Stream {
+	return &Stream{id: id, model: model, stopReason: "end_turn", inputTokens: 10, outputTokens: 1}
+}
+
+// Text adds a text block whose text is sent as |deltas|, one text_delta event each.
+func (s *Stream) Text(deltas ...string) *Stream {
+	var b = &block{start: map[string]any{"type": "text", "text": ""}}
+	for _, d := range deltas {
+		b.deltas = append(b.deltas, map[string]any{"type": "text_delta", "text": d})
+	}
+	s.blocks = append(s.blocks, b)
+
+	return s
+}
+
+// ToolUse adds a tool_use block for the tool |name| whose input is sent as the fragments |partialJSON|, one
+// input_json_delta event each. The fragments should concatenate to a JSON object.
+func (s *Stream) ToolUse(id, name string, partialJSON ...string) *Stream {
+	var b = &block{start: map[string]any{"type": "tool_use", "id": id, "name": name, "input": map[string]any{}}}
+	for _, p := range partialJSON {
+		b.deltas = append(b.deltas, map[string]any{"type": "input_json_delta", "partial_json": p})
+	}
+	s.blocks = append(s.blocks, b)
+
+	return s
+}
+
+// Thinking adds a thinking block whose reasoning is sent as |deltas|, one thinking_delta event each, followed by a
+// signature_delta with |signature|.
+func (s *Stream) Thinking(signature string, deltas ...string) *Stream {
+	var b = &block{start: map[string]any{"type": "thinking", "thinking": ""}}
+	for _, d := range deltas {
+		b.deltas = append(b.deltas, map[string]any{"type": "thinking_delta", "thinking": d})
+	}
+	b.deltas = append(b.deltas, map[string]any{"type": "signature_delta", "signature": signature})
+	s.blocks = append(s.blocks, b)
+
+	return s
+}
+
+// StopReason sets the stop reason sent in message_delta.
+func (s *Stream) StopReason(reason string) *Stream {
+	s.stopReason = reason
+	return s
+}
+
+// Usage sets the input tokens sent in message_start and the output tokens sent in message_delta.
+func (s *Stream) Usage(inputTokens, outputTokens int) *Stream {
+	s.inputTokens, s.outputTokens = inputTokens, outputTokens
+	return s
+}
+
+// MultilineData splits the data of each event across several "data:" lines, which a conforming parser must join with
+// newlines.
+func (s *Stream) MultilineData() *Stream {
+	s.multiline = true
+	return s
+}
+
+// CRLF terminates lines with "\r\n" rather than "\n".
+func (s *Stream) CRLF() *Stream {
+	s.crlf = true
+	return s
+}
+
+// Bytes returns the stream's events.
+func (s *Stream) Bytes() []byte {
+	var buf bytes.Buffer
+
+	s.event(&buf, "message_start", map[string]any{
+		"type": "message_start",
+		"message": map[string]any{
+			"id":            s.id,
+			"type":          "message",
+			"role":          "assistant",
+			"content":       []any{},
+			"model":         s.model,
+			"stop_reason":   nil,
+			"stop_sequence": nil,
+			"usage":         map[string]any{"input_tokens": s.inputTokens, "output_tokens": 1},
+		},
+	})
+	for i, b := range s.blocks {
+		s.event(&buf, "content_block_start", map[string]any{"type": "content_block_start", "index": i, "content_block": b.start})
+		if i == 0 {
+			s.event(&buf, "ping", map[string]any{"type": "ping"})
+		}
+		for _, d := range b.deltas {
+			s.event(&buf, "content_block_delta", map[string]any{"type": "content_block_delta", "index": i, "delta": d})
+		}
+		s.event(&buf, "content_block_stop", map[string]any{"type": "content_block_stop", "index": i})
+	}
+	s.event(&buf, "message_delta", map[string]any{
+		"type":  "message_delta",
+		"delta": map[string]any{"stop_reason": s.stopReason, "stop_sequence": nil},
+		"usage": map[string]any{"output_tokens": s.outputTokens},
+	})
+	s.event(&buf, "message_stop", map[string]any{"type": "message_stop"})
+
+	return buf.Bytes()
+}
+
+// String returns the stream's events.
+func (s *Stream) String() string {
+	return string(s.Bytes())
+}
+
+// Reader returns a reader of the stream's events which returns at most |n| bytes per read, so events (and lines) are
+// split across reads, as they may be by the network.
+func (s *Stream) Reader(n int) io.Reader {
+	return &chunkReader{r: bytes.NewReader(s.Bytes()), n: n}
+}
+
+// event writes the event |typ| with the JSON encoding of |data| to |buf|.
+func (s *Stream) event(buf *bytes.Buffer, typ string, data any) {
+	var eol = "\n"
+	if s.crlf {
+		eol = "\r\n"
+	}
+
+	var b []byte
+	if s.multiline {
+		// Indented JSON only has newlines between tokens, so it's still valid once the lines are joined.
+		b, _ = json.MarshalIndent(data, "", " ")
+	} else {
+		b, _ = json.Marshal(data)
+	}
+
+	buf.WriteString("event: " + typ + eol)
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		buf.WriteString("data: ")
+		buf.Write(line)
+		buf.WriteString(eol)
+	}
+	buf.WriteString(eol)
+}
+
+// chunkReader is an io.Reader which returns at most |n| bytes per read.
+type chunkReader struct {
+	r io.Reader
+	n int
+}
+
+// Read implements the io.Reader interface.
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.n > 0 && len(p) > r.n {
+		p = p[:r.n]
+	}
+
+	return r.r.Read(p)
+}
@@ -0,0 +1,284 @@
+package anthropic
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+const messageBatchesEndpoint = "v1/messages/batches"
+
+var (
+	// ErrBatchResultsUnavailable is returned when the results of a message batch are requested before it has ended.
+	ErrBatchResultsUnavailable = errors.New("message batch results are not available until processing has ended")
+	// ErrBatchResultNotFound is returned by GetMessageBatchResult when the batch has no result with the custom_id.
+	ErrBatchResultNotFound = errors.New("message batch result not found")
+)
+
+// errStopScan is returned by a scanMessageBatchResults callback to stop scanning without an error.
+var errStopScan = errors.New("stop scan")
+
+// MessageBatch represents a Message Batch.
+// https://docs.anthropic.com/en/api/retrieving-message-batches
+type MessageBatch struct {
+	// ID is the unique identifier of the batch.
+	ID string `json:"id"`
+	// Type is the object type. For message batches, this is always "message_batch".
+	Type string `json:"type"`
+	// ProcessingStatus is the processing status of the batch: "in_progress", "canceling", or "ended".
+	ProcessingStatus string `json:"processing_status"`
+	// RequestCounts tallies the requests in the batch by status.
+	RequestCounts *BatchRequestCounts `json:"request_counts"`
+	// CreatedAt is when the batch was created.
+	CreatedAt time.Time `json:"created_at"`
+	// EndedAt is when processing of the batch ended, if it has.
+	EndedAt *time.Time `json:"ended_at"`
+	// ExpiresAt is when the batch will expire and end processing, if it hasn't already.
+	ExpiresAt time.Time `json:"expires_at"`
+	// ResultsURL is the URL of the batch's results file. It is only set once processing has ended.
+	ResultsURL *string `json:"results_url"`
+}
+
+// BatchRequestCounts tallies the requests in a message batch by status.
+type BatchRequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+const (
+	// BatchResultSucceeded indicates that a batched request succeeded.
+	BatchResultSucceeded = "succeeded"
+	// BatchResultErrored indicates that a batched request failed.
+	BatchResultErrored = "errored"
+	// BatchResultCanceled indicates that a batched request was canceled before it was processed.
+	BatchResultCanceled = "canceled"
+	// BatchResultExpired indicates that a batched request expired before it was processed.
+	BatchResultExpired = "expired"
+)
+
+// BatchResult is the result of a single request in a message batch.
+type BatchResult struct {
+	// CustomID is the custom_id the request was submitted with.
+	CustomID string `json:"custom_id"`
+	// Result is the outcome of the request.
+	Result struct {
+		// Type is one of the BatchResult* constants.
+		Type string `json:"type"`
+		// Message is the response, if Type is BatchResultSucceeded.
+		Message *v3.Response `json:"message,omitempty"`
+		// Error is the error, if Type is BatchResultErrored.
+		Error *ResponseError `json:"error,omitempty"`
+	} `json:"result"`
+}
+
+// BatchError is a request in a message batch which didn't succeed.
+type BatchError struct {
+	// CustomID is the custom_id the request was submitted with.
+	CustomID string
+	// Type is the result type: BatchResultErrored, BatchResultCanceled, or BatchResultExpired.
+	Type string
+	// Error is the error returned by the API. It is only set if Type is BatchResultErrored.
+	Error *Error
+}
+
+// BatchRequest is a request in a message batch.
+type BatchRequest struct {
+	// CustomID identifies the request's result. It must be unique within the batch.
+	CustomID string `json:"custom_id"`
+	// Params is the request, serialized exactly as NewMessageRequest serializes it.
+	Params *v3.Request[v3.Message] `json:"params"`
+}
+
+// CreateMessageBatch creates a message batch which processes |reqs| asynchronously.
+// https://docs.anthropic.com/en/api/creating-message-batches
+func (c *Client) CreateMessageBatch(ctx context.Context, reqs []*BatchRequest) (*MessageBatch, error) {
+	// Betas can't be enabled per request in a batch, so the batch enables those of all of its requests.
+	var betas []string
+	for _, r := range reqs {
+		if r.Params != nil {
+			betas = append(betas, r.Params.Betas...)
+		}
+	}
+
+	var b, _, err = c.post(ctx, messageBatchesEndpoint, &struct {
+		Requests []*BatchRequest `json:"requests"`
+	}{Requests: reqs}, betaHeader(betas))
+	if err != nil {
+		return nil, err
+	}
+
+	var batch = &MessageBatch{}
+	if err = json.Unmarshal(b, batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// GetMessageBatch retrieves the message batch |batchID|.
+func (c *Client) GetMessageBatch(ctx context.Context, batchID string) (*MessageBatch, error) {
+	var b, err = c.get(ctx, c.endpoint(messageBatchesEndpoint+"/"+url.PathEscape(batchID)))
+	if err != nil {
+		return nil, err
+	}
+
+	var batch = &MessageBatch{}
+	if err = json.Unmarshal(b, batch); err != nil {
+		return nil, err
+	}
+
+	return batch, nil
+}
+
+// GetMessageBatchErrors returns the requests in the ended message batch |batchID| which didn't succeed, i.e. those
+// which errored, were canceled, or expired, in the order they appear in the results. The results are scanned as they
+// are downloaded, so successful responses are never held in memory. ErrBatchResultsUnavailable is returned if the
+// batch hasn't ended.
+func (c *Client) GetMessageBatchErrors(ctx context.Context, batchID string) ([]*BatchError, error) {
+	var out []*BatchError
+	var err = c.scanMessageBatchResults(ctx, batchID, func(r *BatchResult) error {
+		if r.Result.Type == BatchResultSucceeded {
+			return nil
+		}
+
+		var be = &BatchError{CustomID: r.CustomID, Type: r.Result.Type}
+		if r.Result.Error != nil {
+			be.Error = &r.Result.Error.Err
+		}
+		out = append(out, be)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// GetMessageBatchResult returns the result of the request |customID| in the ended message batch |batchID|. The API
+// can't fetch a single result, so the results are scanned as they're downloaded, stopping at the match; results after
+// it are never downloaded, and results before it are never held in memory. ErrBatchResultNotFound is returned if the
+// batch has no such result, and ErrBatchResultsUnavailable if the batch hasn't ended.
+func (c *Client) GetMessageBatchResult(ctx context.Context, batchID, customID string) (*BatchResult, error) {
+	var found *BatchResult
+	var err = c.scanMessageBatchResults(ctx, batchID, func(r *BatchResult) error {
+		if r.CustomID != customID {
+			return nil
+		}
+		found = r
+
+		return errStopScan
+	})
+	if err != nil {
+		return nil, err
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: %s", ErrBatchResultNotFound, customID)
+	}
+
+	return found, nil
+}
+
+// scanMessageBatchResults calls |fn| with each result of the message batch |batchID| as it's read from the results
+// file. Scanning stops at the first error returned by |fn|, which is returned unless it's errStopScan.
+func (c *Client) scanMessageBatchResults(ctx context.Context, batchID string, fn func(*BatchResult) error) error {
+	var batch, err = c.GetMessageBatch(ctx, batchID)
+	if err != nil {
+		return err
+	}
+	if batch.ResultsURL == nil {
+		return fmt.Errorf("%w: batch %s is %s", ErrBatchResultsUnavailable, batchID, batch.ProcessingStatus)
+	}
+
+	var body io.ReadCloser
+	if body, err = c.getStream(ctx, *batch.ResultsURL); err != nil {
+		return err
+	}
+	defer body.Close()
+
+	// Results can be arbitrarily large, so read whole lines rather than using a bufio.Scanner.
+	var r = bufio.NewReader(body)
+	for {
+		var line, rerr = r.ReadBytes('\n')
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			var result = &BatchResult{}
+			if err = json.Unmarshal(line, result); err != nil {
+				return err
+			}
+			if err = fn(result); errors.Is(err, errStopScan) {
+				return nil
+			} else if err != nil {
+				return err
+			}
+		}
+
+		switch {
+		case errors.Is(rerr, io.EOF):
+			return nil
+		case rerr != nil:
+			return rerr
+		}
+	}
+}
+
+// get makes a GET request to |u| and returns the response body.
+func (c *Client) get(ctx context.Context, u string) ([]byte, error) {
+	var body, err = c.getStream(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	return c.readBody(body)
+}
+
+// getStream makes a GET request to |u| and returns the response body, which the caller must close.
+func (c *Client) getStream(ctx context.Context, u string) (io.ReadCloser, error) {
+	var resp, err = c.getResponse(ctx, u, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Body, nil
+}
+
+// getResponse makes a GET request to |u|, with the fields of |header| set, and returns the successful response, whose
+// body the caller must close.
+func (c *Client) getResponse(ctx context.Context, u string, header http.Header) (*http.Response, error) {
+	var cancel context.CancelFunc
+	ctx, cancel = c.bind(ctx)
+	var req, err = c.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	addHeader(req, header)
+
+	var resp *http.Response
+	if resp, err = c.do(req, nil); err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if err = c.interpretResponse(resp); err != nil {
+		_ = resp.Body.Close()
+		cancel()
+		return nil, err
+	}
+
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
@@ -0,0 +1,45 @@
+package anthropic
+
+import (
+	"context"
+	"sync"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// BatchMessages sends |reqs| to the messages endpoint concurrently, with at most |concurrency| requests in flight at
+// once (a |concurrency| < 1 is treated as 1). The returned slices are index-aligned with |reqs|: for each request,
+// either the response or the error is set.
+//
+// Each request is cloned before it is sent, so |reqs| is never mutated and the same *v3.Request may appear in |reqs|
+// multiple times (e.g. a shared base request). Callers must not modify the requests while BatchMessages is running.
+func (c *Client) BatchMessages(ctx context.Context, reqs []*v3.Request[v3.Message], concurrency int) ([]*v3.Response, []error) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var resps = make([]*v3.Response, len(reqs))
+	var errs = make([]error, len(reqs))
+
+	var sem = make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i, req := range reqs {
+		wg.Add(1)
+		go func(i int, req *v3.Request[v3.Message]) {
+			defer wg.Done()
+
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				errs[i] = ctx.Err()
+				return
+			}
+
+			resps[i], errs[i] = c.NewMessageRequest(ctx, req.Clone())
+		}(i, req)
+	}
+	wg.Wait()
+
+	return resps, errs
+}
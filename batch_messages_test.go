@@ -0,0 +1,82 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// roundTripFunc is an http.RoundTripper backed by a function.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements the http.RoundTripper interface.
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// newTestResponse returns an *http.Response with the given status code and body.
+func newTestResponse(code int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: code,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(bytes.NewBufferString(body)),
+	}
+}
+
+// TestBatchMessagesSharedRequest sends the same request concurrently and checks that every serialized body is
+// identical. Run with -race to check that sending a shared request doesn't mutate it.
+func TestBatchMessagesSharedRequest(t *testing.T) {
+	var mu sync.Mutex
+	var bodies []string
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+
+		mu.Lock()
+		bodies = append(bodies, string(b))
+		mu.Unlock()
+
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}]}`), nil
+	})}))
+
+	var req = &v3.Request[v3.Message]{
+		Model:     v3.Claude3Haiku20240307,
+		MaxTokens: 100,
+		System:    v3.Optional("Be brief."),
+		Messages: []*v3.Message{
+			{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Hello"}}},
+		},
+	}
+
+	var reqs = make([]*v3.Request[v3.Message], 32)
+	for i := range reqs {
+		reqs[i] = req
+	}
+
+	var resps, errs = c.BatchMessages(context.Background(), reqs, 8)
+	for i := range reqs {
+		if errs[i] != nil {
+			t.Fatalf("request %d: %v", i, errs[i])
+		}
+		if resps[i].Content[0].Text != "Hi" {
+			t.Errorf("request %d: unexpected response %+v", i, resps[i])
+		}
+	}
+
+	if len(bodies) != len(reqs) {
+		t.Fatalf("sent %d requests, want %d", len(bodies), len(reqs))
+	}
+	for i, b := range bodies {
+		if b != bodies[0] {
+			t.Errorf("body %d = %s, want %s", i, b, bodies[0])
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestGetMessageBatchErrors(t *testing.T) {
+	const results = `{"custom_id":"a","result":{"type":"succeeded","message":{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}}}
+{"custom_id":"b","result":{"type":"errored","error":{"type":"error","error":{"type":"invalid_request_error","message":"max_tokens: Field required"}}}}
+{"custom_id":"c","result":{"type":"expired"}}
+`
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		switch r.URL.Path {
+		case "/v1/messages/batches/msgbatch_1":
+			return newTestResponse(http.StatusOK, `{"id":"msgbatch_1","type":"message_batch","processing_status":"ended","results_url":"https://api.anthropic.com/v1/messages/batches/msgbatch_1/results"}`), nil
+		case "/v1/messages/batches/msgbatch_1/results":
+			return newTestResponse(http.StatusOK, results), nil
+		case "/v1/messages/batches/msgbatch_2":
+			return newTestResponse(http.StatusOK, `{"id":"msgbatch_2","type":"message_batch","processing_status":"in_progress","results_url":null}`), nil
+		default:
+			return newTestResponse(http.StatusNotFound, `{"type":"error","error":{"type":"not_found_error","message":"Not found"}}`), nil
+		}
+	})}))
+
+	var errs, err = c.GetMessageBatchErrors(context.Background(), "msgbatch_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errs) != 2 {
+		t.Fatalf("len(errs) = %d, want 2", len(errs))
+	}
+	if errs[0].CustomID != "b" || errs[0].Type != BatchResultErrored || errs[0].Error == nil || errs[0].Error.Type != "invalid_request_error" {
+		t.Errorf("errs[0] = %+v, want errored request b", errs[0])
+	}
+	if errs[1].CustomID != "c" || errs[1].Type != BatchResultExpired || errs[1].Error != nil {
+		t.Errorf("errs[1] = %+v, want expired request c", errs[1])
+	}
+
+	if _, err = c.GetMessageBatchErrors(context.Background(), "msgbatch_2"); !errors.Is(err, ErrBatchResultsUnavailable) {
+		t.Errorf("GetMessageBatchErrors() error = %v, wantErr %v", err, ErrBatchResultsUnavailable)
+	}
+}
+
+func TestGetMessageBatchResult(t *testing.T) {
+	// The results after the match are invalid, so scanning past it would fail.
+	const results = `{"custom_id":"a","result":{"type":"expired"}}
+{"custom_id":"b","result":{"type":"succeeded","message":{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"Hi"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}}}
+not json
+`
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path == "/v1/messages/batches/msgbatch_1/results" {
+			return newTestResponse(http.StatusOK, results), nil
+		}
+		return newTestResponse(http.StatusOK, `{"id":"msgbatch_1","type":"message_batch","processing_status":"ended","results_url":"https://api.anthropic.com/v1/messages/batches/msgbatch_1/results"}`), nil
+	})}))
+
+	var r, err = c.GetMessageBatchResult(context.Background(), "msgbatch_1", "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Result.Type != BatchResultSucceeded || r.Result.Message == nil || r.Result.Message.ID != "msg_2" {
+		t.Errorf("GetMessageBatchResult() = %+v, want the succeeded result for b", r)
+	}
+
+	if _, err = c.GetMessageBatchResult(context.Background(), "msgbatch_1", "z"); err == nil {
+		t.Error("GetMessageBatchResult() error = nil, want an error for a missing custom_id")
+	}
+}
+
+func TestCreateMessageBatchBetas(t *testing.T) {
+	var betas []string
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		betas = r.Header.Values(betaHeaderName)
+		return newTestResponse(http.StatusOK, `{"id":"msgbatch_1","processing_status":"in_progress"}`), nil
+	})}))
+
+	var _, err = c.CreateMessageBatch(context.Background(), []*BatchRequest{
+		{CustomID: "a", Params: &v3.Request[v3.Message]{Model: v3.Claude3Dot7Sonnet20250219, MaxTokens: 16, Betas: []string{v3.BetaOutput128k}}},
+		{CustomID: "b", Params: &v3.Request[v3.Message]{Model: v3.Claude3Dot7Sonnet20250219, MaxTokens: 16, Betas: []string{v3.BetaOutput128k, betaPromptCacheHeaderValue}}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{v3.BetaOutput128k, betaPromptCacheHeaderValue}; !reflect.DeepEqual(betas, want) {
+		t.Errorf("%s = %v, want %v", betaHeaderName, betas, want)
+	}
+}
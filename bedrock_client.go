@@ -7,6 +7,8 @@ import (
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/client"
 	"github.com/aws/aws-sdk-go/service/bedrockruntime"
+
+	v3 "github.com/fabiustech/anthropic/v3"
 )
 
 // NewBedrockClient returns a new client for the Bedrock API.
@@ -20,6 +22,8 @@ func NewBedrockClient(p client.ConfigProvider, cfgs ...*aws.Config) *BedrockClie
 type BedrockClient struct {
 	client *bedrockruntime.BedrockRuntime
 	debug  bool
+	// region is the region whose inference profiles are used. See SetRegion.
+	region Region
 }
 
 // Debug enables debug logging. When enabled, the client will log the request's prompt.
@@ -27,13 +31,30 @@ func (bc *BedrockClient) Debug() {
 	bc.debug = true
 }
 
+// bedrockVersion is the anthropic_version Bedrock requires in request bodies.
+const bedrockVersion = "bedrock-2023-05-31"
+
+// BedrockRequestBody returns the JSON body BedrockClient sends to Bedrock for |req|: the request with
+// "anthropic_version" set to the Bedrock version and "model" removed (Bedrock takes the model as the model ID instead).
+// This allows sending requests through AWS infrastructure the client doesn't wrap, e.g. Bedrock batch inference.
+func BedrockRequestBody(req *Request) ([]byte, error) {
+	return marshalObject(req, map[string]any{"anthropic_version": bedrockVersion}, "model")
+}
+
+// BedrockMessageRequestBody is like BedrockRequestBody, but for messages requests. The request's Betas are sent in
+// the body's "anthropic_beta" field, where Bedrock takes them.
+func BedrockMessageRequestBody[T v3.RequestMessage](req *v3.Request[T]) ([]byte, error) {
+	var set = map[string]any{"anthropic_version": bedrockVersion}
+	if len(req.Betas) > 0 {
+		set["anthropic_beta"] = req.Betas
+	}
+
+	return marshalObject(req, set, "model")
+}
+
 // NewCompletion returns a completion response from the API.
 func (bc *BedrockClient) NewCompletion(ctx context.Context, req *Request) (*Response, error) {
-	r := *req
-	var m = r.Model
-	r.Model = UnknownModel
-
-	var b, err = json.Marshal(r)
+	var b, err = BedrockRequestBody(req)
 	if err != nil {
 		return nil, err
 	}
@@ -41,7 +62,7 @@ func (bc *BedrockClient) NewCompletion(ctx context.Context, req *Request) (*Resp
 	var resp *bedrockruntime.InvokeModelOutput
 	resp, err = bc.client.InvokeModelWithContext(ctx, &bedrockruntime.InvokeModelInput{
 		Body:    b,
-		ModelId: aws.String(m.BedrockString()),
+		ModelId: aws.String(bc.modelID(req.Model.BedrockString())),
 	})
 	if err != nil {
 		return nil, err
@@ -91,13 +112,10 @@ loop:
 }
 
 // NewStreamingCompletion returns two channels: the first will be sent |*Response|s as they are received from
-// the API and the second is sent any error(s) encountered while receiving / parsing responses.
+// the API and the second is sent any error(s) encountered while receiving / parsing responses. Canceling |ctx| closes
+// the underlying Bedrock stream and sends ctx.Err() on the error channel.
 func (bc *BedrockClient) NewStreamingCompletion(ctx context.Context, req *Request) (<-chan *Response, <-chan error, error) {
-	r := *req
-	var m = r.Model
-	r.Model = UnknownModel
-
-	var b, err = json.Marshal(r)
+	var b, err = BedrockRequestBody(req)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -105,24 +123,34 @@ func (bc *BedrockClient) NewStreamingCompletion(ctx context.Context, req *Reques
 	var resp *bedrockruntime.InvokeModelWithResponseStreamOutput
 	resp, err = bc.client.InvokeModelWithResponseStreamWithContext(ctx, &bedrockruntime.InvokeModelWithResponseStreamInput{
 		Body:    b,
-		ModelId: aws.String(m.BedrockString()),
+		ModelId: aws.String(bc.modelID(req.Model.BedrockString())),
 	})
 	if err != nil {
 		return nil, nil, err
 	}
 
 	var respCh = make(chan *Response)
-	var errCh = make(chan error)
+	// At most one error is sent, so buffering it means the goroutine never blocks on it.
+	var errCh = make(chan error, 1)
 
 	go func() {
 		defer close(respCh)
 		defer close(errCh)
 
 		var s = resp.GetStream()
+		defer s.Close()
+
 		var events = s.Events()
 		for {
 			select {
-			case ev := <-events:
+			case ev, ok := <-events:
+				if !ok {
+					if err = s.Err(); err != nil {
+						errCh <- err
+					}
+					return
+				}
+
 				switch pp := ev.(type) {
 				case *bedrockruntime.PayloadPart:
 					var out = &Response{}
@@ -131,7 +159,10 @@ func (bc *BedrockClient) NewStreamingCompletion(ctx context.Context, req *Reques
 						return
 					}
 
-					respCh <- out
+					if !send(ctx, respCh, out) {
+						errCh <- ctx.Err()
+						return
+					}
 
 					if out.StopReason != nil {
 						return
@@ -139,17 +170,12 @@ func (bc *BedrockClient) NewStreamingCompletion(ctx context.Context, req *Reques
 				case *bedrockruntime.ResponseStreamUnknownEvent:
 					// Continue.
 				}
-
-			default:
-				// Continue.
-			}
-
-			if err = s.Err(); err != nil {
-				errCh <- err
-				break
+			case <-ctx.Done():
+				// Closing the stream (deferred above) stops Bedrock from sending any further events.
+				errCh <- ctx.Err()
+				return
 			}
 		}
-
 	}()
 
 	return respCh, errCh, nil
@@ -0,0 +1,161 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/bedrockruntime"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestBedrockRequestBody(t *testing.T) {
+	var tests = []struct {
+		name  string
+		body  func() ([]byte, error)
+		betas string
+	}{
+		{
+			name: "completion",
+			body: func() ([]byte, error) {
+				return BedrockRequestBody(&Request{Prompt: "\n\nHuman: Hi\n\nAssistant:", Model: Claude2Dot1, MaxTokensToSample: 16})
+			},
+		},
+		{
+			name: "message",
+			body: func() ([]byte, error) {
+				return BedrockMessageRequestBody(&v3.Request[v3.ShortHandMessage]{
+					Model:     v3.Claude3Haiku20240307,
+					Messages:  []*v3.ShortHandMessage{{Role: v3.RoleUser, Content: "Hi"}},
+					MaxTokens: 16,
+					Betas:     []string{v3.BetaOutput128k},
+				})
+			},
+			betas: `["` + v3.BetaOutput128k + `"]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b, err = tt.body()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]json.RawMessage
+			if err = json.Unmarshal(b, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(fields["anthropic_version"]); got != `"`+bedrockVersion+`"` {
+				t.Errorf("anthropic_version = %s, want %q", got, bedrockVersion)
+			}
+			if _, ok := fields["model"]; ok {
+				t.Errorf("body = %s, want no model", b)
+			}
+			if got := string(fields["anthropic_beta"]); got != tt.betas {
+				t.Errorf("anthropic_beta = %s, want %s", got, tt.betas)
+			}
+		})
+	}
+}
+
+func TestConverseInput(t *testing.T) {
+	var tests = []struct {
+		name   string
+		req    *v3.Request[v3.Message]
+		expErr error
+	}{
+		{
+			name: "text",
+			req: &v3.Request[v3.Message]{
+				Model:     v3.Claude3Haiku20240307,
+				System:    v3.Optional("Be brief"),
+				Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Hi"}}}},
+				MaxTokens: 16,
+			},
+		},
+		{
+			name: "tools",
+			req: &v3.Request[v3.Message]{
+				Model:     v3.Claude3Haiku20240307,
+				Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Hi"}}}},
+				MaxTokens: 16,
+				Tools:     []*v3.Tool{{Name: "get_weather"}},
+			},
+			expErr: ErrConverseUnsupported,
+		},
+		{
+			name: "tool result",
+			req: &v3.Request[v3.Message]{
+				Model:     v3.Claude3Haiku20240307,
+				Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "tool_result", ToolUseID: "toolu_1"}}}},
+				MaxTokens: 16,
+			},
+			expErr: ErrConverseUnsupported,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var in, err = converseInput(tt.req)
+			if !errors.Is(err, tt.expErr) {
+				t.Fatalf("converseInput() error = %v, want %v", err, tt.expErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if got := aws.StringValue(in.ModelId); got != "anthropic.claude-3-haiku-20240307-v1:0" {
+				t.Errorf("ModelId = %q", got)
+			}
+			if got := aws.StringValue(in.System[0].Text); got != "Be brief" {
+				t.Errorf("System = %q, want %q", got, "Be brief")
+			}
+			if got := aws.StringValue(in.Messages[0].Role); got != "user" {
+				t.Errorf("Role = %q, want %q", got, "user")
+			}
+			if got := aws.StringValue(in.Messages[0].Content[0].Text); got != "Hi" {
+				t.Errorf("Text = %q, want %q", got, "Hi")
+			}
+			if got := aws.Int64Value(in.InferenceConfig.MaxTokens); got != 16 {
+				t.Errorf("MaxTokens = %d, want 16", got)
+			}
+		})
+	}
+}
+
+func TestConverseResponse(t *testing.T) {
+	var resp = converseResponse(v3.Claude3Haiku20240307, &bedrockruntime.ConverseOutput{
+		Output: &bedrockruntime.ConverseOutput_{Message: &bedrockruntime.Message{
+			Role:    aws.String("assistant"),
+			Content: []*bedrockruntime.ContentBlock{{Text: aws.String("Hello")}},
+		}},
+		StopReason: aws.String("end_turn"),
+		Usage:      &bedrockruntime.TokenUsage{InputTokens: aws.Int64(3), OutputTokens: aws.Int64(1)},
+	})
+
+	if got := resp.Text(); got != "Hello" {
+		t.Errorf("Text() = %q, want %q", got, "Hello")
+	}
+	if resp.StopReason != v3.StopReasonEndTurn {
+		t.Errorf("StopReason = %v, want %v", resp.StopReason, v3.StopReasonEndTurn)
+	}
+	if resp.Usage.InputTokens != 3 || resp.Usage.OutputTokens != 1 {
+		t.Errorf("Usage = %+v", resp.Usage)
+	}
+}
+
+func TestBedrockClientRegion(t *testing.T) {
+	var bc = &BedrockClient{}
+	var id = v3.Claude3Dot5Sonnet20240620.BedrockString()
+	if got := bc.modelID(id); got != id {
+		t.Errorf("modelID() = %q, want %q", got, id)
+	}
+
+	bc.SetRegion(RegionEU)
+	if got, want := bc.modelID(id), "eu.anthropic.claude-3-5-sonnet-20240620-v1:0"; got != want {
+		t.Errorf("modelID() = %q, want %q", got, want)
+	}
+}
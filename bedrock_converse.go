@@ -0,0 +1,140 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/bedrockruntime"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// ErrConverseUnsupported is returned by BedrockClient.Converse for requests using features which can't be expressed
+// in the Converse request shape supported by the AWS SDK.
+var ErrConverseUnsupported = errors.New("unsupported by the bedrock converse api")
+
+// Converse makes a request through Bedrock's Converse API, which uses a message format shared across providers,
+// mapping |req| to the Converse request shape (system, messages, and inferenceConfig) and the result back into a
+// v3.Response. Use NewCompletion et al. for raw Anthropic-shape access via InvokeModel.
+//
+// Note: the v1 AWS SDK can't represent JSON documents, so tool definitions and tool_use blocks can't be sent; only
+// text and base64 image content is supported. Requests using anything else (including Tools, TopK, and Thinking) fail
+// with an error wrapping ErrConverseUnsupported.
+func (bc *BedrockClient) Converse(ctx context.Context, req *v3.Request[v3.Message]) (*v3.Response, error) {
+	var in, err = converseInput(req)
+	if err != nil {
+		return nil, err
+	}
+	in.ModelId = aws.String(bc.modelID(aws.StringValue(in.ModelId)))
+
+	var out *bedrockruntime.ConverseOutput
+	if out, err = bc.client.ConverseWithContext(ctx, in); err != nil {
+		return nil, err
+	}
+
+	return converseResponse(req.Model, out), nil
+}
+
+// converseInput maps |req| to the Converse request shape.
+func converseInput(req *v3.Request[v3.Message]) (*bedrockruntime.ConverseInput, error) {
+	switch {
+	case len(req.Tools) > 0 || req.ToolChoice != nil:
+		return nil, fmt.Errorf("%w: tools", ErrConverseUnsupported)
+	case req.TopK != nil:
+		return nil, fmt.Errorf("%w: top_k", ErrConverseUnsupported)
+	case req.Thinking != nil:
+		return nil, fmt.Errorf("%w: thinking", ErrConverseUnsupported)
+	case len(req.Betas) > 0:
+		return nil, fmt.Errorf("%w: betas", ErrConverseUnsupported)
+	case req.System != nil && len(req.SystemMessages) > 0:
+		return nil, v3.ErrSystemConflict
+	}
+
+	var in = &bedrockruntime.ConverseInput{
+		ModelId: aws.String(req.Model.BedrockString()),
+		InferenceConfig: &bedrockruntime.InferenceConfiguration{
+			MaxTokens:   aws.Int64(int64(req.MaxTokens)),
+			Temperature: req.Temperature,
+		},
+	}
+	if req.TopP != nil {
+		in.InferenceConfig.TopP = aws.Float64(*req.TopP)
+	}
+	if len(req.StopSequences) > 0 {
+		in.InferenceConfig.StopSequences = aws.StringSlice(req.StopSequences)
+	}
+
+	if req.System != nil {
+		in.System = []*bedrockruntime.SystemContentBlock{{Text: req.System}}
+	}
+	for _, s := range req.SystemMessages {
+		if s.Type != "text" {
+			return nil, fmt.Errorf("%w: %s", v3.ErrInvalidSystemBlock, s.Type)
+		}
+		in.System = append(in.System, &bedrockruntime.SystemContentBlock{Text: aws.String(s.Text)})
+	}
+
+	for _, m := range req.Messages {
+		var msg = &bedrockruntime.Message{Role: aws.String(m.Role.String())}
+		for _, c := range m.Content {
+			var block, err = converseContent(c)
+			if err != nil {
+				return nil, err
+			}
+			msg.Content = append(msg.Content, block)
+		}
+		in.Messages = append(in.Messages, msg)
+	}
+
+	return in, nil
+}
+
+// converseContent maps |c| to a Converse content block.
+func converseContent(c *v3.MessageContent) (*bedrockruntime.ContentBlock, error) {
+	switch {
+	case c.Type == "text":
+		return &bedrockruntime.ContentBlock{Text: aws.String(c.Text)}, nil
+	case c.Type == "image" && c.Source != nil && c.Source.Type == "base64":
+		var b, err = base64.StdEncoding.DecodeString(c.Source.Data)
+		if err != nil {
+			return nil, err
+		}
+		return &bedrockruntime.ContentBlock{Image: &bedrockruntime.ImageBlock{
+			Format: aws.String(strings.TrimPrefix(c.Source.MediaType, "image/")),
+			Source: &bedrockruntime.ImageSource{Bytes: b},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("%w: %s content", ErrConverseUnsupported, c.Type)
+	}
+}
+
+// converseResponse maps the Converse output |out| for a request to |model| to a v3.Response.
+func converseResponse(model v3.Model, out *bedrockruntime.ConverseOutput) *v3.Response {
+	var resp = &v3.Response{
+		Model: model,
+		Role:  v3.RoleAssistant,
+		Type:  "message",
+	}
+	if out.Output != nil && out.Output.Message != nil {
+		for _, c := range out.Output.Message.Content {
+			if c.Text != nil {
+				resp.Content = append(resp.Content, &v3.MessageContent{Type: "text", Text: *c.Text})
+			}
+		}
+	}
+	// Converse's stop reasons for Claude share the Messages API's names. Those without an equivalent (e.g.
+	// "guardrail_intervened") are passed through as-is.
+	resp.StopReason = v3.StopReason(aws.StringValue(out.StopReason))
+	if out.Usage != nil {
+		resp.Usage = &v3.Usage{
+			InputTokens:  int(aws.Int64Value(out.Usage.InputTokens)),
+			OutputTokens: int(aws.Int64Value(out.Usage.OutputTokens)),
+		}
+	}
+
+	return resp
+}
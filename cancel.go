@@ -0,0 +1,56 @@
+package anthropic
+
+import (
+	"context"
+	"io"
+)
+
+// CancelAll cancels every request in flight on |c|, including streams, as if each request's context had been
+// canceled: they fail with an error wrapping context.Canceled. This suits a clean shutdown or a "stop" button, without
+// threading a shared context into every call site.
+//
+// Only requests started before CancelAll is called are canceled. No reset is needed: requests started afterwards
+// proceed normally.
+func (c *Client) CancelAll() {
+	c.cancelMu.Lock()
+	defer c.cancelMu.Unlock()
+
+	if c.canceled != nil {
+		close(c.canceled)
+	}
+	c.canceled = make(chan struct{})
+}
+
+// bind returns a context derived from |ctx| which is also canceled by CancelAll. The returned cancel function must be
+// called once the request is complete.
+func (c *Client) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	c.cancelMu.Lock()
+	if c.canceled == nil {
+		c.canceled = make(chan struct{})
+	}
+	var canceled = c.canceled
+	c.cancelMu.Unlock()
+
+	var bound, cancel = context.WithCancel(ctx)
+	go func() {
+		select {
+		case <-canceled:
+			cancel()
+		case <-bound.Done():
+		}
+	}()
+
+	return bound, cancel
+}
+
+// cancelOnClose is a response body which cancels its request's context when it is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close implements the io.Closer interface.
+func (b *cancelOnClose) Close() error {
+	defer b.cancel()
+	return b.ReadCloser.Close()
+}
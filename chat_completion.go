@@ -0,0 +1,105 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+const chatCompletionsEndpoint = "v1/chat/completions"
+
+// ChatCompletionRequest is a request to the OpenAI-compatible chat completions endpoint, in OpenAI's format. Only the
+// basic parameters are supported. See ChatCompletion.
+// https://docs.anthropic.com/en/api/openai-sdk
+type ChatCompletionRequest struct {
+	// Model is the model to use. It's sent as the model's Anthropic id (e.g. "claude-3-7-sonnet-20250219"). Required.
+	Model v3.Model `json:"model"`
+	// Messages is the conversation, in OpenAI's format. System (or developer) messages are hoisted into the system
+	// prompt by the endpoint. Required.
+	Messages []*ChatCompletionMessage `json:"messages"`
+	// MaxTokens is the maximum number of tokens to generate. Optional.
+	MaxTokens int `json:"max_tokens,omitempty"`
+	// Temperature is the sampling temperature, between 0 and 1 (larger values are capped at 1). Optional.
+	Temperature *float64 `json:"temperature,omitempty"`
+	// TopP is the nucleus sampling probability. Optional.
+	TopP *float64 `json:"top_p,omitempty"`
+	// Stop is a list of sequences to stop generating at. Optional.
+	Stop []string `json:"stop,omitempty"`
+	// User identifies the end user, like v3.Metadata's UserID. Optional.
+	User string `json:"user,omitempty"`
+}
+
+// ChatCompletionMessage is a message of a chat completion, in OpenAI's format.
+type ChatCompletionMessage struct {
+	// Role is the role of the message's author: "system", "developer", "user", or "assistant".
+	Role string `json:"role"`
+	// Content is the text of the message.
+	Content string `json:"content"`
+}
+
+// ChatCompletionResponse is a response from the OpenAI-compatible chat completions endpoint, in OpenAI's format.
+type ChatCompletionResponse struct {
+	// ID is the unique identifier of the completion.
+	ID string `json:"id"`
+	// Object is the object type. For chat completions, this is always "chat.completion".
+	Object string `json:"object"`
+	// Created is when the completion was created, in seconds since the Unix epoch.
+	Created int64 `json:"created"`
+	// Model is the model which generated the completion.
+	Model string `json:"model"`
+	// Choices holds the generated message. The endpoint only generates one.
+	Choices []*ChatCompletionChoice `json:"choices"`
+	// Usage is the usage of the request.
+	Usage *v3.OpenAIUsage `json:"usage,omitempty"`
+	// RequestID is the id the API assigned to the request (its |request-id| response header).
+	RequestID string `json:"-"`
+	// ClientRequestID is the id the client generated for the request, if it was configured to (see
+	// WithRequestIDHeader).
+	ClientRequestID string `json:"-"`
+}
+
+// ChatCompletionChoice is a generated message of a chat completion.
+type ChatCompletionChoice struct {
+	// Index is the index of the choice.
+	Index int `json:"index"`
+	// Message is the generated message.
+	Message *ChatCompletionMessage `json:"message"`
+	// FinishReason is why generation stopped, in OpenAI's terms: "stop", "length", or "tool_calls".
+	FinishReason string `json:"finish_reason"`
+}
+
+// Text returns the text of the first choice of |r|, or an empty string if it has none.
+func (r *ChatCompletionResponse) Text() string {
+	if len(r.Choices) == 0 || r.Choices[0].Message == nil {
+		return ""
+	}
+
+	return r.Choices[0].Message.Content
+}
+
+// ChatCompletion makes a request to Anthropic's OpenAI-compatible chat completions endpoint, so code written against
+// OpenAI's request and response shapes can reach Claude with minimal change. It's meant for migration: the messages
+// endpoint (see NewMessageRequest) supports every feature, while this endpoint ignores or rejects many of OpenAI's
+// parameters. Errors are returned as *ResponseErrors, like the client's other methods.
+func (c *Client) ChatCompletion(ctx context.Context, req *ChatCompletionRequest) (*ChatCompletionResponse, error) {
+	if req.Model == v3.UnknownModel {
+		return nil, v3.ErrUnknownModel
+	}
+	if len(req.Messages) == 0 {
+		return nil, v3.ErrEmptyMessages
+	}
+
+	var b, ids, err = c.post(ctx, chatCompletionsEndpoint, req, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp = &ChatCompletionResponse{}
+	if err = json.Unmarshal(b, resp); err != nil {
+		return nil, err
+	}
+	resp.RequestID, resp.ClientRequestID = ids.server, ids.client
+
+	return resp, nil
+}
@@ -0,0 +1,73 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestChatCompletion(t *testing.T) {
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/"+chatCompletionsEndpoint {
+			t.Errorf("path = %s, want /%s", r.URL.Path, chatCompletionsEndpoint)
+		}
+
+		var body map[string]json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		if got := string(body["model"]); got != `"claude-3-5-haiku-20241022"` {
+			t.Errorf("model = %s, want the model's id", got)
+		}
+		if got := string(body["messages"]); got != `[{"role":"system","content":"Be brief."},{"role":"user","content":"Hi"}]` {
+			t.Errorf("messages = %s", got)
+		}
+
+		var resp = newTestResponse(http.StatusOK, `{"id":"chatcmpl_1","object":"chat.completion","created":1700000000,"model":"claude-3-5-haiku-20241022","choices":[{"index":0,"message":{"role":"assistant","content":"Hello!"},"finish_reason":"stop"}],"usage":{"prompt_tokens":12,"completion_tokens":3,"total_tokens":15}}`)
+		resp.Header.Set(requestIDHeaderName, "req_1")
+		return resp, nil
+	})}))
+
+	var tests = []struct {
+		name    string
+		req     *ChatCompletionRequest
+		wantErr error
+	}{
+		{
+			name: "Valid",
+			req: &ChatCompletionRequest{
+				Model:     v3.Claude3Dot5Haiku20241022,
+				Messages:  []*ChatCompletionMessage{{Role: "system", Content: "Be brief."}, {Role: "user", Content: "Hi"}},
+				MaxTokens: 16,
+			},
+		},
+		{name: "No Model", req: &ChatCompletionRequest{Messages: []*ChatCompletionMessage{{Role: "user", Content: "Hi"}}}, wantErr: v3.ErrUnknownModel},
+		{name: "No Messages", req: &ChatCompletionRequest{Model: v3.Claude3Dot5Haiku20241022}, wantErr: v3.ErrEmptyMessages},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp, err = c.ChatCompletion(context.Background(), tt.req)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ChatCompletion() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			if resp.Text() != "Hello!" || resp.Choices[0].FinishReason != "stop" {
+				t.Errorf("response = %+v, want the generated message", resp)
+			}
+			if resp.Usage == nil || resp.Usage.TotalTokens != 15 {
+				t.Errorf("Usage = %+v, want 15 total tokens", resp.Usage)
+			}
+			if resp.RequestID != "req_1" {
+				t.Errorf("RequestID = %q, want %q", resp.RequestID, "req_1")
+			}
+		})
+	}
+}
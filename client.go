@@ -1,6 +1,7 @@
 package anthropic
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
@@ -10,26 +11,36 @@ import (
 	"log"
 	"log/slog"
 	"net/http"
-	"net/url"
-	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	v3 "github.com/fabiustech/anthropic/v3"
 )
 
 const (
-	host               = "api.anthropic.com"
+	defaultBaseURL     = "https://api.anthropic.com"
 	completionEndpoint = "v1/complete"
 	messagesEndpoint   = "v1/messages"
 	apiKeyHeader       = "X-Api-Key"
 	apiVersionHeader   = "Anthropic-Version"
-	defaultVersion     = "2023-06-01"
+	defaultVersion     = Version20230601
 
 	// Header and value to enable using the beta version of the API which allows for a max output tokens of 8192.
 	// https://docs.anthropic.com/en/release-notes/api#july-15th-2024
 	betaHeaderName             = "anthropic-beta"
-	betaOutputTokenHeaderValue = "max-tokens-3-5-sonnet-2024-07-15"
+	betaOutputTokenHeaderValue = v3.BetaMaxTokens35Sonnet
 	betaPromptCacheHeaderValue = "prompt-caching-2024-07-31"
+	// Header value to raise Claude 3.7 Sonnet's max output tokens to 128K.
+	// https://docs.anthropic.com/en/docs/about-claude/models/extended-thinking-models#extended-output-capabilities-beta
+	betaOutput128kHeaderValue = v3.BetaOutput128k
+	// Header value to enable token-efficient tool use, which reduces the output tokens spent on tool calls.
+	// https://docs.anthropic.com/en/docs/build-with-claude/tool-use/token-efficient-tool-use
+	betaTokenEfficientToolsHeaderValue = "token-efficient-tools-2025-02-19"
+
+	// Header used to scope requests to a workspace.
+	workspaceHeader = "Anthropic-Workspace-Id"
 )
 
 // Client is a client for the Anthropic API.
@@ -38,20 +49,77 @@ type Client struct {
 	debug bool
 	// requestHeaders is a map of custom headers to be sent with each request.
 	requestHeaders http.Header
+	// streamHeaders override the default headers of streaming requests. See WithStreamHeaders.
+	streamHeaders http.Header
+	// httpClient is the client used to make requests. If nil, http.DefaultClient is used.
+	httpClient *http.Client
+	// maxResponseBytes is the maximum size of a response body. If 0, response bodies are not limited.
+	maxResponseBytes int64
+	// streamConnectTimeout is the maximum time to wait for a stream to start. If 0, there is no timeout.
+	streamConnectTimeout time.Duration
+	// streamIdleTimeout is the maximum time to wait between data on a stream. If 0, there is no timeout.
+	streamIdleTimeout time.Duration
+	// dump is where requests and responses are written, if set.
+	dump *requestDump
+	// baseURL is the URL requests are sent to. If empty, defaultBaseURL is used.
+	baseURL string
+	// maxRetries is the number of times a failed request is retried.
+	maxRetries int
+	// strict enables invariant checks on decoded responses.
+	strict bool
+	// requestIDHeader is the header a generated id is sent in with each request, if set.
+	requestIDHeader string
+	// modelFallback is the model requests are retried with if their model isn't found. See WithModelFallback.
+	modelFallback v3.Model
+	// tokenCounter counts tokens for helpers which need counts. If nil, v3.HeuristicTokenCounter is used.
+	tokenCounter v3.TokenCounter
+	// modelDefaultMaxTokens fills in an unset MaxTokens based on the request's model.
+	modelDefaultMaxTokens bool
+	// canceled is closed by CancelAll to cancel the requests in flight. It's replaced by each call.
+	canceled chan struct{}
+	cancelMu sync.Mutex
+	// tools are the tools registered with RegisterTool, by name.
+	tools   map[string]*registeredTool
+	toolsMu sync.RWMutex
 }
 
-// NewClient returns a client with the given API key.
-func NewClient(key string) *Client {
-	return &Client{key: key, requestHeaders: http.Header{apiVersionHeader: {defaultVersion}}}
+// NewClient returns a client with the given API key, configured by |opts|.
+func NewClient(key string, opts ...Option) *Client {
+	var c = &Client{key: key, requestHeaders: http.Header{apiVersionHeader: {defaultVersion}}}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Versions of the API (sent in the |Anthropic-Version| header) this library has been tested against.
+// https://docs.anthropic.com/en/api/versioning
+const (
+	// Version20230101 is the initial API release.
+	Version20230101 = "2023-01-01"
+	// Version20230601 is the current API version. It changed the format of streaming responses.
+	Version20230601 = "2023-06-01"
+)
+
+// knownVersions are the versions this library has been tested against.
+var knownVersions = map[string]bool{
+	Version20230101: true,
+	Version20230601: true,
 }
 
 // SetVersion set's the value passed in the |Anthropic-Version| header for requests.
-// The default value is "2023-06-01".
+// The default value is Version20230601. Versions other than the Version constants are sent as-is for forward
+// compatibility, but a warning is logged since they're more likely a typo than a version this library supports.
 func (c *Client) SetVersion(version string) {
 	if c.requestHeaders == nil {
 		c.requestHeaders = make(http.Header)
 	}
 
+	if !knownVersions[version] {
+		slog.Warn("unknown anthropic api version", "version", version)
+	}
+
 	c.requestHeaders.Set(apiVersionHeader, version)
 }
 
@@ -84,6 +152,49 @@ func (c *Client) SetBetaPromptCacheHeader() {
 	c.requestHeaders.Add(betaHeaderName, betaPromptCacheHeaderValue)
 }
 
+// SetBetaOutput128kHeader sets the |anthropic-beta| header to "output-128k-2025-02-19", which raises Claude 3.7
+// Sonnet's max output tokens to 128K. Generating that many tokens takes far longer than the API allows a non-streaming
+// request to run, so requests with a MaxTokens above 21,333 must use one of the streaming methods (e.g.
+// NewStreamingMessageRequest or NewMessageStreamedBatchResponse); the non-streaming methods return
+// ErrStreamingRequired for them.
+func (c *Client) SetBetaOutput128kHeader() {
+	if c.requestHeaders == nil {
+		c.requestHeaders = make(http.Header)
+	}
+
+	c.requestHeaders.Add(betaHeaderName, betaOutput128kHeaderValue)
+}
+
+// SetBetaTokenEfficientToolsHeader sets the |anthropic-beta| header to "token-efficient-tools-2025-02-19", which
+// reduces the output tokens used by tool calls. Requests are serialized the same way with or without it, and
+// tool_choice (including DisableParallelToolUse) behaves the same; the beta only changes how the model emits tool
+// calls. It's only supported by Claude 3.7 Sonnet.
+func (c *Client) SetBetaTokenEfficientToolsHeader() {
+	if c.requestHeaders == nil {
+		c.requestHeaders = make(http.Header)
+	}
+
+	c.requestHeaders.Add(betaHeaderName, betaTokenEfficientToolsHeaderValue)
+}
+
+// SetWorkspace sets the |anthropic-workspace-id| header, which scopes requests to the workspace |id|. Passing an empty
+// |id| removes the header.
+//
+// Note: the Anthropic API itself determines the workspace from the API key; this header is for gateways / proxies that
+// route requests for multiple workspaces through a shared key.
+func (c *Client) SetWorkspace(id string) {
+	if c.requestHeaders == nil {
+		c.requestHeaders = make(http.Header)
+	}
+
+	if id == "" {
+		c.requestHeaders.Del(workspaceHeader)
+		return
+	}
+
+	c.requestHeaders.Set(workspaceHeader, id)
+}
+
 // Debug enables debug logging. When enabled, the client will log the request's prompt.
 func (c *Client) Debug() {
 	c.debug = true
@@ -95,7 +206,7 @@ func (c *Client) NewCompletion(ctx context.Context, req *Request) (*Response, er
 		log.Printf("prompt: %s\n", req.Prompt)
 	}
 
-	var b, err = c.post(ctx, completionEndpoint, req)
+	var b, _, err = c.post(ctx, completionEndpoint, req, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -108,16 +219,107 @@ func (c *Client) NewCompletion(ctx context.Context, req *Request) (*Response, er
 	return resp, nil
 }
 
+// maxNonStreamingTokens is the largest MaxTokens the API accepts for a non-streaming request. It's the number of tokens
+// that can be generated in 10 minutes at 128K tokens per hour.
+const maxNonStreamingTokens = 21333
+
+// ErrStreamingRequired is returned by the non-streaming message methods when a request's MaxTokens is so large the
+// API requires it to be streamed.
+var ErrStreamingRequired = errors.New("max_tokens is too large for a non-streaming request; use a streaming method")
+
+// betas returns the beta features enabled by the client's |anthropic-beta| headers.
+func (c *Client) betas() []string {
+	var out []string
+	for _, v := range c.requestHeaders.Values(betaHeaderName) {
+		for _, b := range strings.Split(v, ",") {
+			if b = strings.TrimSpace(b); b != "" {
+				out = append(out, b)
+			}
+		}
+	}
+
+	return out
+}
+
+// betaHeader returns the header enabling |betas| for a single request, or nil if there are none.
+func betaHeader(betas []string) http.Header {
+	if len(betas) == 0 {
+		return nil
+	}
+
+	return http.Header{betaHeaderName: betas}
+}
+
+// addHeader sets the fields of |header| on |req|. Betas are added to those enabled for the client (without repeating
+// any), rather than replacing them.
+func addHeader(req *http.Request, header http.Header) {
+	for k, v := range header {
+		if http.CanonicalHeaderKey(k) != http.CanonicalHeaderKey(betaHeaderName) {
+			req.Header[k] = v
+			continue
+		}
+
+		var enabled = make(map[string]bool)
+		for _, b := range req.Header.Values(betaHeaderName) {
+			enabled[b] = true
+		}
+		for _, b := range v {
+			if !enabled[b] {
+				enabled[b] = true
+				req.Header.Add(betaHeaderName, b)
+			}
+		}
+	}
+}
+
+// checkMaxTokens returns an error if |maxTokens| exceeds |model|'s output limit given the client's beta headers and the
+// request's |betas|, or if the request isn't |streaming| and |maxTokens| is too large for a non-streaming request.
+func (c *Client) checkMaxTokens(model v3.Model, maxTokens int, betas []string, streaming bool) error {
+	if limit := model.MaxOutputTokens(append(c.betas(), betas...)); limit > 0 && maxTokens > limit {
+		return fmt.Errorf("%w: %d > %d for %s", v3.ErrMaxTokensExceeded, maxTokens, limit, model)
+	}
+	if !streaming && maxTokens > maxNonStreamingTokens {
+		return fmt.Errorf("%w: %d > %d", ErrStreamingRequired, maxTokens, maxNonStreamingTokens)
+	}
+
+	return nil
+}
+
+// defaultMaxTokens is the MaxTokens set by WithModelAwareDefaultMaxTokens, for models whose limit is higher.
+const defaultMaxTokens = 4096
+
+// withDefaultMaxTokens returns |req|, or a copy of it with MaxTokens set to its model's default if it's unset and the
+// client is configured with WithModelAwareDefaultMaxTokens. |req| itself is never modified.
+func withDefaultMaxTokens[T v3.RequestMessage](c *Client, req *v3.Request[T]) *v3.Request[T] {
+	if !c.modelDefaultMaxTokens || req.MaxTokens != 0 {
+		return req
+	}
+
+	var cp = *req
+	cp.MaxTokens = defaultMaxTokensFor(req.Model, append(c.betas(), req.Betas...))
+
+	return &cp
+}
+
+// defaultMaxTokensFor returns defaultMaxTokens, or the maximum output tokens of |model| when |betas| are sent if that's
+// lower.
+func defaultMaxTokensFor(model v3.Model, betas []string) int {
+	if limit := model.MaxOutputTokens(betas); limit > 0 && limit < defaultMaxTokens {
+		return limit
+	}
+
+	return defaultMaxTokens
+}
+
 type streamingMessageRequest[T v3.RequestMessage] struct {
 	*v3.Request[T]
 	Stream bool `json:"stream"`
 }
 
-type v3Event struct {
-	Type         string             `json:"type"`
-	Index        int                `json:"index"`
-	Delta        *v3.MessageContent `json:"delta,omitempty"`
-	ContentBlock *v3.MessageContent `json:"content_block,omitempty"`
+// MarshalJSON implements the json.Marshaler interface. It's required because v3.Request's MarshalJSON method is
+// promoted, and would otherwise drop the "stream" field.
+func (r streamingMessageRequest[T]) MarshalJSON() ([]byte, error) {
+	return marshalObject(r.Request, map[string]any{"stream": r.Stream})
 }
 
 // NewMessageRequest makes a request to the messages endpoint.
@@ -130,20 +332,36 @@ func (c *Client) NewMessageRequest(ctx context.Context, req *v3.Request[v3.Messa
 		}
 	}
 
-	var b, err = c.post(ctx, messagesEndpoint, req)
-	if err != nil {
+	req = withDefaultMaxTokens(c, req)
+	if err := c.checkMaxTokens(req.Model, req.MaxTokens, req.Betas, false); err != nil {
 		return nil, err
 	}
 
-	var resp = &v3.Response{}
-	if err = json.Unmarshal(b, resp); err != nil {
+	ctx = c.withRetryBudget(ctx)
+	var b, ids, err = c.post(ctx, messagesEndpoint, req, betaHeader(req.Betas))
+	if fallback := fallbackRequest(ctx, c, req, err); fallback != nil {
+		return c.NewMessageRequest(ctx, fallback)
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return resp, nil
+	return c.decodeMessage(b, ids)
 }
 
-func (c *Client) NewStreamingMessageRequest(ctx context.Context, req *v3.Request[v3.Message]) (*v3.Response, <-chan string, <-chan error, error) {
+// NewStreamingMessageRequest makes a streaming request to the messages endpoint. It returns the response, which is
+// populated as events are received, a channel which is sent the text of the response as it is generated, and a channel
+// which is sent any error encountered while receiving / parsing events. Only the text of "text" blocks is sent on the
+// text channel; thinking and tool input are only available on the response. |opts| configure optional behavior of the
+// stream (e.g. WithStreamStats). Callers which stop receiving before the channels are closed should cancel |ctx| to
+// release the stream.
+//
+// If the stream fails partway through, the response is left as it was when the error occurred, and isn't modified
+// once the error has been received: it holds every content block started so far, each with the deltas received for
+// it, so the partial answer can be salvaged. The input of a tool_use block is only assembled once the block is
+// complete, so an interrupted tool_use block's Input is still its (empty) initial value. StopReason is
+// v3.StopReasonUnknown unless the final message_delta event was received.
+func (c *Client) NewStreamingMessageRequest(ctx context.Context, req *v3.Request[v3.Message], opts ...StreamOption) (*v3.Response, <-chan string, <-chan error, error) {
 	if c.debug {
 		for i, m := range req.Messages {
 			for _, cont := range m.Content {
@@ -152,199 +370,171 @@ func (c *Client) NewStreamingMessageRequest(ctx context.Context, req *v3.Request
 		}
 	}
 
-	var receive, errs, err = c.postStream(ctx, messagesEndpoint, &streamingMessageRequest[v3.Message]{
+	req = withDefaultMaxTokens(c, req)
+	if err := c.checkMaxTokens(req.Model, req.MaxTokens, req.Betas, true); err != nil {
+		return nil, nil, nil, err
+	}
+
+	ctx = c.withRetryBudget(ctx)
+	var resp, text, errs, err = c.streamMessages(ctx, req.Betas, &streamingMessageRequest[v3.Message]{
 		Request: req,
 		Stream:  true,
+	}, opts)
+	if fallback := fallbackRequest(ctx, c, req, err); fallback != nil {
+		return c.NewStreamingMessageRequest(ctx, fallback, opts...)
+	}
+
+	return resp, text, errs, err
+}
+
+// NewStreamingMessageBlocks makes a streaming request to the messages endpoint, like NewStreamingMessageRequest, but
+// rather than sending text as it's generated, it sends each content block once it's complete: text joined, tool
+// input set, and thinking blocks signed. This suits UIs which render whole blocks. The block channel is closed once
+// the stream ends; any error is sent on the error channel, so callers should receive from both.
+func (c *Client) NewStreamingMessageBlocks(ctx context.Context, req *v3.Request[v3.Message], opts ...StreamOption) (*v3.Response, <-chan *v3.MessageContent, <-chan error, error) {
+	var blocks = make(chan *v3.MessageContent)
+	opts = append(opts[:len(opts):len(opts)], func(cfg *streamConfig) {
+		cfg.onBlock = func(_ int, block *v3.MessageContent) {
+			send(ctx, blocks, block)
+		}
 	})
+
+	var resp, text, errs, err = c.NewStreamingMessageRequest(ctx, req, opts...)
 	if err != nil {
 		return nil, nil, nil, err
 	}
-	var respCh = make(chan string)
-	var errCh = make(chan error)
-
-	var resp = &v3.Response{}
 
 	go func() {
-		defer close(respCh)
-		defer close(errCh)
+		// Blocks are sent from the same goroutine as the text, so once the text channel is closed no more blocks will
+		// be sent.
+		for range text {
+		}
+		close(blocks)
+	}()
 
-		for {
-			select {
-			case b := <-receive:
-				var events []*event
-				events, err = parseEvents(b)
-				if err != nil {
-					errCh <- err
-					return
-				}
+	return resp, blocks, errs, nil
+}
 
-				for _, e := range events {
-					switch e.Type {
-					case eventTypeMessageStart:
-						if err = json.Unmarshal(e.Data, resp); err != nil {
-							errCh <- err
-							return
-						}
-					case eventTypeMessageDelta:
-						var delta = &v3.Response{}
-						if err = json.Unmarshal(e.Data, delta); err != nil {
-							errCh <- err
-							return
-						}
+// NewStreamingMessageBlockUpdates makes a streaming request to the messages endpoint, like NewStreamingMessageRequest,
+// but rather than sending text as it's generated, it sends an update each time a content block is started, changed,
+// or completed. Each update holds a snapshot of every block so far, keyed by index, so UIs rendering several blocks at
+// once (e.g. text being typed alongside parallel tool calls being assembled) can redraw from the latest update alone.
+// The update channel is closed once the stream ends; any error is sent on the error channel, so callers should receive
+// from both.
+func (c *Client) NewStreamingMessageBlockUpdates(ctx context.Context, req *v3.Request[v3.Message], opts ...StreamOption) (*v3.Response, <-chan *BlockUpdate, <-chan error, error) {
+	var updates = make(chan *BlockUpdate)
+	var blocks = make(map[int]*v3.MessageContent)
+	opts = append(opts[:len(opts):len(opts)], func(cfg *streamConfig) {
+		cfg.onChange = func(index int, block *v3.MessageContent, partialInput string, done bool) {
+			// Only the changed block is copied; the snapshots of the others are shared with the previous update.
+			var snapshot = make(map[int]*v3.MessageContent, len(blocks)+1)
+			for i, b := range blocks {
+				snapshot[i] = b
+			}
+			snapshot[index] = block.Clone()
+			blocks = snapshot
 
-						resp.StopReason = delta.StopReason
-						resp.StopSequence = delta.StopSequence
-						resp.Usage = delta.Usage
-					case eventTypeMessageStop:
-						return
-					case eventTypeContentBlockStart:
-						var block = &v3Event{}
-						if err = json.Unmarshal(e.Data, block); err != nil {
-							errCh <- err
-							return
-						}
+			send(ctx, updates, &BlockUpdate{Index: index, Blocks: snapshot, PartialInput: partialInput, Done: done})
+		}
+	})
 
-						resp.Content = append(resp.Content, block.ContentBlock)
-						respCh <- block.ContentBlock.Text
-					case eventTypeContentBlockDelta:
-						var delta = &v3Event{}
-						if err = json.Unmarshal(e.Data, delta); err != nil {
-							errCh <- err
-							return
-						}
+	var resp, text, errs, err = c.NewStreamingMessageRequest(ctx, req, opts...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
 
-						resp.Content[len(resp.Content)-1].Text += delta.Delta.Text
-						respCh <- delta.Delta.Text
-					case eventTypeContentBlockStop:
-						continue
-					case eventTypeError:
-						var errResp = &ResponseError{}
-						if err = json.Unmarshal(e.Data, errResp); err != nil {
-							errCh <- errors.New(string(e.Data))
-							return
-						}
+	go func() {
+		// Updates are sent from the same goroutine as the text, so once the text channel is closed no more updates
+		// will be sent.
+		for range text {
+		}
+		close(updates)
+	}()
 
-						errCh <- errResp
-						return
-					case eventTypePing:
-						// Do nothing.
-					default:
-						errCh <- ErrBadEvent
-						return
-					}
+	return resp, updates, errs, nil
+}
+
+// NewStreamingToolInputs makes a streaming request to the messages endpoint, like NewStreamingMessageRequest, but
+// rather than sending text as it's generated, it sends a ToolInputStream as each tool_use (or server_tool_use) block
+// starts, whose Reader reads the block's input JSON as its fragments arrive. This lets callers decode large tool
+// inputs incrementally (e.g. with a json.Decoder, to render a forced tool's structured output as it's generated)
+// rather than waiting for the block to complete. The input channel is closed once the stream ends; any error is sent
+// on the error channel, so callers should receive from both. The stream doesn't wait for the readers, which needn't
+// be read to the end.
+func (c *Client) NewStreamingToolInputs(ctx context.Context, req *v3.Request[v3.Message], opts ...StreamOption) (*v3.Response, <-chan *ToolInputStream, <-chan error, error) {
+	var inputs = make(chan *ToolInputStream)
+	var open = make(map[int]*toolInputBuffer)
+	// written is the length of the input written to each open buffer so far.
+	var written = make(map[int]int)
+	opts = append(opts[:len(opts):len(opts)], func(cfg *streamConfig) {
+		cfg.onChange = func(index int, block *v3.MessageContent, partialInput string, done bool) {
+			var buf, ok = open[index]
+			if !ok {
+				if block.Type != "tool_use" && block.Type != "server_tool_use" {
+					return
 				}
-			case err = <-errs:
-				errCh <- err
-				return
-			case <-ctx.Done():
-				errCh <- ctx.Err()
+				buf = newToolInputBuffer()
+				open[index] = buf
+				send(ctx, inputs, &ToolInputStream{Index: index, ID: block.ID, Name: block.Name, Reader: buf})
+			}
+
+			if done {
+				// A block without input deltas keeps the input it started with.
+				if written[index] == 0 && len(block.Input) > 0 {
+					buf.write(string(block.Input))
+				}
+				buf.close(io.EOF)
+				delete(open, index)
 				return
 			}
+			buf.write(partialInput[written[index]:])
+			written[index] = len(partialInput)
+		}
+	})
+
+	var resp, text, errs, err = c.NewStreamingMessageRequest(ctx, req, opts...)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	go func() {
+		// Inputs are sent from the same goroutine as the text, so once the text channel is closed no more inputs will
+		// be sent, and the buffers which are still open never will be completed.
+		for range text {
 		}
+		for _, buf := range open {
+			buf.close(io.ErrUnexpectedEOF)
+		}
+		close(inputs)
 	}()
 
-	return resp, respCh, errCh, nil
+	return resp, inputs, errs, nil
 }
 
-func (c *Client) NewStreamingShortHandMessageRequest(ctx context.Context, req *v3.Request[v3.ShortHandMessage]) (*v3.Response, <-chan string, <-chan error, error) {
+// NewStreamingShortHandMessageRequest makes a streaming request to the messages endpoint. See
+// NewStreamingMessageRequest for details on the returned values.
+func (c *Client) NewStreamingShortHandMessageRequest(ctx context.Context, req *v3.Request[v3.ShortHandMessage], opts ...StreamOption) (*v3.Response, <-chan string, <-chan error, error) {
 	if c.debug {
 		for i, m := range req.Messages {
 			slog.Info("message", "index", i, "role", m.Role, "content", m.Content)
 		}
 	}
 
-	var receive, errs, err = c.postStream(ctx, messagesEndpoint, &streamingMessageRequest[v3.ShortHandMessage]{
-		Request: req,
-		Stream:  true,
-	})
-	if err != nil {
+	req = withDefaultMaxTokens(c, req)
+	if err := c.checkMaxTokens(req.Model, req.MaxTokens, req.Betas, true); err != nil {
 		return nil, nil, nil, err
 	}
-	var respCh = make(chan string)
-	var errCh = make(chan error)
-
-	var resp = &v3.Response{}
-
-	go func() {
-		defer close(respCh)
-		defer close(errCh)
 
-		for {
-			select {
-			case b := <-receive:
-				var events []*event
-				events, err = parseEvents(b)
-				if err != nil {
-					errCh <- err
-					return
-				}
-
-				for _, e := range events {
-					switch e.Type {
-					case eventTypeMessageStart:
-						if err = json.Unmarshal(e.Data, resp); err != nil {
-							errCh <- err
-							return
-						}
-					case eventTypeMessageDelta:
-						var delta = &v3.Response{}
-						if err = json.Unmarshal(e.Data, delta); err != nil {
-							errCh <- err
-							return
-						}
-
-						resp.StopReason = delta.StopReason
-						resp.StopSequence = delta.StopSequence
-						resp.Usage = delta.Usage
-					case eventTypeMessageStop:
-						return
-					case eventTypeContentBlockStart:
-						var block = &v3Event{}
-						if err = json.Unmarshal(e.Data, block); err != nil {
-							errCh <- err
-							return
-						}
-
-						resp.Content = append(resp.Content, block.ContentBlock)
-						respCh <- block.ContentBlock.Text
-					case eventTypeContentBlockDelta:
-						var delta = &v3Event{}
-						if err = json.Unmarshal(e.Data, delta); err != nil {
-							errCh <- err
-							return
-						}
-
-						resp.Content[len(resp.Content)-1].Text += delta.Delta.Text
-						respCh <- delta.Delta.Text
-					case eventTypeContentBlockStop:
-						continue
-					case eventTypeError:
-						var errResp = &ResponseError{}
-						if err = json.Unmarshal(e.Data, errResp); err != nil {
-							errCh <- errors.New(string(e.Data))
-							return
-						}
-
-						errCh <- errResp
-						return
-					case eventTypePing:
-						// Do nothing.
-					default:
-						errCh <- ErrBadEvent
-						return
-					}
-				}
-			case err = <-errs:
-				errCh <- err
-				return
-			case <-ctx.Done():
-				errCh <- ctx.Err()
-				return
-			}
-		}
-	}()
+	ctx = c.withRetryBudget(ctx)
+	var resp, text, errs, err = c.streamMessages(ctx, req.Betas, &streamingMessageRequest[v3.ShortHandMessage]{
+		Request: req,
+		Stream:  true,
+	}, opts)
+	if fallback := fallbackRequest(ctx, c, req, err); fallback != nil {
+		return c.NewStreamingShortHandMessageRequest(ctx, fallback, opts...)
+	}
 
-	return resp, respCh, errCh, nil
+	return resp, text, errs, err
 }
 
 // NewShortHandMessageRequest makes a request to the messages endpoint.
@@ -355,19 +545,63 @@ func (c *Client) NewShortHandMessageRequest(ctx context.Context, req *v3.Request
 		}
 	}
 
-	var b, err = c.post(ctx, messagesEndpoint, req)
+	req = withDefaultMaxTokens(c, req)
+	if err := c.checkMaxTokens(req.Model, req.MaxTokens, req.Betas, false); err != nil {
+		return nil, err
+	}
+
+	ctx = c.withRetryBudget(ctx)
+	var b, ids, err = c.post(ctx, messagesEndpoint, req, betaHeader(req.Betas))
+	if fallback := fallbackRequest(ctx, c, req, err); fallback != nil {
+		return c.NewShortHandMessageRequest(ctx, fallback)
+	}
 	if err != nil {
 		return nil, err
 	}
 
+	return c.decodeMessage(b, ids)
+}
+
+// RawMessageRequest makes a request to the messages endpoint with the pre-serialized JSON |body|, bypassing the typed
+// request (e.g. to replay a captured request or pass through a body built elsewhere). The body is sent as is: no
+// validation or max tokens check is performed, but the client's auth, version, and beta headers are still applied.
+func (c *Client) RawMessageRequest(ctx context.Context, body []byte) (*v3.Response, error) {
+	var b, ids, err = c.postRaw(ctx, messagesEndpoint, body, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.decodeMessage(b, ids)
+}
+
+// ErrUnexpectedRole is returned in strict mode (see WithStrictDecoding) when a response's role isn't "assistant".
+var ErrUnexpectedRole = errors.New("unexpected response role")
+
+// decodeMessage decodes the messages endpoint response |b| to the request identified by |ids|.
+func (c *Client) decodeMessage(b []byte, ids requestIDs) (*v3.Response, error) {
 	var resp = &v3.Response{}
-	if err = json.Unmarshal(b, resp); err != nil {
+	if err := json.Unmarshal(b, resp); err != nil {
+		return nil, err
+	}
+	ids.setOn(resp)
+	if err := c.checkResponse(resp); err != nil {
 		return nil, err
 	}
 
 	return resp, nil
 }
 
+// checkResponse returns an error wrapping ErrUnexpectedRole if the client is in strict mode and |resp|'s role isn't
+// "assistant". Appending such a response to a conversation's history would silently break its role alternation.
+func (c *Client) checkResponse(resp *v3.Response) error {
+	if !c.strict || resp.Role == v3.RoleAssistant {
+		return nil
+	}
+	slog.Warn("unexpected anthropic response role", "role", resp.Role, "id", resp.ID)
+
+	return fmt.Errorf("%w: %q", ErrUnexpectedRole, resp.Role)
+}
+
 // NewCompletionStreamedBatchResponse returns a completion response from the API, which appears to the caller
 // as a non-streaming response. However, it is actually a streaming response under the hood. This is useful
 // in cases where you are getting a 524 error from the API, which is caused by the API taking too long to
@@ -405,27 +639,64 @@ func (c *Client) NewCompletionStreamedBatchResponse(ctx context.Context, req *Re
 	return resp, nil
 }
 
+// NewMessageStreamedBatchResponse returns a response from the messages endpoint, which appears to the caller as a
+// non-streaming response. However, it is actually a streaming response under the hood (see
+// NewCompletionStreamedBatchResponse for why this is useful). The response is fully reconstructed from the stream's
+// events, including usage and tool_use blocks with their complete input. Use WithResponseValidation to check that the
+// reconstructed response is internally consistent before it's returned.
+func (c *Client) NewMessageStreamedBatchResponse(ctx context.Context, req *v3.Request[v3.Message], opts ...StreamOption) (*v3.Response, error) {
+	var resp, texts, errs, err = c.NewStreamingMessageRequest(ctx, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	for texts != nil || errs != nil {
+		select {
+		case _, ok := <-texts:
+			if !ok {
+				texts = nil
+			}
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			var se *StreamError
+			if errors.As(err, &se) && !se.IsFatal() {
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return resp, nil
+}
+
 type streamingRequest struct {
 	*Request
 	Stream bool `json:"stream"`
 }
 
 // NewStreamingCompletion returns two channels: the first will be sent |*Response|s as they are received from
-// the API and the second is sent any error(s) encountered while receiving / parsing responses.
+// the API and the second is sent any error(s) encountered while receiving / parsing responses. Callers which stop
+// receiving before the channels are closed should cancel |ctx| to release the stream.
 func (c *Client) NewStreamingCompletion(ctx context.Context, req *Request) (<-chan *Response, <-chan error, error) {
 	if c.debug {
 		log.Printf("prompt: %s\n", req.Prompt)
 	}
 
-	var receive, errs, err = c.postStream(ctx, completionEndpoint, &streamingRequest{
+	var receive, errs, _, err = c.postStream(ctx, completionEndpoint, &streamingRequest{
 		Request: req,
 		Stream:  true,
-	})
+	}, nil)
 	if err != nil {
 		return nil, nil, err
 	}
 	var respCh = make(chan *Response)
-	var errCh = make(chan error)
+	// At most one error is sent, so buffering it means the goroutine never blocks on it.
+	var errCh = make(chan error, 1)
 
 	go func() {
 		defer close(respCh)
@@ -433,7 +704,11 @@ func (c *Client) NewStreamingCompletion(ctx context.Context, req *Request) (<-ch
 
 		for {
 			select {
-			case b := <-receive:
+			case b, ok := <-receive:
+				if !ok {
+					return
+				}
+
 				var events []*event
 				events, err = parseEvents(b)
 				if err != nil {
@@ -451,29 +726,30 @@ func (c *Client) NewStreamingCompletion(ctx context.Context, req *Request) (<-ch
 							return
 						}
 
-						respCh <- resp
+						if !send(ctx, respCh, resp) {
+							errCh <- ctx.Err()
+							return
+						}
 
 						if resp.StopReason != nil {
 							return
 						}
 					case eventTypeError:
-						var errResp = &ResponseError{}
-						if err = json.Unmarshal(e.Data, errResp); err != nil {
-							errCh <- errors.New(string(e.Data))
-							return
-						}
-
-						errCh <- errResp
+						errCh <- streamedError(e.Data)
 						return
 					case eventTypePing:
 						// Do nothing.
 					default:
-						errCh <- ErrBadEvent
-						return
-
+						// The API adds new event types from time to time; ignore them rather than end the stream.
+						slog.Debug("ignoring unknown stream event", "type", e.Type)
 					}
 				}
-			case err = <-errs:
+			case err, ok := <-errs:
+				if !ok {
+					// Wait for |receive| to be closed.
+					errs = nil
+					continue
+				}
 				errCh <- err
 				return
 			case <-ctx.Done():
@@ -486,8 +762,6 @@ func (c *Client) NewStreamingCompletion(ctx context.Context, req *Request) (<-ch
 	return respCh, errCh, nil
 }
 
-var re = regexp.MustCompile("event: (.*?)\ndata: (.*?)\n")
-
 type eventType string
 
 const (
@@ -510,110 +784,202 @@ const (
 // ErrBadEvent is returned when an event is received that cannot be parsed.
 var ErrBadEvent = errors.New("bad event")
 
+// event is a single server-sent event.
 type event struct {
+	// Type is the value of the event's "event" field.
 	Type eventType
+	// Data is the value of the event's "data" field(s). Multiple data lines are joined with newlines.
 	Data []byte
+	// ID is the value of the event's "id" field, if any. It can be sent in the |Last-Event-ID| header to resume a
+	// stream from this event (if the server supports it).
+	ID string
+	// Retry is the reconnection time requested by the event's "retry" field, if any.
+	Retry time.Duration
 }
 
+// parseEvents parses the server-sent events in |b|, which must contain only complete events. Events are separated by
+// a blank line and consist of "field: value" lines; comment lines (starting with ":") are ignored.
 func parseEvents(b []byte) ([]*event, error) {
 	var out []*event
 
-	var matches = re.FindAllSubmatch(b, -1)
-	for _, group := range matches {
-		if len(group) != 3 {
-			return nil, ErrBadEvent
-		}
+	var ev = &event{}
+	var data [][]byte
+	var hasFields bool
 
-		var ev = &event{
-			Type: eventType(strings.TrimSpace(string(group[1]))),
-			Data: group[2],
+	var dispatch = func() error {
+		if !hasFields {
+			return nil
+		}
+		if ev.Type == "" {
+			return ErrBadEvent
 		}
+
+		ev.Data = bytes.Join(data, []byte("\n"))
 		out = append(out, ev)
+
+		ev, data, hasFields = &event{}, nil, false
+		return nil
+	}
+
+	for _, line := range bytes.Split(b, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			if err := dispatch(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		var field, value, _ = bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+
+		switch string(field) {
+		case "event":
+			ev.Type = eventType(strings.TrimSpace(string(value)))
+		case "data":
+			data = append(data, value)
+		case "id":
+			ev.ID = string(value)
+		case "retry":
+			if ms, err := strconv.Atoi(string(value)); err == nil {
+				ev.Retry = time.Duration(ms) * time.Millisecond
+			}
+		default:
+			// Unknown fields are ignored, per the SSE spec.
+		}
+		hasFields = true
+	}
+
+	if err := dispatch(); err != nil {
+		return nil, err
 	}
 
 	return out, nil
 }
 
-func (c *Client) post(ctx context.Context, path string, payload any) ([]byte, error) {
-	var b, err = json.Marshal(payload)
+func (c *Client) post(ctx context.Context, path string, payload any, header http.Header) ([]byte, requestIDs, error) {
+	var b, err = v3.Marshal(payload)
 	if err != nil {
-		return nil, err
+		return nil, requestIDs{}, err
 	}
 
-	var u = url.URL{
-		Scheme: "https",
-		Host:   host,
-		Path:   path,
-	}
+	return c.postRaw(ctx, path, b, header)
+}
 
-	var req *http.Request
-	req, err = c.newRequest(ctx, "POST", u.String(), bytes.NewBuffer(b))
+// postRaw makes a request with the already-serialized body |b| and returns the response body and the request's ids.
+func (c *Client) postRaw(ctx context.Context, path string, b []byte, header http.Header) ([]byte, requestIDs, error) {
+	var cancel context.CancelFunc
+	ctx, cancel = c.bind(ctx)
+	defer cancel()
+
+	var req, err = c.newRequest(ctx, "POST", c.endpoint(path), bytes.NewBuffer(b))
 	if err != nil {
-		return nil, err
+		return nil, requestIDs{}, err
 	}
+	addHeader(req, header)
 
 	var resp *http.Response
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = c.do(req, b)
 	if err != nil {
-		return nil, err
+		return nil, requestIDs{}, err
 	}
 	defer resp.Body.Close()
 
-	if err = interpretResponse(resp); err != nil {
-		return nil, err
+	if err = c.interpretResponse(resp); err != nil {
+		return nil, requestIDs{}, err
 	}
 
-	return io.ReadAll(resp.Body)
-}
+	var ids = c.requestIDs(resp)
+	if b, err = c.readBody(resp.Body); err != nil {
+		return nil, ids, err
+	}
 
-const bufferSize = 1024
+	return b, ids, nil
+}
 
-func (c *Client) postStream(ctx context.Context, path string, payload any) (<-chan []byte, <-chan error, error) {
-	var b, err = json.Marshal(payload)
+// postStream makes a streaming request and returns a channel which is sent each complete server-sent event (including
+// its trailing blank line) as it is received, and the request's ids. |header| is added to the request's headers.
+func (c *Client) postStream(ctx context.Context, path string, payload any, header http.Header) (<-chan []byte, <-chan error, requestIDs, error) {
+	var b, err = v3.Marshal(payload)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, requestIDs{}, err
 	}
 
-	var u = url.URL{
-		Scheme: "https",
-		Host:   host,
-		Path:   path,
-	}
+	var streamCtx, cancel = c.bind(ctx)
+	var timeout = newStreamTimeout(c.streamConnectTimeout, c.streamIdleTimeout, cancel)
 
 	var req *http.Request
-	req, err = c.newRequest(ctx, "POST", u.String(), bytes.NewBuffer(b))
+	req, err = c.newRequest(streamCtx, "POST", c.endpoint(path), bytes.NewBuffer(b))
 	if err != nil {
-		return nil, nil, err
+		timeout.stop()
+		cancel()
+		return nil, nil, requestIDs{}, err
 	}
 	req.Header.Set("Content-Type", "application/json; charset=utf-8")
 	req.Header.Set("Accept", "text/event-stream; charset=utf-8")
 	req.Header.Set("Connection", "keep-alive")
 	req.Header.Set("Cache-Control", "no-cache")
+	for k, v := range c.streamHeaders {
+		if len(v) == 0 {
+			req.Header.Del(k)
+			continue
+		}
+		req.Header[http.CanonicalHeaderKey(k)] = v
+	}
+	addHeader(req, header)
 
 	var resp *http.Response
-	resp, err = http.DefaultClient.Do(req)
+	resp, err = c.do(req, b)
 	if err != nil {
-		return nil, nil, err
+		timeout.stop()
+		cancel()
+		if terr := timeout.err(); terr != nil {
+			return nil, nil, requestIDs{}, terr
+		}
+		return nil, nil, requestIDs{}, err
 	}
-	if err = interpretResponse(resp); err != nil {
+	if err = c.interpretResponse(resp); err != nil {
+		timeout.stop()
+		cancel()
 		_ = resp.Body.Close()
-		return nil, nil, err
+		return nil, nil, requestIDs{}, err
 	}
 
 	var events = make(chan []byte)
-	var errCh = make(chan error)
+	// At most one error is sent, so buffering it means the goroutine never blocks on it.
+	var errCh = make(chan error, 1)
 
 	go func() {
+		defer cancel()
+		defer timeout.stop()
 		defer resp.Body.Close()
 		defer close(events)
 		defer close(errCh)
 
+		var r = bufio.NewReader(resp.Body)
+		var buf []byte
+		var total int64
 		for {
-			var msg = make([]byte, bufferSize)
-			_, err = resp.Body.Read(msg)
+			var line, err = r.ReadBytes('\n')
+			buf = append(buf, line...)
+			total += int64(len(line))
+			if terr := timeout.err(); terr != nil {
+				errCh <- terr
+				return
+			}
+			timeout.received()
 
 			switch {
+			case c.maxResponseBytes > 0 && total > c.maxResponseBytes:
+				errCh <- ErrResponseTooLarge
+				return
 			case errors.Is(err, io.EOF):
+				if len(bytes.TrimSpace(buf)) > 0 {
+					send(ctx, events, buf)
+				}
 				return
 			case err != nil:
 				errCh <- err
@@ -625,11 +991,161 @@ func (c *Client) postStream(ctx context.Context, path string, payload any) (<-ch
 				// No-op.
 			}
 
-			events <- msg
+			// A blank line terminates an event.
+			if len(bytes.TrimRight(line, "\r\n")) == 0 && len(bytes.TrimSpace(buf)) > 0 {
+				if !send(ctx, events, buf) {
+					return
+				}
+				buf = nil
+			}
 		}
 	}()
 
-	return events, errCh, nil
+	return events, errCh, c.requestIDs(resp), nil
+}
+
+var (
+	// ErrStreamConnectTimeout is returned when a stream doesn't start within the timeout set by
+	// WithStreamConnectTimeout.
+	ErrStreamConnectTimeout = errors.New("timed out waiting for stream to start")
+	// ErrStreamIdleTimeout is returned when no data is received on a stream within the timeout set by
+	// WithStreamIdleTimeout.
+	ErrStreamIdleTimeout = errors.New("timed out waiting for stream data")
+)
+
+// streamTimeout enforces the connect and idle timeouts of a stream by canceling its context when one expires.
+type streamTimeout struct {
+	mu      sync.Mutex
+	timer   *time.Timer
+	idle    time.Duration
+	cancel  context.CancelFunc
+	started bool
+	fired   error
+}
+
+// newStreamTimeout returns a streamTimeout which calls |cancel| if no data is received within |connect| of now, or
+// within |idle| of the previously received data. A zero duration disables the corresponding timeout.
+func newStreamTimeout(connect, idle time.Duration, cancel context.CancelFunc) *streamTimeout {
+	var t = &streamTimeout{idle: idle, cancel: cancel}
+	if connect > 0 {
+		t.timer = time.AfterFunc(connect, t.fire)
+	}
+
+	return t
+}
+
+// fire records which timeout expired and cancels the stream.
+func (t *streamTimeout) fire() {
+	t.mu.Lock()
+	if t.started {
+		t.fired = ErrStreamIdleTimeout
+	} else {
+		t.fired = ErrStreamConnectTimeout
+	}
+	t.mu.Unlock()
+
+	t.cancel()
+}
+
+// received records that data was received, switching from the connect timeout to the idle timeout.
+func (t *streamTimeout) received() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.started = true
+	switch {
+	case t.idle > 0 && t.timer == nil:
+		t.timer = time.AfterFunc(t.idle, t.fire)
+	case t.idle > 0:
+		t.timer.Reset(t.idle)
+	case t.timer != nil:
+		t.timer.Stop()
+	}
+}
+
+// stop disables the timeouts.
+func (t *streamTimeout) stop() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+}
+
+// err returns the error for the timeout that expired, if any.
+func (t *streamTimeout) err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.fired
+}
+
+// do sends |req|, whose body is |body|, writing both it and the response to the request dump if one is set. Failed
+// attempts are retried as configured by WithMaxRetries.
+func (c *Client) do(req *http.Request, body []byte) (*http.Response, error) {
+	var retries = c.maxRetries
+	var budget, _ = req.Context().Value(retryBudgetKey{}).(*int)
+	if budget != nil {
+		retries = *budget
+	}
+
+	for attempt := 0; ; attempt++ {
+		var r = req
+		if attempt > 0 {
+			r = req.Clone(req.Context())
+			if body != nil {
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+		}
+
+		if c.dump != nil {
+			c.dump.request(r, body)
+		}
+
+		var resp, err = c.client().Do(r)
+		if attempt < retries && req.Context().Err() == nil && shouldRetry(resp, err) {
+			if budget != nil {
+				*budget--
+			}
+			var wait = retryDelay(attempt, resp)
+			if resp != nil {
+				_, _ = io.Copy(io.Discard, resp.Body)
+				_ = resp.Body.Close()
+			}
+
+			var t = time.NewTimer(wait)
+			select {
+			case <-t.C:
+				continue
+			case <-req.Context().Done():
+				t.Stop()
+				return nil, req.Context().Err()
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+		if resp.Request == nil {
+			resp.Request = r
+		}
+
+		if c.dump != nil {
+			c.dump.response(resp)
+		}
+
+		return resp, nil
+	}
+}
+
+// endpoint returns the URL of the API endpoint |path|.
+func (c *Client) endpoint(path string) string {
+	var base = c.baseURL
+	if base == "" {
+		base = defaultBaseURL
+	}
+
+	return strings.TrimRight(base, "/") + "/" + strings.TrimLeft(path, "/")
 }
 
 func (c *Client) newRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
@@ -640,6 +1156,9 @@ func (c *Client) newRequest(ctx context.Context, method string, url string, body
 
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set(apiKeyHeader, c.key)
+	if c.requestIDHeader != "" {
+		req.Header.Set(c.requestIDHeader, newRequestID())
+	}
 
 	if c.requestHeaders != nil {
 		for k, v := range c.requestHeaders {
@@ -650,20 +1169,54 @@ func (c *Client) newRequest(ctx context.Context, method string, url string, body
 	return req, nil
 }
 
-func interpretResponse(resp *http.Response) error {
+// ErrPayloadTooLarge is returned when the API rejects a request because it's too large (HTTP 413), e.g. because of a
+// large document or a long conversation. Retrying won't help; trim the request's content or upload large files via
+// the Files API instead.
+var ErrPayloadTooLarge = errors.New("request payload too large")
+
+// ErrResponseTooLarge is returned when a response body exceeds the limit set by WithMaxResponseBytes.
+var ErrResponseTooLarge = errors.New("response body exceeds maximum size")
+
+// readBody reads all of |r|, returning ErrResponseTooLarge if it exceeds the client's maximum response size.
+func (c *Client) readBody(r io.Reader) ([]byte, error) {
+	if c.maxResponseBytes <= 0 {
+		return io.ReadAll(r)
+	}
+
+	var b, err = io.ReadAll(io.LimitReader(r, c.maxResponseBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(b)) > c.maxResponseBytes {
+		return nil, ErrResponseTooLarge
+	}
+
+	return b, nil
+}
+
+func (c *Client) interpretResponse(resp *http.Response) error {
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusBadRequest {
-		var b, err = io.ReadAll(resp.Body)
+		var b, err = c.readBody(resp.Body)
 		if err != nil {
-			return fmt.Errorf("code: %d, unable to read response body", resp.StatusCode)
+			return fmt.Errorf("code: %d, unable to read response body: %w", resp.StatusCode, err)
 
 		}
 
 		var errResp = &ResponseError{}
 		if err = json.Unmarshal(b, errResp); err == nil {
 			errResp.Err.Code = resp.StatusCode
+			c.requestIDs(resp).annotate(errResp)
+			if resp.StatusCode == http.StatusRequestEntityTooLarge {
+				return fmt.Errorf("%w: %w", ErrPayloadTooLarge, errResp)
+			}
 			return errResp
 		}
 
+		if resp.StatusCode == http.StatusRequestEntityTooLarge {
+			// The 413 may come from a proxy in front of the API, in which case the body isn't an API error.
+			return fmt.Errorf("%w: code: %d, error: %s", ErrPayloadTooLarge, resp.StatusCode, string(b))
+		}
+
 		return fmt.Errorf("code: %d, error: %s", resp.StatusCode, string(b))
 	}
 
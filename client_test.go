@@ -0,0 +1,613 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestParseEvents(t *testing.T) {
+	var in = []byte(": comment\n" +
+		"event: ping\ndata: {\"type\": \"ping\"}\n\n" +
+		"id: 42\nretry: 1500\nevent: content_block_delta\ndata: {\"a\":\ndata: 1}\r\n\r\n" +
+		"event: message_stop\ndata: {}\n")
+
+	var events, err = parseEvents(in)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = []*event{
+		{Type: eventTypePing, Data: []byte(`{"type": "ping"}`)},
+		{Type: eventTypeContentBlockDelta, Data: []byte("{\"a\":\n1}"), ID: "42", Retry: 1500 * time.Millisecond},
+		{Type: eventTypeMessageStop, Data: []byte(`{}`)},
+	}
+	if len(events) != len(exp) {
+		t.Fatalf("parseEvents() returned %d events, want %d", len(events), len(exp))
+	}
+	for i, e := range events {
+		if e.Type != exp[i].Type || string(e.Data) != string(exp[i].Data) || e.ID != exp[i].ID || e.Retry != exp[i].Retry {
+			t.Errorf("parseEvents()[%d] = %+v, want %+v", i, e, exp[i])
+		}
+	}
+}
+
+func TestParseEventsMissingType(t *testing.T) {
+	if _, err := parseEvents([]byte("data: {}\n\n")); err != ErrBadEvent {
+		t.Errorf("parseEvents() error = %v, wantErr %v", err, ErrBadEvent)
+	}
+}
+
+func TestRequestBodyNotHTMLEscaped(t *testing.T) {
+	var body string
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+		return newTestResponse(http.StatusOK, `{"id":"msg_1","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+	})}))
+
+	var _, err = c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{
+		Model:          v3.Claude3Haiku20240307,
+		SystemMessages: []*v3.SystemMessage{{Type: "text", Text: "<b>Be brief</b>"}},
+		Messages:       []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "<div>a & b</div>"}}}},
+		MaxTokens:      16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, want := range []string{"<div>a & b</div>", "<b>Be brief</b>"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("request body = %s, want it to contain %s", body, want)
+		}
+	}
+}
+
+func TestCheckMaxTokens(t *testing.T) {
+	var tests = []struct {
+		name      string
+		beta      bool
+		maxTokens int
+		streaming bool
+		err       error
+	}{
+		{name: "Non-Streaming Within Limit", maxTokens: 8192},
+		{name: "Non-Streaming Too Large", maxTokens: 32000, err: ErrStreamingRequired},
+		{name: "Non-Streaming Too Large With Beta", beta: true, maxTokens: 128000, err: ErrStreamingRequired},
+		{name: "Streaming With Beta", beta: true, maxTokens: 128000, streaming: true},
+		{name: "Streaming Without Beta", maxTokens: 128000, streaming: true, err: v3.ErrMaxTokensExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key")
+			if tt.beta {
+				c.SetBetaPromptCacheHeader()
+				c.SetBetaOutput128kHeader()
+			}
+
+			if err := c.checkMaxTokens(v3.Claude3Dot7Sonnet20250219, tt.maxTokens, nil, tt.streaming); !errors.Is(err, tt.err) {
+				t.Errorf("checkMaxTokens() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSetVersionWarnsOnUnknownVersion(t *testing.T) {
+	var buf bytes.Buffer
+	var prev = slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer slog.SetDefault(prev)
+
+	var c = NewClient("key")
+	c.SetVersion(Version20230101)
+	if buf.Len() != 0 {
+		t.Errorf("SetVersion(%q) logged %q, want nothing", Version20230101, buf.String())
+	}
+
+	c.SetVersion("2023-06-1")
+	if !strings.Contains(buf.String(), "unknown anthropic api version") {
+		t.Errorf("SetVersion() logged %q, want a warning", buf.String())
+	}
+	if got := c.requestHeaders.Get(apiVersionHeader); got != "2023-06-1" {
+		t.Errorf("%s = %q, want the version to be sent as-is", apiVersionHeader, got)
+	}
+}
+
+func TestRawMessageRequest(t *testing.T) {
+	var raw = []byte(`{"model":"claude-3-haiku-20240307","max_tokens":16,"messages":[{"role":"user","content":"Hi"}]}`)
+
+	var body []byte
+	var header http.Header
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var err error
+		if body, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+		header = r.Header
+		return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[{"type":"text","text":"Hello"}],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+	})}))
+
+	var resp, err = c.RawMessageRequest(context.Background(), raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(body, raw) {
+		t.Errorf("request body = %s, want %s", body, raw)
+	}
+	if got := header.Get(apiKeyHeader); got != "key" {
+		t.Errorf("%s = %q, want %q", apiKeyHeader, got, "key")
+	}
+	if got := header.Get(apiVersionHeader); got != string(defaultVersion) {
+		t.Errorf("%s = %q, want %q", apiVersionHeader, got, defaultVersion)
+	}
+	if got := resp.Text(); got != "Hello" {
+		t.Errorf("Text() = %q, want %q", got, "Hello")
+	}
+}
+
+func TestStrictDecodingRole(t *testing.T) {
+	var tests = []struct {
+		name   string
+		opts   []Option
+		role   string
+		expErr error
+	}{
+		{name: "assistant", opts: []Option{WithStrictDecoding()}, role: "assistant"},
+		{name: "user", opts: []Option{WithStrictDecoding()}, role: "user", expErr: ErrUnexpectedRole},
+		{name: "user, not strict", role: "user"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts = append(tt.opts, WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"`+tt.role+`","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+			})}))
+			var c = NewClient("key", opts...)
+
+			var _, err = c.RawMessageRequest(context.Background(), []byte(`{}`))
+			if !errors.Is(err, tt.expErr) {
+				t.Errorf("RawMessageRequest() error = %v, want %v", err, tt.expErr)
+			}
+		})
+	}
+}
+
+func TestStreamingMessageRequestBody(t *testing.T) {
+	var b, err = v3.Marshal(&streamingMessageRequest[v3.Message]{
+		Request: &v3.Request[v3.Message]{Model: v3.Claude3Haiku20240307, System: v3.Optional("Be brief"), MaxTokens: 16},
+		Stream:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	for k, want := range map[string]string{"stream": "true", "system": `"Be brief"`, "max_tokens": "16"} {
+		if got := string(fields[k]); got != want {
+			t.Errorf("%s = %s, want %s", k, got, want)
+		}
+	}
+}
+
+func TestWithMaxResponseBytes(t *testing.T) {
+	const message = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hi!"}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var tests = []struct {
+		name      string
+		body      string
+		streaming bool
+		limit     int64
+		expErr    error
+	}{
+		{name: "Body Over Limit", body: message, limit: int64(len(message)) - 1, expErr: ErrResponseTooLarge},
+		{name: "Body At Limit", body: message, limit: int64(len(message))},
+		{name: "Stream Over Limit", body: toolUseStream, streaming: true, limit: int64(len(toolUseStream)) - 1, expErr: ErrResponseTooLarge},
+		{name: "Stream At Limit", body: toolUseStream, streaming: true, limit: int64(len(toolUseStream))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key", WithMaxResponseBytes(tt.limit), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var resp = newTestResponse(http.StatusOK, tt.body)
+				if tt.streaming {
+					resp.Header.Set("Content-Type", "text/event-stream")
+				}
+				return resp, nil
+			})}))
+
+			var req = &v3.Request[v3.Message]{Model: v3.Claude3Haiku20240307, MaxTokens: 16}
+			var err error
+			if tt.streaming {
+				var texts <-chan string
+				var errs <-chan error
+				if _, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), req); err != nil {
+					t.Fatal(err)
+				}
+				for range texts {
+				}
+				err = <-errs
+			} else {
+				_, err = c.NewMessageRequest(context.Background(), req)
+			}
+			if !errors.Is(err, tt.expErr) {
+				t.Errorf("error = %v, want %v", err, tt.expErr)
+			}
+		})
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	var srv = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer srv.Close()
+
+	var pool = x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+
+	var tests = []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{name: "Default", wantErr: true},
+		{name: "Root CAs", opts: []Option{WithRootCAs(pool)}},
+		{name: "Insecure Skip Verify", opts: []Option{WithInsecureSkipVerify()}},
+		// The options must not leak into the default client or transport.
+		{name: "Default Again", wantErr: true},
+	}
+
+	var defaultTransport = http.DefaultTransport.(*http.Transport)
+	var defaultClientTransport = http.DefaultClient.Transport
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key", append(tt.opts, WithBaseURL(srv.URL))...)
+			if _, err := c.RawMessageRequest(context.Background(), []byte(`{}`)); (err != nil) != tt.wantErr {
+				t.Errorf("RawMessageRequest() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if http.DefaultTransport != defaultTransport || http.DefaultClient.Transport != defaultClientTransport {
+				t.Error("http.DefaultTransport or http.DefaultClient was replaced")
+			}
+			if tls := defaultTransport.TLSClientConfig; tls != nil && (tls.RootCAs != nil || tls.InsecureSkipVerify) {
+				t.Errorf("http.DefaultTransport's TLS config was modified: %+v", tls)
+			}
+		})
+	}
+}
+
+func TestSetWorkspace(t *testing.T) {
+	var got []string
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		got = append(got, r.Header.Get(workspaceHeader))
+		return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[]}`), nil
+	})}))
+
+	for _, id := range []string{"wrkspc_1", "wrkspc_2", ""} {
+		c.SetWorkspace(id)
+		if _, err := c.RawMessageRequest(context.Background(), []byte(`{}`)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if exp := []string{"wrkspc_1", "wrkspc_2", ""}; !reflect.DeepEqual(got, exp) {
+		t.Errorf("%s headers = %q, want %q", workspaceHeader, got, exp)
+	}
+	if _, ok := c.requestHeaders[http.CanonicalHeaderKey(workspaceHeader)]; ok {
+		t.Errorf("%s header wasn't removed", workspaceHeader)
+	}
+}
+
+func TestWithProxy(t *testing.T) {
+	var proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "api.example.com" {
+			t.Errorf("proxied host = %s, want api.example.com", r.URL.Host)
+		}
+		if got, want := r.Header.Get("Proxy-Authorization"), "Basic dXNlcjpwYXNz"; got != want {
+			t.Errorf("Proxy-Authorization = %q, want %q", got, want)
+		}
+		_, _ = io.WriteString(w, `{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`)
+	}))
+	defer proxy.Close()
+
+	var tests = []struct {
+		name   string
+		proxy  string
+		expErr error
+	}{
+		{name: "Valid", proxy: strings.Replace(proxy.URL, "http://", "http://user:pass@", 1)},
+		{name: "No Scheme", proxy: "proxy.example.com:3128", expErr: ErrInvalidProxyURL},
+		{name: "Bad Scheme", proxy: "ftp://proxy.example.com", expErr: ErrInvalidProxyURL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key", WithBaseURL("http://api.example.com"), WithProxy(tt.proxy))
+			if _, err := c.RawMessageRequest(context.Background(), []byte(`{}`)); !errors.Is(err, tt.expErr) {
+				t.Errorf("RawMessageRequest() error = %v, want %v", err, tt.expErr)
+			}
+		})
+	}
+}
+
+func TestWithHTTPClientNil(t *testing.T) {
+	var c = NewClient("key", WithHTTPClient(&http.Client{Timeout: time.Second}), WithHTTPClient(nil))
+	if got := c.client(); got != http.DefaultClient {
+		t.Errorf("client() = %v, want http.DefaultClient", got)
+	}
+}
+
+func TestWithRequestIDHeader(t *testing.T) {
+	var uuid = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	var tests = []struct {
+		name    string
+		codes   []int
+		body    string
+		stream  bool
+		wantErr bool
+	}{
+		{name: "Retried", codes: []int{StatusOverloaded, http.StatusOK}, body: `{"id":"msg_1","role":"assistant","content":[]}`},
+		{name: "Error", codes: []int{http.StatusBadRequest}, body: `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`, wantErr: true},
+		{name: "Stream", codes: []int{http.StatusOK}, body: toolUseStream, stream: true},
+		{name: "Stream Error", codes: []int{http.StatusOK}, body: toolUseStream[:strings.Index(toolUseStream, "event: content_block_stop")] + "event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\",\"message\":\"Overloaded\"}}\n\n", stream: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var sent []string
+			var c = NewClient("key", WithRequestIDHeader("X-Request-Id"), WithMaxRetries(1), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				sent = append(sent, r.Header.Get("X-Request-Id"))
+
+				var code = tt.codes[len(sent)-1]
+				var resp = newTestResponse(code, tt.body)
+				resp.Header.Set("Retry-After", "0")
+				resp.Header.Set("Request-Id", fmt.Sprintf("req_%d", len(sent)))
+				return resp, nil
+			})}))
+
+			var resp *v3.Response
+			var err error
+			if tt.stream {
+				resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+			} else {
+				resp, err = c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{})
+			}
+
+			if len(sent) != len(tt.codes) {
+				t.Fatalf("sent %d requests, want %d", len(sent), len(tt.codes))
+			}
+			for _, id := range sent {
+				if id != sent[0] || !uuid.MatchString(id) {
+					t.Fatalf("sent ids %v, want the same random UUID", sent)
+				}
+			}
+
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			var requestID, clientRequestID string
+			var re *ResponseError
+			switch {
+			case errors.As(err, &re):
+				requestID, clientRequestID = re.RequestID, re.ClientRequestID
+			case err != nil:
+				t.Fatal(err)
+			default:
+				requestID, clientRequestID = resp.RequestID, resp.ClientRequestID
+			}
+			if want := fmt.Sprintf("req_%d", len(sent)); requestID != want {
+				t.Errorf("RequestID = %q, want %q", requestID, want)
+			}
+			if clientRequestID != sent[0] {
+				t.Errorf("ClientRequestID = %q, want %q", clientRequestID, sent[0])
+			}
+		})
+	}
+}
+
+func TestCancelAll(t *testing.T) {
+	const firstEvent = "event: message_start\ndata: {\"type\":\"message_start\",\"message\":{\"id\":\"msg_1\",\"role\":\"assistant\",\"content\":[]}}\n\n"
+
+	var stall = true
+	var started = make(chan struct{}, 2)
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if !stall {
+			return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[]}`), nil
+		}
+
+		started <- struct{}{}
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			var resp = newTestResponse(http.StatusOK, "")
+			resp.Body = &stallingBody{ctx: r.Context(), data: strings.NewReader(firstEvent)}
+			return resp, nil
+		}
+
+		<-r.Context().Done()
+		return nil, r.Context().Err()
+	})}))
+
+	var errs = make(chan error, 2)
+	go func() {
+		var _, err = c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{})
+		errs <- err
+	}()
+	go func() {
+		var _, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+		errs <- err
+	}()
+	<-started
+	<-started
+
+	c.CancelAll()
+	for i := 0; i < 2; i++ {
+		if err := <-errs; !errors.Is(err, context.Canceled) {
+			t.Errorf("error = %v, want %v", err, context.Canceled)
+		}
+	}
+
+	stall = false
+	if _, err := c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{}); err != nil {
+		t.Errorf("request after CancelAll error = %v, want nil", err)
+	}
+}
+
+func TestRequestBetas(t *testing.T) {
+	var betas []string
+	var c = NewClient("key", WithBetas(v3.BetaOutput128k), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		betas = r.Header.Values(betaHeaderName)
+		if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+			var resp = newTestResponse(http.StatusOK, toolUseStream)
+			resp.Header.Set("Content-Type", "text/event-stream")
+			return resp, nil
+		}
+		return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+	})}))
+
+	var req = &v3.Request[v3.Message]{
+		Model:     v3.Claude3Dot7Sonnet20250219,
+		MaxTokens: 16,
+		Betas:     []string{v3.BetaOutput128k, betaTokenEfficientToolsHeaderValue},
+	}
+	var want = []string{v3.BetaOutput128k, betaTokenEfficientToolsHeaderValue}
+
+	if _, err := c.NewMessageRequest(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(betas, want) {
+		t.Errorf("%s = %v, want %v", betaHeaderName, betas, want)
+	}
+
+	if _, err := c.NewMessageStreamedBatchResponse(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(betas, want) {
+		t.Errorf("streaming %s = %v, want %v", betaHeaderName, betas, want)
+	}
+
+	var b, err = v3.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "beta") {
+		t.Errorf("request body = %s, want no betas", b)
+	}
+}
+
+func TestWithModelAwareDefaultMaxTokens(t *testing.T) {
+	var tests = []struct {
+		name      string
+		opts      []Option
+		maxTokens int
+		want      int
+	}{
+		{name: "Default", opts: []Option{WithModelAwareDefaultMaxTokens()}, want: defaultMaxTokens},
+		{name: "Explicit", opts: []Option{WithModelAwareDefaultMaxTokens()}, maxTokens: 100, want: 100},
+		{name: "Disabled", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int
+			var opts = append(tt.opts, WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var req v3.Request[v3.Message]
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					return nil, err
+				}
+				got = req.MaxTokens
+				return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[]}`), nil
+			})}))
+			var c = NewClient("key", opts...)
+
+			var req = &v3.Request[v3.Message]{Model: v3.Claude3Haiku20240307, MaxTokens: tt.maxTokens}
+			if _, err := c.NewMessageRequest(context.Background(), req); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("max_tokens = %d, want %d", got, tt.want)
+			}
+			if req.MaxTokens != tt.maxTokens {
+				t.Errorf("request MaxTokens modified to %d", req.MaxTokens)
+			}
+		})
+	}
+}
+
+func TestWithDefaultMaxTokensCapped(t *testing.T) {
+	var c = NewClient("key", WithModelAwareDefaultMaxTokens())
+	for _, m := range []v3.Model{v3.Claude3Haiku20240307, v3.Claude3Dot7Sonnet20250219, v3.Model(-1)} {
+		var req = withDefaultMaxTokens(c, &v3.Request[v3.Message]{Model: m})
+		if limit := m.MaxOutputTokens(nil); limit > 0 && req.MaxTokens > limit {
+			t.Errorf("%v: MaxTokens = %d, over the model's limit %d", m, req.MaxTokens, limit)
+		}
+		if req.MaxTokens <= 0 {
+			t.Errorf("%v: MaxTokens = %d, want positive", m, req.MaxTokens)
+		}
+	}
+}
+
+func TestWithStreamHeaders(t *testing.T) {
+	var tests = []struct {
+		name   string
+		opts   []Option
+		accept string
+		cache  string
+		custom string
+	}{
+		{name: "Default", accept: "text/event-stream; charset=utf-8", cache: "no-cache"},
+		{
+			name:   "Overridden",
+			opts:   []Option{WithStreamHeaders(http.Header{"accept": {"application/x-ndjson-sse"}, "Cache-Control": nil, "X-Gateway-Dialect": {"sse"}})},
+			accept: "application/x-ndjson-sse",
+			custom: "sse",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var header http.Header
+			var c = NewClient("key", append(tt.opts, WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				header = r.Header
+				var resp = newTestResponse(http.StatusOK, toolUseStream)
+				resp.Header.Set("Content-Type", "text/event-stream")
+				return resp, nil
+			})}))...)
+
+			if _, err := c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{}); err != nil {
+				t.Fatal(err)
+			}
+			if got := header.Get("Accept"); got != tt.accept {
+				t.Errorf("Accept = %q, want %q", got, tt.accept)
+			}
+			if got := header.Get("Cache-Control"); got != tt.cache {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cache)
+			}
+			if got := header.Get("X-Gateway-Dialect"); got != tt.custom {
+				t.Errorf("X-Gateway-Dialect = %q, want %q", got, tt.custom)
+			}
+		})
+	}
+}
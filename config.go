@@ -0,0 +1,79 @@
+package anthropic
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Config is the configuration of a Client, for applications which configure everything declaratively (e.g. from a
+// struct unmarshaled from the environment or flags). Zero values leave the corresponding default in place.
+type Config struct {
+	// APIKey is the API key sent with each request.
+	APIKey string
+	// BaseURL is the URL requests are sent to. See WithBaseURL.
+	BaseURL string
+	// Version is the value of the |Anthropic-Version| header. See WithVersion.
+	Version string
+	// Timeout limits the total time of each request. See WithTimeout.
+	Timeout time.Duration
+	// MaxRetries is the number of times a failed request is retried. See WithMaxRetries.
+	MaxRetries int
+	// Betas are sent in the |anthropic-beta| header. See WithBetas.
+	Betas []string
+	// HTTPClient is the client used to make requests. See WithHTTPClient.
+	HTTPClient *http.Client
+	// Proxy is the URL of the proxy requests are sent through. See WithProxy.
+	Proxy string
+}
+
+// Options returns the Options equivalent to |cfg|, excluding the API key.
+func (cfg Config) Options() []Option {
+	var opts []Option
+	if cfg.HTTPClient != nil {
+		// First, so the options below modify a copy of it.
+		opts = append(opts, WithHTTPClient(cfg.HTTPClient))
+	}
+	if cfg.BaseURL != "" {
+		opts = append(opts, WithBaseURL(cfg.BaseURL))
+	}
+	if cfg.Proxy != "" {
+		opts = append(opts, WithProxy(cfg.Proxy))
+	}
+	if cfg.Version != "" {
+		opts = append(opts, WithVersion(cfg.Version))
+	}
+	if cfg.Timeout > 0 {
+		opts = append(opts, WithTimeout(cfg.Timeout))
+	}
+	if cfg.MaxRetries > 0 {
+		opts = append(opts, WithMaxRetries(cfg.MaxRetries))
+	}
+	if len(cfg.Betas) > 0 {
+		opts = append(opts, WithBetas(cfg.Betas...))
+	}
+
+	return opts
+}
+
+// ErrInvalidBaseURL is returned by NewClientWithConfig when Config.BaseURL isn't an absolute URL.
+var ErrInvalidBaseURL = errors.New("base url must be an absolute url")
+
+// NewClientWithConfig returns a client configured by |cfg|, followed by |opts|. ErrInvalidBaseURL is returned if
+// cfg.BaseURL isn't an absolute URL, and an error wrapping ErrInvalidProxyURL if cfg.Proxy isn't a valid proxy URL.
+func NewClientWithConfig(cfg Config, opts ...Option) (*Client, error) {
+	if cfg.BaseURL != "" {
+		if u, err := url.Parse(cfg.BaseURL); err != nil || !u.IsAbs() {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidBaseURL, cfg.BaseURL)
+		}
+	}
+	if cfg.Proxy != "" {
+		if _, err := parseProxyURL(cfg.Proxy); err != nil {
+			return nil, err
+		}
+	}
+
+	return NewClient(cfg.APIKey, append(cfg.Options(), opts...)...), nil
+}
@@ -0,0 +1,63 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestNewClientWithConfig(t *testing.T) {
+	var calls int
+	var hc = &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls++
+
+		if got := r.URL.String(); got != "https://gateway.example.com/anthropic/v1/messages" {
+			t.Errorf("URL = %s, want the base url", got)
+		}
+		if got := r.Header.Get(apiVersionHeader); got != "2023-01-01" {
+			t.Errorf("%s = %s, want 2023-01-01", apiVersionHeader, got)
+		}
+		if got := r.Header.Get(apiKeyHeader); got != "key" {
+			t.Errorf("%s = %s, want key", apiKeyHeader, got)
+		}
+		if got := r.Header.Values(betaHeaderName); len(got) != 1 || got[0] != v3.BetaOutput128k {
+			t.Errorf("%s = %v, want [%s]", betaHeaderName, got, v3.BetaOutput128k)
+		}
+
+		if calls == 1 {
+			var resp = newTestResponse(529, `{"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}`)
+			resp.Header.Set("Retry-After", "0")
+			return resp, nil
+		}
+		return newTestResponse(http.StatusOK, `{"id":"msg_1","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+	})}
+
+	var c, err = NewClientWithConfig(Config{
+		APIKey:     "key",
+		BaseURL:    "https://gateway.example.com/anthropic/",
+		Version:    "2023-01-01",
+		MaxRetries: 1,
+		Betas:      []string{v3.BetaOutput128k},
+		HTTPClient: hc,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{}); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("made %d requests, want 2", calls)
+	}
+
+	if _, err = NewClientWithConfig(Config{BaseURL: "gateway.example.com"}); !errors.Is(err, ErrInvalidBaseURL) {
+		t.Errorf("NewClientWithConfig() error = %v, wantErr %v", err, ErrInvalidBaseURL)
+	}
+	if _, err = NewClientWithConfig(Config{Proxy: "proxy.example.com:3128"}); !errors.Is(err, ErrInvalidProxyURL) {
+		t.Errorf("NewClientWithConfig() error = %v, wantErr %v", err, ErrInvalidProxyURL)
+	}
+}
@@ -0,0 +1,100 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+const countTokensEndpoint = "v1/messages/count_tokens"
+
+// countTokensFields are the request fields accepted by the count_tokens endpoint. The endpoint rejects the others
+// (e.g. max_tokens), since they don't affect the input.
+var countTokensFields = map[string]bool{
+	"model":       true,
+	"messages":    true,
+	"system":      true,
+	"tools":       true,
+	"tool_choice": true,
+	"thinking":    true,
+}
+
+// TokenCount is the response of the count_tokens endpoint.
+type TokenCount struct {
+	// InputTokens is the number of input tokens |req| would use.
+	InputTokens int `json:"input_tokens"`
+}
+
+// CountTokens returns the number of input tokens |req| would use, without sending it to the model. |req| is
+// serialized exactly as NewMessageRequest serializes it, less the fields the endpoint doesn't accept.
+func (c *Client) CountTokens(ctx context.Context, req *v3.Request[v3.Message]) (*TokenCount, error) {
+	var payload, err = countTokensPayload(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var b []byte
+	if b, _, err = c.post(ctx, countTokensEndpoint, payload, betaHeader(req.Betas)); err != nil {
+		return nil, err
+	}
+
+	var out = &TokenCount{}
+	if err = json.Unmarshal(b, out); err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// TokenCounter returns a v3.TokenCounter which counts tokens exactly, with the count_tokens endpoint (see CountTokens).
+// Each count is a request, made with |ctx|. See WithTokenCounter.
+func (c *Client) TokenCounter(ctx context.Context) v3.TokenCounter {
+	return &endpointTokenCounter{c: c, ctx: ctx}
+}
+
+// endpointTokenCounter is a v3.TokenCounter which uses the count_tokens endpoint.
+type endpointTokenCounter struct {
+	c   *Client
+	ctx context.Context
+}
+
+// Count implements v3.TokenCounter.
+func (e *endpointTokenCounter) Count(r *v3.Request[v3.Message]) (int, error) {
+	var count, err = e.c.CountTokens(e.ctx, r)
+	if err != nil {
+		return 0, err
+	}
+
+	return count.InputTokens, nil
+}
+
+// counter returns the client's token counter (see WithTokenCounter).
+func (c *Client) counter() v3.TokenCounter {
+	if c.tokenCounter == nil {
+		return v3.HeuristicTokenCounter{}
+	}
+
+	return c.tokenCounter
+}
+
+// countTokensPayload returns the body of a count_tokens request for |req|: its message request body, restricted to
+// countTokensFields.
+func countTokensPayload(req *v3.Request[v3.Message]) (map[string]json.RawMessage, error) {
+	var b, err = v3.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for k := range fields {
+		if !countTokensFields[k] {
+			delete(fields, k)
+		}
+	}
+
+	return fields, nil
+}
@@ -0,0 +1,100 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// TestRequestSerializationShared checks that the messages, count_tokens, and message batch endpoints serialize the
+// same request identically, less their endpoint-specific fields.
+func TestRequestSerializationShared(t *testing.T) {
+	var bodies = make(map[string][]byte)
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodies[r.URL.Path] = b
+
+		return newTestResponse(http.StatusOK, `{"id":"x","input_tokens":10}`), nil
+	})}))
+
+	var req = &v3.Request[v3.Message]{
+		Model:       v3.Claude3Dot7Sonnet20250219,
+		System:      v3.Optional("Be brief."),
+		Messages:    []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "What's the weather?"}}}},
+		MaxTokens:   2048,
+		Temperature: v3.Optional(1.0),
+		Thinking:    v3.NewThinking(1024),
+		ToolChoice:  &v3.ToolChoice{Type: "auto"},
+		Tools: []*v3.Tool{{
+			Name:        "get_weather",
+			InputSchema: &v3.Schema{Type: v3.SchemaTypeObject, Properties: map[string]*v3.Schema{"city": {Type: v3.SchemaTypeString}}},
+		}},
+	}
+
+	var ctx = context.Background()
+	if _, err := c.NewMessageRequest(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CountTokens(ctx, req); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateMessageBatch(ctx, []*BatchRequest{{CustomID: "a", Params: req}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var message, count map[string]json.RawMessage
+	if err := json.Unmarshal(bodies["/v1/messages"], &message); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(bodies["/v1/messages/count_tokens"], &count); err != nil {
+		t.Fatal(err)
+	}
+
+	for k := range countTokensFields {
+		if !bytes.Equal(count[k], message[k]) {
+			t.Errorf("count_tokens %s = %s, want %s", k, count[k], message[k])
+		}
+	}
+	for k := range count {
+		if !countTokensFields[k] {
+			t.Errorf("count_tokens body has unsupported field %s", k)
+		}
+	}
+
+	var batch struct {
+		Requests []struct {
+			Params json.RawMessage `json:"params"`
+		} `json:"requests"`
+	}
+	if err := json.Unmarshal(bodies["/v1/messages/batches"], &batch); err != nil {
+		t.Fatal(err)
+	}
+	if got := batch.Requests[0].Params; !bytes.Equal(got, bodies["/v1/messages"]) {
+		t.Errorf("batch params = %s, want %s", got, bodies["/v1/messages"])
+	}
+}
+
+func TestClientTokenCounter(t *testing.T) {
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.URL.Path != "/"+countTokensEndpoint {
+			t.Errorf("path = %s, want /%s", r.URL.Path, countTokensEndpoint)
+		}
+		return newTestResponse(http.StatusOK, `{"input_tokens":42}`), nil
+	})}))
+
+	var got, err = c.TokenCounter(context.Background()).Count(&v3.Request[v3.Message]{Model: v3.Claude3Haiku20240307})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 42 {
+		t.Errorf("Count() = %d, want 42", got)
+	}
+}
@@ -0,0 +1,93 @@
+package anthropic
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redacted replaces the values of sensitive headers in request dumps.
+const redacted = "REDACTED"
+
+// credentialHeaders are the headers whose values are redacted in request dumps, by canonical name.
+var credentialHeaders = map[string]bool{
+	http.CanonicalHeaderKey(apiKeyHeader): true,
+	"Authorization":                       true,
+	"Proxy-Authorization":                 true,
+	"Cookie":                              true,
+	"Set-Cookie":                          true,
+}
+
+// requestDump writes requests and responses to a writer for offline inspection. Writes are serialized so that a
+// dump shared by concurrent requests isn't corrupted mid-write.
+type requestDump struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// Write implements io.Writer.
+func (d *requestDump) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	return d.w.Write(p)
+}
+
+// request writes |req|'s request line, headers, and |body|. Credentials are redacted.
+func (d *requestDump) request(req *http.Request, body []byte) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--> %s %s\n", req.Method, req.URL)
+	writeHeaders(&sb, req.Header)
+	sb.WriteString("\n")
+	sb.Write(body)
+	sb.WriteString("\n\n")
+
+	_, _ = io.WriteString(d, sb.String())
+}
+
+// response writes |resp|'s status line and headers, and replaces its body with one that writes everything read
+// from it to the dump. This covers both regular responses and server-sent event streams.
+func (d *requestDump) response(resp *http.Response) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<-- %s\n", resp.Status)
+	writeHeaders(&sb, resp.Header)
+	sb.WriteString("\n")
+
+	_, _ = io.WriteString(d, sb.String())
+
+	resp.Body = &dumpBody{Reader: io.TeeReader(resp.Body, d), Closer: resp.Body, dump: d}
+}
+
+// dumpBody is a response body which is copied to a dump as it's read.
+type dumpBody struct {
+	io.Reader
+	io.Closer
+	dump *requestDump
+}
+
+// Close terminates the dumped body and closes the underlying body.
+func (b *dumpBody) Close() error {
+	_, _ = io.WriteString(b.dump, "\n\n")
+	return b.Closer.Close()
+}
+
+// writeHeaders writes |h| to |sb| in sorted order, redacting credentials (see credentialHeaders).
+func writeHeaders(sb *strings.Builder, h http.Header) {
+	var keys = make([]string, 0, len(h))
+	for k := range h {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range h[k] {
+			if credentialHeaders[http.CanonicalHeaderKey(k)] {
+				v = redacted
+			}
+			fmt.Fprintf(sb, "%s: %s\n", k, v)
+		}
+	}
+}
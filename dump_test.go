@@ -0,0 +1,67 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestWithRequestDump(t *testing.T) {
+	const respBody = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"text","text":"Hi!"}],"model":"claude-3-haiku-20240307","stop_reason":"end_turn","stop_sequence":null,"usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var buf = &bytes.Buffer{}
+	var c = NewClient("sk-secret", WithRequestDump(buf), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return newTestResponse(http.StatusOK, respBody), nil
+	})}))
+
+	var _, err = c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{
+		Model:     v3.Claude3Haiku20240307,
+		Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Hello"}}}},
+		MaxTokens: 16,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var dump = buf.String()
+	for _, want := range []string{"--> POST https://api.anthropic.com/v1/messages", `"text":"Hello"`, redacted, respBody} {
+		if !strings.Contains(dump, want) {
+			t.Errorf("dump is missing %q:\n%s", want, dump)
+		}
+	}
+	if strings.Contains(dump, "sk-secret") {
+		t.Errorf("dump contains the API key:\n%s", dump)
+	}
+}
+
+func TestWithRequestDumpRedactsCredentials(t *testing.T) {
+	var buf = &bytes.Buffer{}
+	var c = NewClient("sk-secret", WithRequestDump(buf), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var resp = newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[]}`)
+		resp.Header.Set("Set-Cookie", "session=resp-cookie")
+		return resp, nil
+	})}))
+	c.AddRequestHeaders(http.Header{
+		"Authorization":       {"Bearer gateway-token"},
+		"Proxy-Authorization": {"Basic proxy-creds"},
+		"Cookie":              {"session=req-cookie"},
+	})
+
+	if _, err := c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{Model: v3.Claude3Haiku20240307, MaxTokens: 16}); err != nil {
+		t.Fatal(err)
+	}
+
+	var dump = buf.String()
+	for _, secret := range []string{"sk-secret", "gateway-token", "proxy-creds", "req-cookie", "resp-cookie"} {
+		if strings.Contains(dump, secret) {
+			t.Errorf("dump contains %q:\n%s", secret, dump)
+		}
+	}
+	if !strings.Contains(dump, "Authorization: "+redacted) {
+		t.Errorf("dump is missing the redacted Authorization header:\n%s", dump)
+	}
+}
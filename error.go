@@ -1,6 +1,8 @@
 package anthropic
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 )
@@ -8,15 +10,51 @@ import (
 const (
 	errRateLimit  = "rate_limit_error"
 	errOverloaded = "overloaded_error"
+	errAPI        = "api_error"
 )
 
+// StatusOverloaded is the (non-standard) HTTP status code the API responds with when it's temporarily overloaded.
+const StatusOverloaded = 529
+
+// errorTypeStatus is the HTTP status code the API responds with for each error type which can occur mid-stream.
+var errorTypeStatus = map[string]int{
+	errRateLimit:  http.StatusTooManyRequests,
+	errOverloaded: StatusOverloaded,
+	errAPI:        http.StatusInternalServerError,
+}
+
+// streamedError returns the error sent in the "error" event |data| of a stream. Errors sent mid-stream arrive on a
+// connection which already responded 200, so Code is set to the status the API would have responded with for the
+// error's type (e.g. StatusOverloaded for "overloaded_error"), allowing status-based handling to treat them alike.
+func streamedError(data []byte) error {
+	var errResp = &ResponseError{}
+	if err := json.Unmarshal(data, errResp); err != nil {
+		return errors.New(string(data))
+	}
+	if errResp.Err.Code == 0 {
+		errResp.Err.Code = errorTypeStatus[errResp.Err.Type]
+	}
+
+	return errResp
+}
+
 type ResponseError struct {
 	Err Error `json:"error"`
+	// RequestID is the id the API assigned to the failed request (its |request-id| response header), if known.
+	RequestID string `json:"-"`
+	// ClientRequestID is the id the client generated for the failed request, if it was configured to (see
+	// WithRequestIDHeader).
+	ClientRequestID string `json:"-"`
 }
 
-// Error implements the error interface.
+// Error implements the error interface. Any details returned by the API are appended to the message.
 func (r *ResponseError) Error() string {
-	return fmt.Sprintf("%s: %s (code: %d)", r.Err.Type, r.Err.Message, r.Err.Code)
+	var msg = fmt.Sprintf("%s: %s (code: %d)", r.Err.Type, r.Err.Message, r.Err.Code)
+	if len(r.Err.Details) > 0 {
+		msg += fmt.Sprintf(" details: %s", r.Err.Details)
+	}
+
+	return msg
 }
 
 // Retryable returns true if the error is retryable. For now, we assume all 5xx errors are transient.
@@ -37,4 +75,35 @@ type Error struct {
 	Message string `json:"message"`
 	// Code is the HTTP status code returned by the API (populated by the client).
 	Code int `json:"code"`
+	// Details holds additional context about the error when the API provides it (e.g. which field of the request was
+	// invalid). Its shape depends on the error, so it is left as raw JSON.
+	Details json.RawMessage `json:"details,omitempty"`
+	// Extra holds any other fields of the error returned by the API, keyed by field name.
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// unmarshalError is a type alias for Error to allow custom JSON unmarshaling.
+type unmarshalError Error
+
+// UnmarshalJSON implements a custom JSON unmarshaling for the Error type, which captures any fields beyond the known
+// ones in Extra.
+func (e *Error) UnmarshalJSON(b []byte) error {
+	if err := json.Unmarshal(b, (*unmarshalError)(e)); err != nil {
+		return err
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return err
+	}
+	for _, k := range []string{"type", "message", "code", "details"} {
+		delete(fields, k)
+	}
+
+	e.Extra = nil
+	if len(fields) > 0 {
+		e.Extra = fields
+	}
+
+	return nil
 }
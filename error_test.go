@@ -0,0 +1,67 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestErrorUnmarshalDetails(t *testing.T) {
+	var in = `{"type":"error","error":{"type":"invalid_request_error","message":"invalid request","details":{"field":"messages.0.content"},"param":"messages"}}`
+
+	var r = &ResponseError{}
+	if err := json.Unmarshal([]byte(in), r); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := string(r.Err.Details); got != `{"field":"messages.0.content"}` {
+		t.Errorf("Details = %s", got)
+	}
+	if got := string(r.Err.Extra["param"]); got != `"messages"` {
+		t.Errorf("Extra[param] = %s", got)
+	}
+	if exp := `invalid_request_error: invalid request (code: 0) details: {"field":"messages.0.content"}`; r.Error() != exp {
+		t.Errorf("Error() = %s, want %s", r.Error(), exp)
+	}
+}
+
+func TestPayloadTooLarge(t *testing.T) {
+	var tests = []struct {
+		name string
+		body string
+		api  bool
+	}{
+		{name: "Empty Body", body: ``},
+		{name: "HTML Body", body: `<html><body>413 Request Entity Too Large</body></html>`},
+		{name: "API Error", body: `{"type":"error","error":{"type":"request_too_large","message":"Request exceeds the maximum allowed number of bytes."}}`, api: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var c = NewClient("key", WithMaxRetries(2), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				return newTestResponse(http.StatusRequestEntityTooLarge, tt.body), nil
+			})}))
+
+			var _, err = c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{})
+			if !errors.Is(err, ErrPayloadTooLarge) {
+				t.Errorf("NewMessageRequest() error = %v, wantErr %v", err, ErrPayloadTooLarge)
+			}
+			if calls != 1 {
+				t.Errorf("made %d requests, want 1 (413s must not be retried)", calls)
+			}
+
+			var re *ResponseError
+			if errors.As(err, &re) != tt.api {
+				t.Errorf("errors.As(err, *ResponseError) = %v, want %v", !tt.api, tt.api)
+			} else if tt.api && re.Retryable() {
+				t.Error("Retryable() = true, want false")
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+)
+
+const (
+	filesEndpoint = "v1/files"
+	// Header value to enable the Files API.
+	// https://docs.anthropic.com/en/docs/build-with-claude/files
+	betaFilesAPIHeaderValue = "files-api-2025-04-14"
+)
+
+// ErrEmptyFileID is returned by DownloadFile when it's given an empty file ID.
+var ErrEmptyFileID = errors.New("file id cannot be empty")
+
+// DownloadFile downloads the content of the file |fileID|, e.g. a file created by the code execution tool. It returns
+// the content, which the caller must close, and its media type (the response's |Content-Type|). The content is
+// streamed rather than read into memory, so large files can be copied to their destination (e.g. an HTTP response)
+// as they're received; the client's WithMaxResponseBytes limit doesn't apply. Only files created by Claude (rather
+// than uploaded) can be downloaded.
+// https://docs.anthropic.com/en/api/files-content
+func (c *Client) DownloadFile(ctx context.Context, fileID string) (io.ReadCloser, string, error) {
+	if fileID == "" {
+		return nil, "", ErrEmptyFileID
+	}
+
+	var resp, err = c.getResponse(ctx, c.endpoint(filesEndpoint+"/"+url.PathEscape(fileID)+"/content"), betaHeader([]string{betaFilesAPIHeaderValue}))
+	if err != nil {
+		return nil, "", err
+	}
+
+	return resp.Body, resp.Header.Get("Content-Type"), nil
+}
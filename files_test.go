@@ -0,0 +1,58 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+func TestDownloadFile(t *testing.T) {
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1/files/file_1/content" {
+			return newTestResponse(http.StatusNotFound, `{"type":"error","error":{"type":"not_found_error","message":"not found"}}`), nil
+		}
+		if got := r.Header.Get(betaHeaderName); got != betaFilesAPIHeaderValue {
+			t.Errorf("%s = %q, want %q", betaHeaderName, got, betaFilesAPIHeaderValue)
+		}
+
+		var resp = newTestResponse(http.StatusOK, "a,b\n1,2\n")
+		resp.Header.Set("Content-Type", "text/csv")
+		return resp, nil
+	})}))
+
+	var tests = []struct {
+		name        string
+		fileID      string
+		content     string
+		contentType string
+		wantErr     bool
+		err         error
+	}{
+		{name: "Found", fileID: "file_1", content: "a,b\n1,2\n", contentType: "text/csv"},
+		{name: "Not Found", fileID: "file_2", wantErr: true},
+		{name: "Empty", wantErr: true, err: ErrEmptyFileID},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body, contentType, err = c.DownloadFile(context.Background(), tt.fileID)
+			if (err != nil) != tt.wantErr || (tt.err != nil && !errors.Is(err, tt.err)) {
+				t.Fatalf("DownloadFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			defer body.Close()
+
+			var b []byte
+			if b, err = io.ReadAll(body); err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.content || contentType != tt.contentType {
+				t.Errorf("DownloadFile() = %q, %q, want %q, %q", b, contentType, tt.content, tt.contentType)
+			}
+		})
+	}
+}
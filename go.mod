@@ -2,6 +2,6 @@ module github.com/fabiustech/anthropic
 
 go 1.20
 
-require github.com/aws/aws-sdk-go v1.45.28
+require github.com/aws/aws-sdk-go v1.55.8
 
 require github.com/jmespath/go-jmespath v0.4.0 // indirect
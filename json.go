@@ -0,0 +1,32 @@
+package anthropic
+
+import (
+	"encoding/json"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// marshalObject marshals |v|, which must marshal to a JSON object, with the fields in |set| added (replacing any
+// existing values) and the fields in |remove| removed. This is used to add fields to types with custom marshaling,
+// whose MarshalJSON method would otherwise be promoted through embedding and drop the outer fields.
+func marshalObject(v any, set map[string]any, remove ...string) ([]byte, error) {
+	var b, err = v3.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+	for _, k := range remove {
+		delete(fields, k)
+	}
+	for k, val := range set {
+		if fields[k], err = v3.Marshal(val); err != nil {
+			return nil, err
+		}
+	}
+
+	return v3.Marshal(fields)
+}
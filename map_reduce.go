@@ -0,0 +1,169 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+const (
+	// mapReduceConcurrency is the maximum number of map (or reduce) requests MapReduce has in flight at once.
+	mapReduceConcurrency = 4
+	// mapReduceInputTokens is the estimated number of input tokens MapReduce puts in a single reduce request. It's
+	// well under the context window of every current model, leaving room for the instruction and the output.
+	mapReduceInputTokens = 100_000
+	// mapReduceMaxRounds is the maximum number of rounds of grouped reduce requests MapReduce sends before the final
+	// reduce, bounding its cost.
+	mapReduceMaxRounds = 8
+)
+
+var (
+	// ErrNoChunks is returned by MapReduce when it's given no chunks.
+	ErrNoChunks = errors.New("no chunks to map")
+	// ErrReduceNotConverging is returned by MapReduce when its results can't be combined into a single request: a
+	// round of grouped reduce requests wouldn't reduce their number (e.g. because each result is too large to share a
+	// request with another), or they still don't fit after mapReduceMaxRounds rounds.
+	ErrReduceNotConverging = errors.New("map results can't be reduced to a single request")
+)
+
+// MapReduce applies |instruction| (e.g. "Summarize the key decisions in this document.") to each of |chunks| with
+// |model|, then combines the results into a single answer to |instruction|, which it returns.
+//
+// The map step sends one request per chunk, with at most a few in flight at once (see BatchMessages). The reduce step
+// sends the map results, in order, in a single request. If they're too large to fit in one request (as counted by the
+// client's token counter, see WithTokenCounter), they're reduced in consecutive groups first, repeatedly, until they
+// do; if a round wouldn't reduce the number of results, or they still don't fit after a few rounds, an error wrapping
+// ErrReduceNotConverging is returned rather than sending more requests. A single chunk is only mapped. Each request
+// sets MaxTokens to 4096, or to the model's maximum output tokens if that's lower.
+//
+// The first error encountered is returned, annotated with the step and chunk it occurred in.
+func (c *Client) MapReduce(ctx context.Context, model v3.Model, instruction string, chunks []string) (string, error) {
+	if len(chunks) == 0 {
+		return "", ErrNoChunks
+	}
+
+	var results, err = c.mapChunks(ctx, model, instruction, chunks, mapPrompt, "map")
+	if err != nil {
+		return "", err
+	}
+
+	var count = func(s string) (int, error) {
+		return c.counter().Count(&v3.Request[v3.Message]{
+			Model:    model,
+			Messages: []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: s}}}},
+		})
+	}
+	for round := 0; len(results) > 1; round++ {
+		var groups [][]string
+		if groups, err = groupByTokens(results, mapReduceInputTokens, count); err != nil {
+			return "", fmt.Errorf("count tokens: %w", err)
+		}
+		if len(groups) == 1 {
+			return c.reduce(ctx, model, instruction, results)
+		}
+		if len(groups) == len(results) {
+			return "", fmt.Errorf("%w: none of the %d results fit in a reduce request together", ErrReduceNotConverging, len(results))
+		}
+		if round == mapReduceMaxRounds {
+			return "", fmt.Errorf("%w: %d results remain after %d rounds", ErrReduceNotConverging, len(results), round)
+		}
+
+		var prompts = make([]string, len(groups))
+		for i, g := range groups {
+			prompts[i] = reducePrompt(g)
+		}
+		if results, err = c.mapChunks(ctx, model, instruction, prompts, identity, "reduce"); err != nil {
+			return "", err
+		}
+	}
+
+	return results[0], nil
+}
+
+// reduce combines |results| into a single answer to |instruction| in one request.
+func (c *Client) reduce(ctx context.Context, model v3.Model, instruction string, results []string) (string, error) {
+	var out, err = c.mapChunks(ctx, model, instruction, []string{reducePrompt(results)}, identity, "reduce")
+	if err != nil {
+		return "", err
+	}
+
+	return out[0], nil
+}
+
+// mapChunks sends a request for each of |chunks|, formatted by |format|, with |instruction| as the system prompt. It
+// returns the text of the responses, index-aligned with |chunks|. Errors are annotated with |step|.
+func (c *Client) mapChunks(ctx context.Context, model v3.Model, instruction string, chunks []string, format func(string) string, step string) ([]string, error) {
+	// The requests aren't streamed, so MaxTokens must stay under the non-streaming limit of high-output models.
+	var maxTokens = defaultMaxTokensFor(model, c.betas())
+
+	var reqs = make([]*v3.Request[v3.Message], len(chunks))
+	for i, chunk := range chunks {
+		reqs[i] = &v3.Request[v3.Message]{
+			Model:     model,
+			MaxTokens: maxTokens,
+			System:    v3.Optional(instruction),
+			Messages: []*v3.Message{
+				{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: format(chunk)}}},
+			},
+		}
+	}
+
+	var resps, errs = c.BatchMessages(ctx, reqs, mapReduceConcurrency)
+	var out = make([]string, len(chunks))
+	for i := range chunks {
+		if errs[i] != nil {
+			return nil, fmt.Errorf("%s chunk %d: %w", step, i, errs[i])
+		}
+		out[i] = resps[i].Text()
+	}
+
+	return out, nil
+}
+
+// groupByTokens splits |results| into consecutive groups whose size, as counted by |count|, is at most |budget| tokens.
+// A result which is larger than |budget| on its own gets its own group.
+func groupByTokens(results []string, budget int, count func(string) (int, error)) ([][]string, error) {
+	var groups [][]string
+	var group []string
+	var size int
+	for _, r := range results {
+		var n, err = count(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(group) > 0 && size+n > budget {
+			groups = append(groups, group)
+			group, size = nil, 0
+		}
+		group = append(group, r)
+		size += n
+	}
+
+	return append(groups, group), nil
+}
+
+// mapPrompt returns the user message sent for |chunk| in the map step.
+func mapPrompt(chunk string) string {
+	return "<document>\n" + chunk + "\n</document>"
+}
+
+// reducePrompt returns the user message which combines |results|.
+func reducePrompt(results []string) string {
+	var sb strings.Builder
+	sb.WriteString("The document was too long to process at once, so it was split into consecutive parts and the ")
+	sb.WriteString("instruction was applied to each part. Combine the results below, in order, into a single result ")
+	sb.WriteString("for the whole document.\n")
+	for i, r := range results {
+		fmt.Fprintf(&sb, "\n<result part=\"%d\">\n%s\n</result>\n", i+1, r)
+	}
+
+	return sb.String()
+}
+
+// identity returns |s|.
+func identity(s string) string {
+	return s
+}
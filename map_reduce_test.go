@@ -0,0 +1,202 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestMapReduce(t *testing.T) {
+	var mu sync.Mutex
+	var prompts []string
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var req v3.Request[v3.Message]
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, err
+		}
+		if req.System == nil || *req.System != "Summarize." {
+			t.Errorf("system = %v, want the instruction", req.System)
+		}
+
+		var prompt = req.Messages[0].Content[0].Text
+		mu.Lock()
+		prompts = append(prompts, prompt)
+		mu.Unlock()
+
+		var text = "combined"
+		if strings.HasPrefix(prompt, "<document>") {
+			text = "summary of " + strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(prompt, "<document>"), "</document>"))
+		}
+		var b, _ = json.Marshal(text)
+
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":`+string(b)+`}]}`), nil
+	})}))
+
+	var out, err = c.MapReduce(context.Background(), v3.Claude3Haiku20240307, "Summarize.", []string{"a", "b", "c"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "combined" {
+		t.Errorf("MapReduce() = %q, want %q", out, "combined")
+	}
+
+	if len(prompts) != 4 {
+		t.Fatalf("sent %d requests, want 4", len(prompts))
+	}
+	var reduce = prompts[3]
+	var ia, ib, ic = strings.Index(reduce, "summary of a"), strings.Index(reduce, "summary of b"), strings.Index(reduce, "summary of c")
+	if ia < 0 || ib < ia || ic < ib {
+		t.Errorf("reduce prompt doesn't contain the map results in order: %q", reduce)
+	}
+}
+
+func TestMapReduceErrors(t *testing.T) {
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		if strings.Contains(string(b), "bad") {
+			return newTestResponse(http.StatusBadRequest, `{"type":"error","error":{"type":"invalid_request_error","message":"bad"}}`), nil
+		}
+
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}]}`), nil
+	})}))
+
+	if _, err := c.MapReduce(context.Background(), v3.Claude3Haiku20240307, "Summarize.", nil); !errors.Is(err, ErrNoChunks) {
+		t.Errorf("MapReduce(nil) error = %v, want %v", err, ErrNoChunks)
+	}
+
+	var _, err = c.MapReduce(context.Background(), v3.Claude3Haiku20240307, "Summarize.", []string{"good", "bad"})
+	if err == nil || !strings.Contains(err.Error(), "map chunk 1") {
+		t.Errorf("MapReduce() error = %v, want an error for map chunk 1", err)
+	}
+}
+
+func TestGroupByTokens(t *testing.T) {
+	var tests = []struct {
+		name    string
+		results []string
+		budget  int
+		want    []int
+	}{
+		{name: "fits", results: []string{"aaaa", "bbbb"}, budget: 2, want: []int{2}},
+		{name: "split", results: []string{"aaaa", "bbbb", "cccc"}, budget: 2, want: []int{2, 1}},
+		{name: "oversized", results: []string{"aaaaaaaaaaaa", "bbbb"}, budget: 2, want: []int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var groups, err = groupByTokens(tt.results, tt.budget, func(s string) (int, error) {
+				return v3.EstimateTokens(s), nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(groups) != len(tt.want) {
+				t.Fatalf("got %d groups, want %d", len(groups), len(tt.want))
+			}
+			for i, g := range groups {
+				if len(g) != tt.want[i] {
+					t.Errorf("group %d has %d results, want %d", i, len(g), tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+type countFunc func(r *v3.Request[v3.Message]) (int, error)
+
+func (f countFunc) Count(r *v3.Request[v3.Message]) (int, error) {
+	return f(r)
+}
+
+func TestMapReduceTokenCounter(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	var c = NewClient("key", WithTokenCounter(countFunc(func(r *v3.Request[v3.Message]) (int, error) {
+		if r.Messages[0].Content[0].Text == "big" {
+			return mapReduceInputTokens, nil
+		}
+		return 1, nil
+	})), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		mu.Lock()
+		requests++
+		mu.Unlock()
+
+		var text = "ok"
+		switch {
+		case strings.Contains(string(b), "chunk-a"):
+			text = "big"
+		case strings.Contains(string(b), "<document>"):
+			text = "small"
+		}
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"`+text+`"}]}`), nil
+	})}))
+
+	// The first map result fills a reduce request by the counter's count, so it's reduced on its own, and the others
+	// together, and then the two results are combined: 3 + 2 + 1 requests.
+	if _, err := c.MapReduce(context.Background(), v3.Claude3Haiku20240307, "Summarize.", []string{"chunk-a", "chunk-b", "chunk-c"}); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 6 {
+		t.Errorf("sent %d requests, want 6", requests)
+	}
+
+	var errCount = errors.New("count failed")
+	c = NewClient("key", WithTokenCounter(countFunc(func(r *v3.Request[v3.Message]) (int, error) {
+		return 0, errCount
+	})), WithHTTPClient(c.httpClient))
+	if _, err := c.MapReduce(context.Background(), v3.Claude3Haiku20240307, "Summarize.", []string{"a", "b"}); !errors.Is(err, errCount) {
+		t.Errorf("MapReduce() error = %v, wantErr %v", err, errCount)
+	}
+}
+
+func TestMapReduceNotConverging(t *testing.T) {
+	var mu sync.Mutex
+	var requests int
+	var c = NewClient("key", WithTokenCounter(countFunc(func(r *v3.Request[v3.Message]) (int, error) {
+		return mapReduceInputTokens, nil
+	})), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		mu.Lock()
+		requests++
+		mu.Unlock()
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"big"}]}`), nil
+	})}))
+
+	var _, err = c.MapReduce(context.Background(), v3.Claude3Haiku20240307, "Summarize.", []string{"a", "b", "c"})
+	if !errors.Is(err, ErrReduceNotConverging) {
+		t.Errorf("MapReduce() error = %v, wantErr %v", err, ErrReduceNotConverging)
+	}
+	if requests != 3 {
+		t.Errorf("sent %d requests, want only the 3 map requests", requests)
+	}
+}
+
+func TestMapReduceHighOutputModel(t *testing.T) {
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var req v3.Request[v3.Message]
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, err
+		}
+		if req.MaxTokens <= 0 || req.MaxTokens > maxNonStreamingTokens {
+			t.Errorf("max_tokens = %d, want at most %d", req.MaxTokens, maxNonStreamingTokens)
+		}
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"ok"}]}`), nil
+	})}))
+
+	if _, err := c.MapReduce(context.Background(), v3.Claude3Dot7Sonnet20250219, "Summarize.", []string{"a", "b"}); err != nil {
+		t.Errorf("MapReduce() error = %v, want nil", err)
+	}
+}
@@ -0,0 +1,49 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// errNotFound is the type of the error the API responds with when a resource, such as a model, doesn't exist.
+const errNotFound = "not_found_error"
+
+// isModelNotFound returns true if |err| is the API's error for a request to a model which doesn't exist (e.g. because
+// it was retired), rather than any other 404.
+func isModelNotFound(err error) bool {
+	var re *ResponseError
+	return errors.As(err, &re) && re.Err.Type == errNotFound && strings.HasPrefix(re.Err.Message, "model:")
+}
+
+// retryBudgetKey is the context key for the number of retries (see WithMaxRetries) a message request has left. It's
+// shared with the request's fallback (see WithModelFallback), so falling back doesn't reset the budget.
+type retryBudgetKey struct{}
+
+// withRetryBudget returns |ctx| with a retry budget of the client's max retries if the client falls back to another
+// model and |ctx| doesn't already have one (i.e. it isn't a fallback request's context).
+func (c *Client) withRetryBudget(ctx context.Context) context.Context {
+	if c.modelFallback == v3.UnknownModel || ctx.Value(retryBudgetKey{}) != nil {
+		return ctx
+	}
+
+	var n = c.maxRetries
+	return context.WithValue(ctx, retryBudgetKey{}, &n)
+}
+
+// fallbackRequest returns a copy of |req| which uses the client's fallback model (see WithModelFallback) if |err| is
+// a model not found error for |req|'s model, or nil if the request shouldn't be retried with it.
+func fallbackRequest[T v3.RequestMessage](ctx context.Context, c *Client, req *v3.Request[T], err error) *v3.Request[T] {
+	if c.modelFallback == v3.UnknownModel || req.Model == c.modelFallback || ctx.Err() != nil || !isModelNotFound(err) {
+		return nil
+	}
+
+	slog.Warn("anthropic model not found, retrying with fallback model", "model", req.Model, "fallback", c.modelFallback)
+	var cp = *req
+	cp.Model = c.modelFallback
+
+	return &cp
+}
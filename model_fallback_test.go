@@ -0,0 +1,109 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestWithModelFallback(t *testing.T) {
+	const (
+		modelNotFound = `{"type":"error","error":{"type":"not_found_error","message":"model: claude-3-opus-20240229"}}`
+		otherNotFound = `{"type":"error","error":{"type":"not_found_error","message":"Not found"}}`
+	)
+
+	var tests = []struct {
+		name      string
+		opts      []Option
+		notFound  string
+		streaming bool
+		models    []v3.Model
+		wantErr   bool
+	}{
+		{name: "Fallback", opts: []Option{WithModelFallback(v3.Claude3Dot5Sonnet20241022)}, notFound: modelNotFound, models: []v3.Model{v3.Claude3Opus20240229, v3.Claude3Dot5Sonnet20241022}},
+		{name: "Streaming Fallback", opts: []Option{WithModelFallback(v3.Claude3Dot5Sonnet20241022)}, notFound: modelNotFound, streaming: true, models: []v3.Model{v3.Claude3Opus20240229, v3.Claude3Dot5Sonnet20241022}},
+		{name: "Other Not Found", opts: []Option{WithModelFallback(v3.Claude3Dot5Sonnet20241022)}, notFound: otherNotFound, models: []v3.Model{v3.Claude3Opus20240229}, wantErr: true},
+		{name: "No Fallback", notFound: modelNotFound, models: []v3.Model{v3.Claude3Opus20240229}, wantErr: true},
+		{name: "Fallback Not Found", opts: []Option{WithModelFallback(v3.Claude3Haiku20240307)}, notFound: modelNotFound, models: []v3.Model{v3.Claude3Opus20240229, v3.Claude3Haiku20240307}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var models []v3.Model
+			var c = NewClient("key", append(tt.opts, WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var req v3.Request[v3.Message]
+				if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+					return nil, err
+				}
+				models = append(models, req.Model)
+
+				if req.Model != v3.Claude3Dot5Sonnet20241022 {
+					return newTestResponse(http.StatusNotFound, tt.notFound), nil
+				}
+				if tt.streaming {
+					var resp = newTestResponse(http.StatusOK, toolUseStream)
+					resp.Header.Set("Content-Type", "text/event-stream")
+					return resp, nil
+				}
+				return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","content":[]}`), nil
+			})}))...)
+
+			var req = &v3.Request[v3.Message]{Model: v3.Claude3Opus20240229, MaxTokens: 16}
+			var err error
+			if tt.streaming {
+				_, err = c.NewMessageStreamedBatchResponse(context.Background(), req)
+			} else {
+				_, err = c.NewMessageRequest(context.Background(), req)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(models) != len(tt.models) {
+				t.Fatalf("requested models %v, want %v", models, tt.models)
+			}
+			for i := range models {
+				if models[i] != tt.models[i] {
+					t.Errorf("requested models %v, want %v", models, tt.models)
+				}
+			}
+			if req.Model != v3.Claude3Opus20240229 {
+				t.Errorf("request Model modified to %v", req.Model)
+			}
+		})
+	}
+}
+
+func TestWithModelFallbackSharesRetries(t *testing.T) {
+	const modelNotFound = `{"type":"error","error":{"type":"not_found_error","message":"model: claude-3-opus-20240229"}}`
+
+	// The original model fails transiently once before it's found not to exist, using one of the two retries, so the
+	// fallback model is only retried once.
+	var models []v3.Model
+	var c = NewClient("key", WithModelFallback(v3.Claude3Dot5Sonnet20241022), WithMaxRetries(2), WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var req v3.Request[v3.Message]
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, err
+		}
+		models = append(models, req.Model)
+
+		if len(models) == 2 {
+			return newTestResponse(http.StatusNotFound, modelNotFound), nil
+		}
+		var resp = newTestResponse(http.StatusInternalServerError, `{"type":"error","error":{"type":"api_error","message":"Internal error"}}`)
+		resp.Header.Set("Retry-After", "0")
+		return resp, nil
+	})}))
+
+	if _, err := c.NewMessageRequest(context.Background(), &v3.Request[v3.Message]{Model: v3.Claude3Opus20240229, MaxTokens: 16}); err == nil {
+		t.Fatal("expected an error")
+	}
+
+	var want = []v3.Model{v3.Claude3Opus20240229, v3.Claude3Opus20240229, v3.Claude3Dot5Sonnet20241022, v3.Claude3Dot5Sonnet20241022}
+	if !reflect.DeepEqual(models, want) {
+		t.Errorf("requested models %v, want %v", models, want)
+	}
+}
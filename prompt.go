@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+
+	v3 "github.com/fabiustech/anthropic/v3"
 )
 
 // Prompt represents the prompt passed to the model. The text that you give Claude is designed to elicit, or "prompt",
@@ -53,6 +55,10 @@ var (
 	ErrBadSystemMessage = errors.New("system messages must be the first message in the dialogue")
 	// ErrMissingAssistant indicates that a Message slice's last Message was not from the assistant.
 	ErrMissingAssistant = errors.New("the final message in the dialogue must be from the assistant")
+	// ErrMissingHuman indicates that the first non-system Message in a Message slice was not from the human.
+	ErrMissingHuman = errors.New("the first non-system message in the dialogue must be from the human")
+	// ErrUnknownUserType indicates that a Message has a UserType other than Human, Assistant, or System.
+	ErrUnknownUserType = errors.New("unknown user type")
 )
 
 // Validate ensures that |m| is valid. It returns an error if |m| is invalid.
@@ -72,6 +78,70 @@ func (m Messages) Validate() error {
 	return nil
 }
 
+// ToV3Request converts |m| to a request for the messages endpoint. A leading system message becomes the request's
+// System prompt, and Human / Assistant messages become user / assistant messages. A final assistant message with no
+// text (the "\n\nAssistant:" cue required by the completion endpoint) is dropped; a final assistant message with
+// text is kept, and will be continued by the model.
+func (m Messages) ToV3Request(model v3.Model, maxTokens int) (*v3.Request[v3.Message], error) {
+	if len(m) == 0 {
+		return nil, ErrEmptyMessages
+	}
+
+	var req = &v3.Request[v3.Message]{
+		Model:     model,
+		MaxTokens: maxTokens,
+	}
+
+	for i, msg := range m {
+		var role v3.Role
+		switch msg.UserType {
+		case UserTypeSystem:
+			if i != 0 {
+				return nil, ErrBadSystemMessage
+			}
+			req.System = v3.Optional(strings.TrimSpace(msg.Text))
+			continue
+		case UserTypeHuman:
+			role = v3.RoleUser
+		case UserTypeAssistant:
+			role = v3.RoleAssistant
+			if i == len(m)-1 && strings.TrimSpace(msg.Text) == "" {
+				continue
+			}
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrUnknownUserType, msg.UserType)
+		}
+
+		if len(req.Messages) == 0 && role != v3.RoleUser {
+			return nil, ErrMissingHuman
+		}
+
+		var text = strings.TrimSpace(msg.Text)
+		req.Messages = append(req.Messages, &v3.Message{
+			Role:    role,
+			Content: []*v3.MessageContent{{Type: "text", Text: text}},
+		})
+	}
+
+	if len(req.Messages) == 0 {
+		return nil, ErrMissingHuman
+	}
+
+	return req, nil
+}
+
+// Prompt returns |m| in the Human/Assistant format expected by the completion endpoint. It is equivalent to
+// NewPromptFromMessages(m), and likewise does not validate |m|.
+func (m Messages) Prompt() Prompt {
+	return NewPromptFromMessages(m)
+}
+
+// MarshalJSON implements the json.Marshaler interface. |m| is marshaled as its Prompt, i.e. as a single string in the
+// Human/Assistant format, so it can be embedded directly in a request to the completion endpoint.
+func (m Messages) MarshalJSON() ([]byte, error) {
+	return v3.Marshal(m.Prompt())
+}
+
 // NewPromptFromMessages returns a Prompt from a slice of |Message|s by wrapping them in the expected Human/Assistant
 // format. You can use this style to "Put words in Claude's mouth." Note: this function does not validate the messages,
 // and therefore can result in a 4xx response from the API.
@@ -1,7 +1,10 @@
 package anthropic
 
 import (
+	"encoding/json"
 	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
 )
 
 func TestMessageMarshal(t *testing.T) {
@@ -106,6 +109,28 @@ func TestNewPromptFromMessages(t *testing.T) {
 	}
 }
 
+func TestMessagesMarshalJSON(t *testing.T) {
+	var msgs = Messages{
+		{UserType: UserTypeHuman, Text: "Hi"},
+		{UserType: UserTypeAssistant, Text: "Hello!"},
+	}
+
+	var b, err = json.Marshal(struct {
+		Prompt Messages `json:"prompt"`
+	}{Prompt: msgs})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `{"prompt":"\n\nHuman: Hi\n\nAssistant: Hello!"}`
+	if string(b) != exp {
+		t.Errorf("json.Marshal() = %s, want %s", b, exp)
+	}
+	if got := msgs.Prompt(); got != NewPromptFromMessages(msgs) {
+		t.Errorf("Prompt() = %q, want %q", got, NewPromptFromMessages(msgs))
+	}
+}
+
 func TestNewPromptFromString(t *testing.T) {
 	var input = "What's the weather like?"
 	var exp = "\n\nHuman: What's the weather like?\n\nAssistant:"
@@ -122,3 +147,42 @@ func TestNewPromptFromStringWithSystemMessage(t *testing.T) {
 		t.Errorf("NewPromptFromStringWithSystemMessage() = %v, want %v", result, exp)
 	}
 }
+
+func TestMessagesToV3Request(t *testing.T) {
+	var msgs = Messages{
+		{UserType: UserTypeSystem, Text: "You are a test."},
+		{UserType: UserTypeHuman, Text: "Hi"},
+		{UserType: UserTypeAssistant, Text: "Hello"},
+		{UserType: UserTypeHuman, Text: "How are you?"},
+		{UserType: UserTypeAssistant, Text: ""},
+	}
+
+	var req, err = msgs.ToV3Request(v3.Claude3Haiku20240307, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if req.System == nil || *req.System != "You are a test." {
+		t.Errorf("System = %v, want %q", req.System, "You are a test.")
+	}
+	var exp = []struct {
+		role v3.Role
+		text string
+	}{
+		{v3.RoleUser, "Hi"},
+		{v3.RoleAssistant, "Hello"},
+		{v3.RoleUser, "How are you?"},
+	}
+	if len(req.Messages) != len(exp) {
+		t.Fatalf("len(Messages) = %d, want %d", len(req.Messages), len(exp))
+	}
+	for i, m := range req.Messages {
+		if m.Role != exp[i].role || m.Content[0].Text != exp[i].text {
+			t.Errorf("Messages[%d] = %v %q, want %v %q", i, m.Role, m.Content[0].Text, exp[i].role, exp[i].text)
+		}
+	}
+
+	if _, err = (Messages{{UserType: UserTypeAssistant, Text: "Hi"}}).ToV3Request(v3.Claude3Haiku20240307, 100); err != ErrMissingHuman {
+		t.Errorf("ToV3Request() error = %v, wantErr %v", err, ErrMissingHuman)
+	}
+}
@@ -0,0 +1,183 @@
+package anthropic
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"time"
+	"unicode"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// WithReconnect reconnects up to |attempts| times when a stream is interrupted by a transient error (e.g. the
+// connection dropping, an idle timeout, or an overloaded error). The API can't resume a stream, so this is best
+// effort: the request is resent with the text generated so far as an assistant prefill, and the continuation is
+// stitched onto the response and text channel as if the stream had never been interrupted. max_tokens is reduced by
+// the tokens already generated. The response's Usage is the sum of every request's usage, since each continuation
+// resends (and is billed for) the whole prompt.
+//
+// Only text can be continued; if the interrupted response contains any other kind of block (e.g. a tool_use or
+// thinking block), the original error is returned. Whitespace at the seam may differ from an uninterrupted response,
+// since the API rejects prefills that end in whitespace. Content block indices passed to WithPartialJSON restart from
+// 0 in each continuation.
+func WithReconnect(attempts int) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.reconnects = attempts
+	}
+}
+
+// reconnectable returns true if a stream ended by |err| is worth continuing.
+func reconnectable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var re *ResponseError
+	var ne net.Error
+	switch {
+	case errors.As(err, &re):
+		return re.Retryable()
+	case errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, ErrStreamIdleTimeout), errors.Is(err, ErrStreamConnectTimeout):
+		return true
+	case errors.As(err, &ne):
+		return true
+	default:
+		return false
+	}
+}
+
+// continuation returns a streaming request which continues the interrupted response |partial| to |payload|, and
+// whether trailing whitespace was trimmed from the prefill. It returns false if |partial| can't be continued.
+func continuation(payload any, partial *v3.Response) (any, bool, bool) {
+	var sb strings.Builder
+	for _, b := range partial.Content {
+		if b == nil || b.Type != "text" {
+			return nil, false, false
+		}
+		sb.WriteString(b.Text)
+	}
+
+	var text = sb.String()
+	var prefill = strings.TrimRightFunc(text, unicode.IsSpace)
+	var trimmed = len(prefill) < len(text)
+
+	// The output token count is only final at the end of a stream, so fall back to an estimate.
+	var used = v3.EstimateTokens(text)
+	if partial.Usage != nil && partial.Usage.OutputTokens > used {
+		used = partial.Usage.OutputTokens
+	}
+
+	switch r := payload.(type) {
+	case *streamingMessageRequest[v3.Message]:
+		var req = r.Request.Clone()
+		if req.MaxTokens -= used; req.MaxTokens <= 0 {
+			return nil, false, false
+		}
+		if prefill != "" {
+			var block = &v3.MessageContent{Type: "text", Text: prefill}
+			if last := req.Messages[len(req.Messages)-1]; last.Role == v3.RoleAssistant {
+				last.Content = append(last.Content, block)
+			} else {
+				req.Messages = append(req.Messages, &v3.Message{Role: v3.RoleAssistant, Content: []*v3.MessageContent{block}})
+			}
+		}
+
+		return &streamingMessageRequest[v3.Message]{Request: req, Stream: true}, trimmed, true
+	case *streamingMessageRequest[v3.ShortHandMessage]:
+		var req = r.Request.Clone()
+		if req.MaxTokens -= used; req.MaxTokens <= 0 {
+			return nil, false, false
+		}
+		if prefill != "" {
+			if last := req.Messages[len(req.Messages)-1]; last.Role == v3.RoleAssistant {
+				last.Content += prefill
+			} else {
+				req.Messages = append(req.Messages, &v3.ShortHandMessage{Role: v3.RoleAssistant, Content: prefill})
+			}
+		}
+
+		return &streamingMessageRequest[v3.ShortHandMessage]{Request: req, Stream: true}, trimmed, true
+	default:
+		return nil, false, false
+	}
+}
+
+// resume streams the continuation |next| and stitches it onto |resp|. If |trimmed|, leading whitespace is dropped
+// from the continuation, since the interrupted text's trailing whitespace was already sent.
+func (c *Client) resume(ctx context.Context, next any, trimmed bool, resp *v3.Response, cfg *streamConfig, start time.Time, emit func(string)) error {
+	var receive, errs, _, err = c.postStream(ctx, messagesEndpoint, next, betaHeader(cfg.betas))
+	if err != nil {
+		return err
+	}
+
+	var part = &v3.Response{}
+	var a = newMessageAssembler(part, cfg)
+	if onBlock := a.onBlock; onBlock != nil && len(resp.Content) > 0 && resp.Content[len(resp.Content)-1].Type == "text" {
+		// The first text block of the continuation completes the interrupted block, so send them as one.
+		var prefix = resp.Content[len(resp.Content)-1].Text
+		a.onBlock = func(index int, block *v3.MessageContent) {
+			if index == 0 && block.Type == "text" {
+				var cp = *block
+				var text = block.Text
+				if trimmed {
+					text = strings.TrimLeftFunc(text, unicode.IsSpace)
+				}
+				cp.Text = prefix + text
+				block = &cp
+			}
+			onBlock(index, block)
+		}
+	}
+
+	var seam = trimmed
+	err = consumeStream(ctx, receive, errs, a, cfg, start, func(text string) {
+		if seam {
+			if text = strings.TrimLeftFunc(text, unicode.IsSpace); text == "" {
+				return
+			}
+			seam = false
+		}
+		emit(text)
+	})
+	mergeContinuation(resp, part, trimmed)
+
+	return err
+}
+
+// mergeContinuation appends the continuation |part| to |resp|, adding its usage to |resp|'s. The first text block of
+// |part| continues the last text block of |resp|.
+func mergeContinuation(resp, part *v3.Response, trimmed bool) {
+	if resp.ID == "" {
+		// Nothing was received before the interruption.
+		*resp = *part
+		return
+	}
+
+	for i, b := range part.Content {
+		if b == nil {
+			continue
+		}
+		if i == 0 && b.Type == "text" && len(resp.Content) > 0 && resp.Content[len(resp.Content)-1].Type == "text" {
+			var text = b.Text
+			if trimmed {
+				text = strings.TrimLeftFunc(text, unicode.IsSpace)
+			}
+			resp.Content[len(resp.Content)-1].Text += text
+			continue
+		}
+		resp.Content = append(resp.Content, b)
+	}
+
+	resp.StopReason = part.StopReason
+	resp.StopSequence = part.StopSequence
+	if part.Usage != nil {
+		if resp.Usage == nil {
+			resp.Usage = &v3.Usage{}
+		}
+		// Each continuation resends the prompt, so its input (and cache) tokens are billed too.
+		resp.Usage.Add(part.Usage)
+	}
+}
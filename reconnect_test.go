@@ -0,0 +1,113 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+const interruptedStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Once upon a "}}
+
+`
+
+const continuedStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":14,"cache_read_input_tokens":5,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" time."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":3}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestWithReconnect(t *testing.T) {
+	var bodies []string
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		bodies = append(bodies, string(b))
+
+		if len(bodies) == 1 {
+			return newTestResponse(http.StatusOK, interruptedStream), nil
+		}
+		return newTestResponse(http.StatusOK, continuedStream), nil
+	})}))
+
+	var resp, text, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{
+		Model:     v3.Claude3Dot5Sonnet20241022,
+		Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Tell me a story."}}}},
+		MaxTokens: 100,
+	}, WithReconnect(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sb strings.Builder
+	for text != nil || errs != nil {
+		select {
+		case s, ok := <-text:
+			if !ok {
+				text = nil
+				continue
+			}
+			sb.WriteString(s)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			t.Fatal(err)
+		}
+	}
+
+	const exp = "Once upon a time."
+	if got := sb.String(); got != exp {
+		t.Errorf("streamed text = %q, want %q", got, exp)
+	}
+	if got := resp.Content[0].Text; got != exp {
+		t.Errorf("resp.Content[0].Text = %q, want %q", got, exp)
+	}
+	if resp.StopReason != v3.StopReasonEndTurn {
+		t.Errorf("resp.StopReason = %v, want %v", resp.StopReason, v3.StopReasonEndTurn)
+	}
+	// Both requests' usage is billed, including the continuation's resent prompt.
+	if exp := (v3.Usage{InputTokens: 24, CacheReadInputTokens: 5, OutputTokens: 4}); resp.Usage == nil || *resp.Usage != exp {
+		t.Errorf("resp.Usage = %+v, want %+v", resp.Usage, exp)
+	}
+
+	if len(bodies) != 2 {
+		t.Fatalf("sent %d requests, want 2", len(bodies))
+	}
+	var req = &v3.Request[v3.Message]{}
+	if err = json.Unmarshal([]byte(bodies[1]), req); err != nil {
+		t.Fatal(err)
+	}
+	var last = req.Messages[len(req.Messages)-1]
+	if last.Role != v3.RoleAssistant || last.Content[0].Text != "Once upon a" {
+		t.Errorf("continuation prefill = %v %q, want assistant %q", last.Role, last.Content[0].Text, "Once upon a")
+	}
+}
@@ -0,0 +1,32 @@
+package anthropic
+
+// Region is a geography Bedrock requests can be restricted to for data residency. See BedrockClient.SetRegion.
+type Region string
+
+const (
+	// RegionGlobal is the default: requests may be served from any region.
+	RegionGlobal Region = ""
+	// RegionUS restricts requests to the United States.
+	RegionUS Region = "us"
+	// RegionEU restricts requests to the European Union.
+	RegionEU Region = "eu"
+	// RegionAPAC restricts requests to the Asia Pacific region.
+	RegionAPAC Region = "apac"
+)
+
+// SetRegion restricts requests to |r| using Bedrock's cross-region inference profiles, whose model IDs are the
+// model's ID prefixed with the region (e.g. "eu.anthropic.claude-3-5-sonnet-20240620-v1:0"). Requests are served
+// from any of the region's AWS regions; the AWS region the client itself connects to is set through its aws.Config.
+// Inference profiles are only available for the Claude 3 and later models.
+func (bc *BedrockClient) SetRegion(r Region) {
+	bc.region = r
+}
+
+// modelID returns the Bedrock model ID of the model with ID |id|, in the client's region.
+func (bc *BedrockClient) modelID(id string) string {
+	if bc.region == RegionGlobal {
+		return id
+	}
+
+	return string(bc.region) + "." + id
+}
@@ -1,5 +1,9 @@
 package anthropic
 
+import (
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
 // Request represents the request to the API.
 type Request struct {
 	// Prompt is the prompt you want Claude to complete. Required.
@@ -32,6 +36,13 @@ type Request struct {
 	Metadata *Metadata `json:"metadata,omitempty"`
 }
 
+// Validate ensures that |r| is valid. It returns an error if |r| is invalid. Currently, only its StopSequences are
+// checked (see v3.ValidateStopSequences); "\n\nHuman:" is the Text Completions API's own stop sequence, so it's
+// allowed without a warning.
+func (r *Request) Validate() error {
+	return v3.ValidateStopSequences(r.StopSequences)
+}
+
 // Optional returns a pointer to |v|. Used to easily assign literals to optional parameters.
 func Optional[T any](v T) *T {
 	return &v
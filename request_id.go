@@ -0,0 +1,57 @@
+package anthropic
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net/http"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// requestIDHeaderName is the response header holding the id the API assigned to a request.
+const requestIDHeaderName = "request-id"
+
+// requestIDs identify a request: by the id the API assigned to it, and by the id the client sent with it (if it was
+// configured to, see WithRequestIDHeader).
+type requestIDs struct {
+	server string
+	client string
+}
+
+// requestIDs returns the ids of the request which |resp| responds to.
+func (c *Client) requestIDs(resp *http.Response) requestIDs {
+	var ids = requestIDs{server: resp.Header.Get(requestIDHeaderName)}
+	if c.requestIDHeader != "" && resp.Request != nil {
+		ids.client = resp.Request.Header.Get(c.requestIDHeader)
+	}
+
+	return ids
+}
+
+// setOn sets the ids of |resp|.
+func (ids requestIDs) setOn(resp *v3.Response) {
+	resp.RequestID = ids.server
+	resp.ClientRequestID = ids.client
+}
+
+// annotate sets the ids of |err|, if it's a *ResponseError which doesn't have them (e.g. because it was sent
+// mid-stream).
+func (ids requestIDs) annotate(err error) {
+	var re *ResponseError
+	if errors.As(err, &re) && re.RequestID == "" && re.ClientRequestID == "" {
+		re.RequestID = ids.server
+		re.ClientRequestID = ids.client
+	}
+}
+
+// newRequestID returns a random (version 4) UUID.
+func newRequestID() string {
+	var b [16]byte
+	// crypto/rand.Read never returns an error on supported platforms.
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
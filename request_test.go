@@ -0,0 +1,29 @@
+package anthropic
+
+import (
+	"errors"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestRequestValidate(t *testing.T) {
+	var tests = []struct {
+		name          string
+		stopSequences []string
+		err           error
+	}{
+		{name: "None"},
+		{name: "Human Turn", stopSequences: []string{"\n\nHuman:", "END"}},
+		{name: "Empty", stopSequences: []string{""}, err: v3.ErrInvalidStopSequence},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &Request{Prompt: "\n\nHuman: Hi\n\nAssistant:", StopSequences: tt.stopSequences}
+			if err := r.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Request.Validate() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
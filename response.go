@@ -1,5 +1,7 @@
 package anthropic
 
+import v3 "github.com/fabiustech/anthropic/v3"
+
 // Response represents the response from the API.
 type Response struct {
 	// Completion is he resulting completion up to and excluding the stop sequences.
@@ -11,3 +13,9 @@ type Response struct {
 	// Model is the model that performed the completion.
 	Model Model `json:"model"`
 }
+
+// EstimatedOutputTokens returns an estimate of the number of tokens in the completion. The completion endpoint does
+// not report token usage, so this is derived from the completion text and is only approximate.
+func (r *Response) EstimatedOutputTokens() int {
+	return v3.EstimateTokens(r.Completion)
+}
@@ -0,0 +1,26 @@
+package anthropic
+
+import "testing"
+
+func TestResponseEstimatedOutputTokens(t *testing.T) {
+	var tests = []struct {
+		name       string
+		completion string
+		exp        int
+	}{
+		{name: "Empty", completion: "", exp: 0},
+		{name: "Partial Token", completion: "Hi", exp: 1},
+		{name: "Whole Tokens", completion: "Hello, world", exp: 3},
+		// Characters, not bytes, are counted.
+		{name: "Multibyte", completion: "héllo", exp: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &Response{Completion: tt.completion}
+			if got := r.EstimatedOutputTokens(); got != tt.exp {
+				t.Errorf("EstimatedOutputTokens() = %d, want %d", got, tt.exp)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package anthropic
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// retryBaseDelay is the delay before the first retry. It doubles with each subsequent retry.
+	retryBaseDelay = 500 * time.Millisecond
+	// retryMaxDelay caps the delay between retries.
+	retryMaxDelay = 8 * time.Second
+)
+
+// shouldRetry returns true if a request which returned |resp| and |err| should be retried: connection errors, rate
+// limits (429), and server errors (5xx, including 529 overloaded).
+func shouldRetry(resp *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// retryDelay returns how long to wait before retry |attempt| (starting from 0). The server's |Retry-After| header is
+// honored if |resp| has one; otherwise the delay backs off exponentially.
+func retryDelay(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if secs, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+
+	var d = retryBaseDelay << attempt
+	if d <= 0 || d > retryMaxDelay {
+		return retryMaxDelay
+	}
+
+	return d
+}
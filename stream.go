@@ -0,0 +1,705 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// StreamOption configures optional behavior of a streaming message request.
+type StreamOption func(*streamConfig)
+
+// streamConfig holds the configuration built from a request's StreamOptions.
+type streamConfig struct {
+	stats         *StreamStats
+	lastEventID   string
+	onPartialJSON func(index int, partial string)
+	separator     string
+	reconnects    int
+	// onBlock, if set, is called with each content block once it is complete.
+	onBlock func(index int, block *v3.MessageContent)
+	// onChange, if set, is called each time a content block is started, changed, or completed.
+	onChange func(index int, block *v3.MessageContent, partialInput string, done bool)
+	// onFinalUsage, if set, is called with the final usage of a stream which completes successfully.
+	onFinalUsage func(usage v3.Usage)
+	// errorOnEmpty makes a stream which completes without content fail with ErrEmptyResponse.
+	errorOnEmpty bool
+	// validate makes a stream whose response isn't internally consistent fail. See v3.Response.Validate.
+	validate bool
+	// maxOutputTokens is the output token budget of the stream. If 0, there is no budget.
+	maxOutputTokens int
+	// betas are the request's betas. They're set by the stream, not by an option.
+	betas []string
+	// skipBadEvents makes malformed events non-fatal.
+	skipBadEvents bool
+	// report is called with each non-fatal error. It's set by the stream, not by an option.
+	report func(err error)
+}
+
+// StreamError is an error caused by a single malformed event of a stream (e.g. one whose data can't be parsed). By
+// default it's fatal, ending the stream like any other error; with WithSkipBadEvents, it's reported and the stream
+// continues with the next event, so consumers should keep receiving after an error for which IsFatal returns false.
+// Errors other than a StreamError (e.g. network failures, API error events, or context cancellation) always end the
+// stream.
+type StreamError struct {
+	// Event is the type of the malformed event, if known.
+	Event string
+	// Err is the underlying error. It wraps ErrBadEvent if the event was well-formed JSON that didn't make sense in the
+	// stream (e.g. a delta for a content block which wasn't started).
+	Err error
+
+	fatal bool
+}
+
+// Error implements the error interface.
+func (e *StreamError) Error() string {
+	if e.Event == "" {
+		return fmt.Sprintf("stream event: %v", e.Err)
+	}
+
+	return fmt.Sprintf("stream event %s: %v", e.Event, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// IsFatal returns true if the error ended the stream.
+func (e *StreamError) IsFatal() bool {
+	return e.fatal
+}
+
+// badEvent handles the error |err| caused by an event of type |typ|. It returns the fatal *StreamError which should
+// end the stream or, if bad events are skipped, reports a non-fatal one and returns nil.
+func (cfg *streamConfig) badEvent(typ eventType, err error) error {
+	var se = &StreamError{Event: string(typ), Err: err, fatal: !cfg.skipBadEvents}
+	if se.fatal {
+		return se
+	}
+	if cfg.report != nil {
+		cfg.report(se)
+	}
+
+	return nil
+}
+
+// header returns the additional request headers required by |cfg|.
+func (cfg *streamConfig) header() http.Header {
+	var h = make(http.Header)
+	if len(cfg.betas) > 0 {
+		h[betaHeaderName] = cfg.betas
+	}
+	if cfg.lastEventID != "" {
+		h.Set(lastEventIDHeader, cfg.lastEventID)
+	}
+
+	return h
+}
+
+// newStreamConfig applies |opts| to a new streamConfig.
+func newStreamConfig(opts []StreamOption) *streamConfig {
+	var cfg = &streamConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	return cfg
+}
+
+// StreamStats holds latency and throughput statistics for a streaming request.
+type StreamStats struct {
+	// TimeToFirstToken is the time from sending the request until the first content delta was received. It is zero if
+	// no content was generated.
+	TimeToFirstToken time.Duration
+	// Duration is the time from sending the request until the stream completed.
+	Duration time.Duration
+	// OutputTokens is the number of output tokens reported by the API.
+	OutputTokens int
+	// LastEventID is the id of the last event received, if the API sent event ids. It can be passed to
+	// WithLastEventID to ask the server to resume the stream after that event.
+	LastEventID string
+}
+
+// OutputTokensPerSecond returns the generation throughput: the number of output tokens divided by the time spent
+// generating them (i.e. excluding the time to first token).
+func (s *StreamStats) OutputTokensPerSecond() float64 {
+	var d = s.Duration - s.TimeToFirstToken
+	if d <= 0 {
+		return 0
+	}
+
+	return float64(s.OutputTokens) / d.Seconds()
+}
+
+// WithStreamStats records latency and throughput statistics for the stream into |stats|. |stats| is fully populated
+// by the time the stream's channels are closed, and must not be read before then.
+func WithStreamStats(stats *StreamStats) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.stats = stats
+	}
+}
+
+// WithPartialJSON calls |fn| each time a fragment of a tool_use block's input is received, with the index of the
+// block and all of the input received so far. The accumulated input is generally not valid JSON until the block is
+// complete, so it should be fed to a lenient / streaming JSON parser (e.g. to render fields of a forced tool's input
+// as they complete). |fn| is called from the stream's goroutine and should return quickly.
+func WithPartialJSON(fn func(index int, partial string)) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.onPartialJSON = fn
+	}
+}
+
+// WithFinalUsage calls |fn| with the response's usage once it's final: when the stream completes successfully, after
+// the last text has been sent and before the channels are closed. Until then, the response's Usage only holds the
+// counts received so far (the output token count is only final at the end of the stream). |fn| isn't called if the
+// stream fails. It's called from the stream's goroutine and should return quickly.
+func WithFinalUsage(fn func(usage v3.Usage)) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.onFinalUsage = fn
+	}
+}
+
+// WithBlockSeparator sends |sep| on the text channel between consecutive text blocks, so consumers can tell where one
+// block ends and the next begins (by default, the text of consecutive blocks runs together).
+func WithBlockSeparator(sep string) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.separator = sep
+	}
+}
+
+// BlockUpdate is a change to one of the content blocks of a streaming response. See NewStreamingMessageBlockUpdates.
+type BlockUpdate struct {
+	// Index is the index of the block which changed.
+	Index int
+	// Blocks holds every block started so far, keyed by index, as of this update. The blocks are snapshots which are
+	// never modified once sent, so they can be read (e.g. rendered by another goroutine) without synchronization.
+	Blocks map[int]*v3.MessageContent
+	// PartialInput is the input received so far for the block at Index, if it's an incomplete tool_use (or
+	// server_tool_use) block. The block's Input is only set once it's complete, and PartialInput generally isn't valid
+	// JSON until then (see WithPartialJSON).
+	PartialInput string
+	// Done is true if the block at Index is complete.
+	Done bool
+}
+
+// ToolInputStream is the input of a tool_use (or server_tool_use) block of a streaming response, read as it's
+// generated. See NewStreamingToolInputs.
+type ToolInputStream struct {
+	// Index is the index of the block.
+	Index int
+	// ID is the id of the tool use.
+	ID string
+	// Name is the name of the tool.
+	Name string
+	// Reader reads the block's raw input JSON as its fragments arrive. It returns io.EOF once the block is complete,
+	// or io.ErrUnexpectedEOF if the stream ends first. Fragments are buffered, so it never holds up the stream, and
+	// can be read at any pace (e.g. by a json.Decoder).
+	io.Reader
+}
+
+// toolInputBuffer is the Reader of a ToolInputStream: an unbounded buffer which is written by the stream's goroutine
+// and read by the caller.
+type toolInputBuffer struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+	buf  bytes.Buffer
+	// err is returned once buf is drained. It's set when the block is complete or the stream ends.
+	err error
+}
+
+// newToolInputBuffer returns an empty toolInputBuffer.
+func newToolInputBuffer() *toolInputBuffer {
+	var b = &toolInputBuffer{}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// Read implements io.Reader. It blocks until input is available or the buffer is closed.
+func (b *toolInputBuffer) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.buf.Len() == 0 && b.err == nil {
+		b.cond.Wait()
+	}
+	if b.buf.Len() > 0 {
+		return b.buf.Read(p)
+	}
+
+	return 0, b.err
+}
+
+// write appends |s| to the buffer.
+func (b *toolInputBuffer) write(s string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf.WriteString(s)
+	b.cond.Broadcast()
+}
+
+// close makes reads return |err| once the buffer is drained. Only the first call has an effect.
+func (b *toolInputBuffer) close(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.err == nil {
+		b.err = err
+	}
+	b.cond.Broadcast()
+}
+
+// ErrEmptyResponse is returned by streams configured with WithErrorOnEmptyResponse when the response has no content.
+var ErrEmptyResponse = errors.New("response has no content")
+
+// WithErrorOnEmptyResponse makes a stream which completes successfully but without any content blocks (e.g. an
+// immediate refusal, or an empty generation) fail with ErrEmptyResponse, so callers can handle it explicitly rather
+// than assuming resp.Content is non-empty. The response is still populated (e.g. with its StopReason).
+func WithErrorOnEmptyResponse() StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.errorOnEmpty = true
+	}
+}
+
+// WithResponseValidation makes a stream which completes successfully fail if the reconstructed response isn't
+// internally consistent (see v3.Response.Validate), e.g. NewMessageStreamedBatchResponse then returns the error rather
+// than the response. The response is still populated. Note that it also fails on stop reasons this package doesn't
+// know yet.
+func WithResponseValidation() StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.validate = true
+	}
+}
+
+// WithMaxOutputTokens stops the stream once it has generated about |n| output tokens, independent of the request's
+// MaxTokens: a hard client-side cap on the cost of open-ended generation, which works even when the caller doesn't
+// control the request. Output is counted using the usage reported by the API or, since that is only reported at the
+// end of the stream, an estimate of the generated text, thinking, and tool input (see v3.EstimateTokens). When the
+// budget is exceeded, the stream is closed and the channels are closed without an error; the partial response has the
+// synthetic stop reason v3.StopReasonOutputBudget.
+func WithMaxOutputTokens(n int) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.maxOutputTokens = n
+	}
+}
+
+// WithSkipBadEvents makes malformed events non-fatal: rather than ending the stream, each is reported on the error
+// channel as a *StreamError for which IsFatal returns false, and skipped. Consumers must keep receiving from both
+// channels after such an error. The response may be incomplete if the skipped event carried content.
+func WithSkipBadEvents() StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.skipBadEvents = true
+	}
+}
+
+// lastEventIDHeader is the standard server-sent events header used to resume a stream.
+const lastEventIDHeader = "Last-Event-ID"
+
+// WithLastEventID sends |id| in the |Last-Event-ID| header, asking the server to resume the stream after the event
+// with that id. Note: the Anthropic API does not currently send event ids or support resumption; this exists so
+// streams can be resumed against servers (e.g. gateways) that do.
+func WithLastEventID(id string) StreamOption {
+	return func(cfg *streamConfig) {
+		cfg.lastEventID = id
+	}
+}
+
+// v3Event is the data of a message stream event. Only the fields relevant to the event's type are set.
+type v3Event struct {
+	Type         string             `json:"type"`
+	Index        int                `json:"index"`
+	Message      *v3.Response       `json:"message,omitempty"`
+	Delta        *v3Delta           `json:"delta,omitempty"`
+	ContentBlock *v3.MessageContent `json:"content_block,omitempty"`
+	Usage        *v3.Usage          `json:"usage,omitempty"`
+}
+
+// v3Delta is the delta of a "content_block_delta" or "message_delta" event.
+type v3Delta struct {
+	// Type is the type of a content block delta, e.g. "text_delta" or "input_json_delta".
+	Type string `json:"type"`
+	// Text is set for "text_delta" deltas.
+	Text string `json:"text,omitempty"`
+	// PartialJSON is set for "input_json_delta" deltas. It is a fragment of a tool_use block's input, and is only valid
+	// JSON once all fragments for the block have been concatenated.
+	PartialJSON string `json:"partial_json,omitempty"`
+	// Thinking is set for "thinking_delta" deltas.
+	Thinking string `json:"thinking,omitempty"`
+	// Signature is set for "signature_delta" deltas, which are sent just before a thinking block's
+	// content_block_stop.
+	Signature string `json:"signature,omitempty"`
+	// StopReason is set for "message_delta" events.
+	StopReason v3.StopReason `json:"stop_reason,omitempty"`
+	// StopSequence is set for "message_delta" events.
+	StopSequence *string `json:"stop_sequence,omitempty"`
+	// Container is set for "message_delta" events of requests which used a code execution container.
+	Container *v3.Container `json:"container,omitempty"`
+}
+
+const (
+	deltaTypeText      = "text_delta"
+	deltaTypeInputJSON = "input_json_delta"
+	deltaTypeThinking  = "thinking_delta"
+	deltaTypeSignature = "signature_delta"
+)
+
+// messageAssembler assembles a *v3.Response from the events of a message stream.
+type messageAssembler struct {
+	resp *v3.Response
+	// partialJSON holds the accumulated "input_json_delta" fragments for each content block index.
+	partialJSON map[int][]byte
+	// onPartialJSON, if set, is called with the accumulated input each time an "input_json_delta" is applied.
+	onPartialJSON func(index int, partial string)
+	// separator is returned before the text of each text block after the first.
+	separator string
+	// onBlock, if set, is called with each content block once it is complete.
+	onBlock func(index int, block *v3.MessageContent)
+	// onChange, if set, is called each time a content block is started, changed, or completed.
+	onChange func(index int, block *v3.MessageContent, partialInput string, done bool)
+	// textBlocks is the number of text blocks started so far.
+	textBlocks int
+	// estimatedOutput is the estimated number of output tokens in the deltas applied so far.
+	estimatedOutput int
+}
+
+// newMessageAssembler returns a messageAssembler which assembles into |resp|.
+func newMessageAssembler(resp *v3.Response, cfg *streamConfig) *messageAssembler {
+	return &messageAssembler{
+		resp:          resp,
+		partialJSON:   make(map[int][]byte),
+		onPartialJSON: cfg.onPartialJSON,
+		separator:     cfg.separator,
+		onBlock:       cfg.onBlock,
+		onChange:      cfg.onChange,
+	}
+}
+
+// changed calls onChange (if set) for the content block at |index|.
+func (a *messageAssembler) changed(index int, block *v3.MessageContent, done bool) {
+	if a.onChange != nil {
+		a.onChange(index, block, string(a.partialJSON[index]), done)
+	}
+}
+
+// outputTokens returns the number of output tokens generated so far: the count reported by the API, or the estimate
+// of the applied deltas if that's larger (the API only reports the final count at the end of the stream).
+func (a *messageAssembler) outputTokens() int {
+	if a.resp.Usage != nil && a.resp.Usage.OutputTokens > a.estimatedOutput {
+		return a.resp.Usage.OutputTokens
+	}
+
+	return a.estimatedOutput
+}
+
+// mergeUsage merges the usage |delta| of a "message_delta" event into |u|. The output token count is cumulative, so
+// it's always taken; the other fields are only taken if set, since they're usually only sent in message_start, but the
+// API may send their final values at the end of the stream.
+func mergeUsage(u, delta *v3.Usage) {
+	u.OutputTokens = delta.OutputTokens
+	if delta.InputTokens != 0 {
+		u.InputTokens = delta.InputTokens
+	}
+	if delta.CacheCreationInputTokens != 0 {
+		u.CacheCreationInputTokens = delta.CacheCreationInputTokens
+	}
+	if delta.CacheReadInputTokens != 0 {
+		u.CacheReadInputTokens = delta.CacheReadInputTokens
+	}
+	if delta.ServiceTier != "" {
+		u.ServiceTier = delta.ServiceTier
+	}
+}
+
+// block returns the content block at |index|, or an error if no block was started at that index.
+func (a *messageAssembler) block(index int) (*v3.MessageContent, error) {
+	if index < 0 || index >= len(a.resp.Content) || a.resp.Content[index] == nil {
+		return nil, fmt.Errorf("%w: no content block at index %d", ErrBadEvent, index)
+	}
+
+	return a.resp.Content[index], nil
+}
+
+// apply applies the message, content block start / delta / stop event |e| to the response. It returns the text
+// generated by the event, if any. Only the text of "text" blocks is returned; in particular, Claude's reasoning in
+// "thinking" blocks never is.
+func (a *messageAssembler) apply(e *event) (string, error) {
+	var ev = &v3Event{}
+	if err := json.Unmarshal(e.Data, ev); err != nil {
+		return "", err
+	}
+
+	switch e.Type {
+	case eventTypeMessageStart:
+		if ev.Message == nil {
+			return "", fmt.Errorf("%w: message_start without message", ErrBadEvent)
+		}
+		// The request ids aren't part of the message, so they're kept.
+		var requestID, clientRequestID = a.resp.RequestID, a.resp.ClientRequestID
+		*a.resp = *ev.Message
+		a.resp.RequestID, a.resp.ClientRequestID = requestID, clientRequestID
+	case eventTypeMessageDelta:
+		if ev.Delta != nil {
+			a.resp.StopReason = ev.Delta.StopReason
+			a.resp.StopSequence = ev.Delta.StopSequence
+			if ev.Delta.Container != nil {
+				a.resp.Container = ev.Delta.Container
+			}
+		}
+		if ev.Usage != nil {
+			if a.resp.Usage == nil {
+				a.resp.Usage = &v3.Usage{}
+			}
+			mergeUsage(a.resp.Usage, ev.Usage)
+		}
+	case eventTypeContentBlockStart:
+		if ev.ContentBlock == nil || ev.Index < 0 {
+			return "", fmt.Errorf("%w: invalid content_block_start", ErrBadEvent)
+		}
+		for len(a.resp.Content) <= ev.Index {
+			a.resp.Content = append(a.resp.Content, nil)
+		}
+		a.resp.Content[ev.Index] = ev.ContentBlock
+		a.changed(ev.Index, ev.ContentBlock, false)
+
+		if ev.ContentBlock.Type != "text" {
+			return "", nil
+		}
+
+		var text = ev.ContentBlock.Text
+		if a.textBlocks > 0 {
+			text = a.separator + text
+		}
+		a.textBlocks++
+
+		return text, nil
+	case eventTypeContentBlockDelta:
+		var block, err = a.block(ev.Index)
+		if err != nil {
+			return "", err
+		}
+		if ev.Delta == nil {
+			return "", fmt.Errorf("%w: content_block_delta without delta", ErrBadEvent)
+		}
+
+		switch ev.Delta.Type {
+		case deltaTypeText:
+			if block.Type != "text" {
+				return "", nil
+			}
+			block.Text += ev.Delta.Text
+			a.estimatedOutput += v3.EstimateTokens(ev.Delta.Text)
+			a.changed(ev.Index, block, false)
+			return ev.Delta.Text, nil
+		case deltaTypeInputJSON:
+			a.partialJSON[ev.Index] = append(a.partialJSON[ev.Index], ev.Delta.PartialJSON...)
+			a.estimatedOutput += v3.EstimateTokens(ev.Delta.PartialJSON)
+			if a.onPartialJSON != nil {
+				a.onPartialJSON(ev.Index, string(a.partialJSON[ev.Index]))
+			}
+		case deltaTypeThinking:
+			block.Thinking += ev.Delta.Thinking
+			a.estimatedOutput += v3.EstimateTokens(ev.Delta.Thinking)
+		case deltaTypeSignature:
+			block.Signature += ev.Delta.Signature
+		default:
+			// Ignore delta types we don't know how to assemble.
+			return "", nil
+		}
+		a.changed(ev.Index, block, false)
+	case eventTypeContentBlockStop:
+		var block, err = a.block(ev.Index)
+		if err != nil {
+			return "", err
+		}
+		if in, ok := a.partialJSON[ev.Index]; ok {
+			delete(a.partialJSON, ev.Index)
+			if len(in) > 0 {
+				block.Input = in
+			}
+		}
+		if a.onBlock != nil {
+			a.onBlock(ev.Index, block)
+		}
+		a.changed(ev.Index, block, true)
+	}
+
+	return "", nil
+}
+
+// streamMessages posts |payload| to the messages endpoint as a streaming request and assembles the response from the
+// received events. See NewStreamingMessageRequest for details on the returned values.
+func (c *Client) streamMessages(parent context.Context, betas []string, payload any, opts []StreamOption) (*v3.Response, <-chan string, <-chan error, error) {
+	var cfg = newStreamConfig(opts)
+	cfg.betas = betas
+	var start = time.Now()
+
+	// The stream is canceled once the goroutine below returns, which may be before the API finishes sending it (e.g.
+	// when the output budget is exceeded).
+	var ctx, cancel = context.WithCancel(parent)
+	var receive, errs, ids, err = c.postStream(ctx, messagesEndpoint, payload, cfg.header())
+	if err != nil {
+		cancel()
+		return nil, nil, nil, err
+	}
+	var respCh = make(chan string)
+	// At most one fatal error is sent, so buffering it means the goroutine never blocks on it (unless the caller
+	// doesn't receive the non-fatal errors of WithSkipBadEvents).
+	var errCh = make(chan error, 1)
+
+	var resp = &v3.Response{}
+	ids.setOn(resp)
+
+	go func() {
+		defer cancel()
+		defer close(respCh)
+		defer close(errCh)
+		if cfg.stats != nil {
+			// Deferred last so the stats are final before the channels are closed.
+			defer func() {
+				cfg.stats.Duration = time.Since(start)
+				if resp.Usage != nil {
+					cfg.stats.OutputTokens = resp.Usage.OutputTokens
+				}
+			}()
+		}
+
+		var emit = func(text string) {
+			send(ctx, respCh, text)
+		}
+		cfg.report = func(err error) {
+			send(ctx, errCh, err)
+		}
+
+		var err = consumeStream(ctx, receive, errs, newMessageAssembler(resp, cfg), cfg, start, emit)
+		for attempt := 0; err != nil && attempt < cfg.reconnects && reconnectable(ctx, err); attempt++ {
+			var next, trimmed, ok = continuation(payload, resp)
+			if !ok {
+				break
+			}
+			err = c.resume(ctx, next, trimmed, resp, cfg, start, emit)
+		}
+		if err == nil && cfg.errorOnEmpty && len(resp.Content) == 0 {
+			err = ErrEmptyResponse
+		}
+		if err == nil && cfg.validate {
+			err = resp.Validate()
+		}
+		if err == nil {
+			err = c.checkResponse(resp)
+		}
+		if err == nil && cfg.onFinalUsage != nil && resp.Usage != nil {
+			cfg.onFinalUsage(*resp.Usage)
+		}
+		if err != nil {
+			ids.annotate(err)
+			// The buffer may be full of a non-fatal error the caller hasn't received yet.
+			select {
+			case errCh <- err:
+			default:
+				send(ctx, errCh, err)
+			}
+		}
+	}()
+
+	return resp, respCh, errCh, nil
+}
+
+// send sends |v| on |ch|, or returns false without sending if |ctx| is done first. Streaming goroutines send with it so
+// that a caller which stops receiving (and cancels |ctx|) doesn't leave them blocked forever.
+func send[T any](ctx context.Context, ch chan<- T, v T) bool {
+	select {
+	case ch <- v:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// consumeStream applies the events received on |receive| to |a| until the message_stop event, sending any generated
+// text to |emit|. It returns the error that ended the stream early, if any.
+func consumeStream(ctx context.Context, receive <-chan []byte, errs <-chan error, a *messageAssembler, cfg *streamConfig, start time.Time, emit func(string)) error {
+	for {
+		select {
+		case b, ok := <-receive:
+			if !ok {
+				// The stream ended without a message_stop event. If it was ended by an error (e.g. cancellation), the
+				// error was sent before |receive| was closed, so return it rather than a generic one.
+				select {
+				case err, ok := <-errs:
+					if ok {
+						return err
+					}
+				default:
+				}
+				return io.ErrUnexpectedEOF
+			}
+
+			var events, err = parseEvents(b)
+			if err != nil {
+				if err = cfg.badEvent("", err); err != nil {
+					return err
+				}
+				continue
+			}
+
+			for _, e := range events {
+				if cfg.stats != nil && e.ID != "" {
+					cfg.stats.LastEventID = e.ID
+				}
+
+				switch e.Type {
+				case eventTypeMessageStart, eventTypeMessageDelta, eventTypeContentBlockStart, eventTypeContentBlockDelta, eventTypeContentBlockStop:
+					var text string
+					text, err = a.apply(e)
+					if err != nil {
+						if err = cfg.badEvent(e.Type, err); err != nil {
+							return err
+						}
+						continue
+					}
+
+					if e.Type == eventTypeContentBlockDelta && cfg.stats != nil && cfg.stats.TimeToFirstToken == 0 {
+						cfg.stats.TimeToFirstToken = time.Since(start)
+					}
+					if text != "" {
+						emit(text)
+					}
+					if cfg.maxOutputTokens > 0 && a.outputTokens() > cfg.maxOutputTokens {
+						a.resp.StopReason = v3.StopReasonOutputBudget
+						a.resp.StopSequence = nil
+						return nil
+					}
+				case eventTypeMessageStop:
+					return nil
+				case eventTypeError:
+					return streamedError(e.Data)
+				case eventTypePing:
+					// Do nothing.
+				default:
+					// The API adds new event types from time to time; ignore them rather than end the stream.
+					slog.Debug("ignoring unknown stream event", "type", e.Type)
+				}
+			}
+		case err, ok := <-errs:
+			if !ok {
+				// Wait for |receive| to be closed.
+				errs = nil
+				continue
+			}
+
+			return err
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,977 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fabiustech/anthropic/anthropictest"
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// newStreamTestClient returns a Client whose requests are all answered with an SSE stream containing |body|.
+func newStreamTestClient(body string) *Client {
+	return NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var resp = newTestResponse(http.StatusOK, body)
+		resp.Header.Set("Content-Type", "text/event-stream")
+		return resp, nil
+	})}))
+}
+
+const toolUseStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","stop_reason":null,"stop_sequence":null,"usage":{"input_tokens":25,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: ping
+data: {"type": "ping"}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Let me check"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" the weather."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"toolu_1","name":"get_weather","input":{}}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"location\": \"San Fra"}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"ncisco, CA\"}"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"tool_use","stop_sequence":null},"usage":{"output_tokens":89}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestNewMessageStreamedBatchResponse(t *testing.T) {
+	var c = newStreamTestClient(toolUseStream)
+
+	var resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.ID != "msg_1" || resp.Model != v3.Claude3Dot5Sonnet20241022 || resp.Role != v3.RoleAssistant {
+		t.Errorf("message_start fields not set: %+v", resp)
+	}
+	if resp.StopReason != v3.StopReasonToolUse {
+		t.Errorf("StopReason = %v, want %v", resp.StopReason, v3.StopReasonToolUse)
+	}
+	if resp.Usage == nil || resp.Usage.InputTokens != 25 || resp.Usage.OutputTokens != 89 {
+		t.Errorf("Usage = %+v, want input 25 / output 89", resp.Usage)
+	}
+	if len(resp.Content) != 2 {
+		t.Fatalf("len(Content) = %d, want 2", len(resp.Content))
+	}
+	if got := resp.Content[0].Text; got != "Let me check the weather." {
+		t.Errorf("Content[0].Text = %q", got)
+	}
+	var tool = resp.Content[1]
+	if tool.Type != "tool_use" || tool.ID != "toolu_1" || tool.Name != "get_weather" {
+		t.Errorf("Content[1] = %+v", tool)
+	}
+	if got := string(tool.Input); got != `{"location": "San Francisco, CA"}` {
+		t.Errorf("Content[1].Input = %s", got)
+	}
+}
+
+func TestNewStreamingMessageBlocks(t *testing.T) {
+	var c = newStreamTestClient(toolUseStream)
+
+	var _, blocks, errs, err = c.NewStreamingMessageBlocks(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*v3.MessageContent
+	for blocks != nil || errs != nil {
+		select {
+		case b, ok := <-blocks:
+			if !ok {
+				blocks = nil
+				continue
+			}
+			got = append(got, b)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			t.Fatal(err)
+		}
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("received %d blocks, want 2", len(got))
+	}
+	if got[0].Type != "text" || got[0].Text != "Let me check the weather." {
+		t.Errorf("blocks[0] = %+v, want the complete text block", got[0])
+	}
+	if got[1].Type != "tool_use" || string(got[1].Input) != `{"location": "San Francisco, CA"}` {
+		t.Errorf("blocks[1] = %+v, want the complete tool_use block", got[1])
+	}
+}
+
+func TestNewStreamingMessageBlockUpdates(t *testing.T) {
+	var c = newStreamTestClient(toolUseStream)
+
+	var _, updates, errs, err = c.NewStreamingMessageBlockUpdates(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []*BlockUpdate
+	for updates != nil || errs != nil {
+		select {
+		case u, ok := <-updates:
+			if !ok {
+				updates = nil
+				continue
+			}
+			got = append(got, u)
+		case err, ok := <-errs:
+			if !ok {
+				errs = nil
+				continue
+			}
+			t.Fatal(err)
+		}
+	}
+
+	// Each block is started, changed by each of its deltas, and completed.
+	if len(got) != 9 {
+		t.Fatalf("received %d updates, want 9", len(got))
+	}
+	if got[0].Index != 0 || got[0].Blocks[0].Text != "" || got[0].Done {
+		t.Errorf("updates[0] = %+v, want the unmodified start of the text block", got[0])
+	}
+	if u := got[2]; u.Index != 0 || u.Blocks[0].Text != "Let me check the weather." || u.Done {
+		t.Errorf("updates[2] = %+v, want the text block's last delta", u)
+	}
+	if u := got[6]; u.Index != 1 || u.PartialInput != `{"location": "San Fra` || len(u.Blocks) != 2 {
+		t.Errorf("updates[6] = %+v, want the tool_use block's partial input alongside the text block", u)
+	}
+
+	var last = got[len(got)-1]
+	if last.Index != 1 || !last.Done || last.PartialInput != "" {
+		t.Errorf("last update = %+v, want the completed tool_use block", last)
+	}
+	if last.Blocks[0].Text != "Let me check the weather." || string(last.Blocks[1].Input) != `{"location": "San Francisco, CA"}` {
+		t.Errorf("last Blocks = %v, want both complete blocks", last.Blocks)
+	}
+}
+
+func TestNewStreamingToolInputs(t *testing.T) {
+	var tests = []struct {
+		name    string
+		stream  string
+		want    string
+		wantErr error
+	}{
+		{name: "Complete", stream: toolUseStream, want: "San Francisco, CA"},
+		{
+			name:    "Interrupted",
+			stream:  toolUseStream[:strings.Index(toolUseStream, "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":1,\"delta\":{\"type\":\"input_json_delta\",\"partial_json\":\"ncisco")],
+			wantErr: io.ErrUnexpectedEOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = newStreamTestClient(tt.stream)
+
+			var _, inputs, errs, err = c.NewStreamingToolInputs(context.Background(), &v3.Request[v3.Message]{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var n int
+			for in := range inputs {
+				n++
+				if in.Index != 1 || in.ID != "toolu_1" || in.Name != "get_weather" {
+					t.Errorf("input = %+v, want the get_weather tool_use block", in)
+				}
+
+				var v struct {
+					Location string `json:"location"`
+				}
+				if err = json.NewDecoder(in).Decode(&v); !errors.Is(err, tt.wantErr) {
+					t.Errorf("Decode() error = %v, wantErr %v", err, tt.wantErr)
+				}
+				if v.Location != tt.want {
+					t.Errorf("location = %q, want %q", v.Location, tt.want)
+				}
+			}
+			<-errs
+
+			if n != 1 {
+				t.Errorf("received %d inputs, want 1", n)
+			}
+		})
+	}
+}
+
+func TestWithPartialJSON(t *testing.T) {
+	var c = newStreamTestClient(toolUseStream)
+
+	var indices []int
+	var partials []string
+	var _, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{}, WithPartialJSON(func(index int, partial string) {
+		indices = append(indices, index)
+		partials = append(partials, partial)
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range texts {
+	}
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = []string{"", `{"location": "San Fra`, `{"location": "San Francisco, CA"}`}
+	if !reflect.DeepEqual(partials, exp) {
+		t.Errorf("partials = %q, want %q", partials, exp)
+	}
+	for _, i := range indices {
+		if i != 1 {
+			t.Errorf("partial JSON index = %d, want 1", i)
+		}
+	}
+}
+
+func TestWithFinalUsage(t *testing.T) {
+	var tests = []struct {
+		name   string
+		stream string
+		exp    *v3.Usage
+	}{
+		{name: "Complete", stream: toolUseStream, exp: &v3.Usage{InputTokens: 25, OutputTokens: 89}},
+		{name: "Failed", stream: toolUseStream[:strings.Index(toolUseStream, "event: message_delta")]},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = newStreamTestClient(tt.stream)
+
+			var got []v3.Usage
+			var _, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{}, WithFinalUsage(func(u v3.Usage) {
+				got = append(got, u)
+			}))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for range texts {
+			}
+			<-errs
+
+			switch {
+			case tt.exp == nil && len(got) != 0:
+				t.Errorf("final usage = %v, want no call", got)
+			case tt.exp != nil && (len(got) != 1 || got[0] != *tt.exp):
+				t.Errorf("final usage = %v, want [%v]", got, *tt.exp)
+			}
+		})
+	}
+}
+
+func TestWithStreamStats(t *testing.T) {
+	const delay = 10 * time.Millisecond
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		time.Sleep(delay)
+		var resp = newTestResponse(http.StatusOK, toolUseStream)
+		resp.Header.Set("Content-Type", "text/event-stream")
+		return resp, nil
+	})}))
+
+	var stats StreamStats
+	var _, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{}, WithStreamStats(&stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range texts {
+	}
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.TimeToFirstToken < delay {
+		t.Errorf("TimeToFirstToken = %v, want at least %v", stats.TimeToFirstToken, delay)
+	}
+	if stats.Duration < stats.TimeToFirstToken {
+		t.Errorf("Duration = %v, want at least TimeToFirstToken (%v)", stats.Duration, stats.TimeToFirstToken)
+	}
+	if stats.OutputTokens != 89 {
+		t.Errorf("OutputTokens = %d, want 89", stats.OutputTokens)
+	}
+}
+
+func TestWithErrorOnEmptyResponse(t *testing.T) {
+	const emptyStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"refusal","stop_sequence":null},"usage":{"output_tokens":1}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	var c = newStreamTestClient(emptyStream)
+
+	var resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatalf("without the option, error = %v, want nil", err)
+	}
+	if len(resp.Content) != 0 {
+		t.Fatalf("len(Content) = %d, want 0", len(resp.Content))
+	}
+
+	if _, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{}, WithErrorOnEmptyResponse()); !errors.Is(err, ErrEmptyResponse) {
+		t.Errorf("error = %v, wantErr %v", err, ErrEmptyResponse)
+	}
+}
+
+func TestStreamingMessageRequestUnexpectedEOF(t *testing.T) {
+	var c = newStreamTestClient(toolUseStream[:len(toolUseStream)-len("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")])
+
+	if _, err := c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{}); err == nil {
+		t.Error("expected an error for a stream without message_stop")
+	}
+}
+
+const multiBlockStream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_2","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":10,"output_tokens":1}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"Two answers."}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"signature_delta","signature":"EqQBCgIYAhIM"}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: content_block_start
+data: {"type":"content_block_start","index":1,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"First."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":1}
+
+event: content_block_start
+data: {"type":"content_block_start","index":2,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":2,"delta":{"type":"text_delta","text":"Second."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":2}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"end_turn","stop_sequence":null},"usage":{"output_tokens":20}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+
+func TestStreamingMessageRequestBlockSeparator(t *testing.T) {
+	var c = newStreamTestClient(multiBlockStream)
+
+	var _, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{}, WithBlockSeparator("\n\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got string
+	for text := range texts {
+		got += text
+	}
+	if err = <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	if exp := "First.\n\nSecond."; got != exp {
+		t.Errorf("streamed text = %q, want %q", got, exp)
+	}
+}
+
+func TestStreamingMessageRequestThinking(t *testing.T) {
+	var c = newStreamTestClient(multiBlockStream)
+
+	var resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var block = resp.Content[0]
+	if block.Type != "thinking" || block.Thinking != "Two answers." || block.Signature != "EqQBCgIYAhIM" {
+		t.Errorf("thinking block = %+v, want thinking %q with signature %q", block, "Two answers.", "EqQBCgIYAhIM")
+	}
+
+	// The assembled thinking block must be sendable in the next turn.
+	var b []byte
+	if b, err = json.Marshal(block); err != nil {
+		t.Fatal(err)
+	}
+	if exp := `{"type":"thinking","thinking":"Two answers.","signature":"EqQBCgIYAhIM"}`; string(b) != exp {
+		t.Errorf("json.Marshal() = %s, want %s", b, exp)
+	}
+}
+
+// stallingBody is a response body which returns |data| and then blocks until |ctx| is done, like a stalled stream.
+type stallingBody struct {
+	ctx  context.Context
+	data *strings.Reader
+}
+
+// Read implements the io.Reader interface.
+func (b *stallingBody) Read(p []byte) (int, error) {
+	if b.data.Len() > 0 {
+		return b.data.Read(p)
+	}
+
+	<-b.ctx.Done()
+	return 0, b.ctx.Err()
+}
+
+// Close implements the io.Closer interface.
+func (b *stallingBody) Close() error {
+	return nil
+}
+
+func TestStreamTimeouts(t *testing.T) {
+	var tests = []struct {
+		name string
+		data string
+		opt  Option
+		err  error
+	}{
+		{
+			name: "Connect",
+			data: "",
+			opt:  WithStreamConnectTimeout(20 * time.Millisecond),
+			err:  ErrStreamConnectTimeout,
+		},
+		{
+			name: "Idle",
+			data: multiBlockStream[:strings.Index(multiBlockStream, "event: content_block_start")],
+			opt:  WithStreamIdleTimeout(20 * time.Millisecond),
+			err:  ErrStreamIdleTimeout,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key", tt.opt, WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var resp = newTestResponse(http.StatusOK, "")
+				resp.Body = &stallingBody{ctx: r.Context(), data: strings.NewReader(tt.data)}
+				return resp, nil
+			})}))
+
+			var _, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+			if !errors.Is(err, tt.err) {
+				t.Errorf("NewMessageStreamedBatchResponse() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+// TestStreamingMessageRequestFirstDelta checks that each text delta is sent as soon as its event arrives, without
+// waiting for (or coalescing with) the rest of the block, so the latency of the first delta doesn't depend on the size
+// of the response. The stream stalls after the given events, so a delta is only received if it wasn't buffered.
+func TestStreamingMessageRequestFirstDelta(t *testing.T) {
+	var prefix = toolUseStream[:strings.Index(toolUseStream, "event: ping")]
+	var delta = func(text string) string {
+		return "event: content_block_delta\ndata: {\"type\":\"content_block_delta\",\"index\":0,\"delta\":{\"type\":\"text_delta\",\"text\":\"" + text + "\"}}\n\n"
+	}
+	var large = strings.Repeat("x", 4<<20)
+
+	var tests = []struct {
+		name string
+		data string
+		exp  []string
+	}{
+		{name: "Small", data: prefix + delta("Hello"), exp: []string{"Hello"}},
+		{name: "Large", data: prefix + delta("Hello") + delta(large), exp: []string{"Hello", large}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var resp = newTestResponse(http.StatusOK, "")
+				resp.Body = &stallingBody{ctx: r.Context(), data: strings.NewReader(tt.data)}
+				return resp, nil
+			})}))
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var _, text, _, err = c.NewStreamingMessageRequest(ctx, &v3.Request[v3.Message]{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for i, exp := range tt.exp {
+				select {
+				case got := <-text:
+					if got != exp {
+						t.Errorf("delta %d has length %d, want %d", i, len(got), len(exp))
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("delta %d wasn't sent before the end of the stream", i)
+				}
+			}
+		})
+	}
+}
+
+const completionStream = `event: completion
+data: {"completion":" Hello","stop_reason":null,"model":"claude-2.1"}
+
+event: completion
+data: {"completion":" there","stop_reason":null,"model":"claude-2.1"}
+
+event: completion
+data: {"completion":"!","stop_reason":"stop_sequence","model":"claude-2.1"}
+
+`
+
+// waitForGoroutines fails the test if the number of goroutines doesn't drop to |n| (or below) within a second.
+func waitForGoroutines(t *testing.T, n int) {
+	t.Helper()
+
+	var deadline = time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > n {
+		if time.Now().After(deadline) {
+			t.Fatalf("%d goroutines are still running, want %d", runtime.NumGoroutine(), n)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestStreamingAbandonedLeak checks that a caller which receives the first value from a stream, then stops receiving
+// and cancels the context, doesn't leave any goroutines blocked.
+func TestStreamingAbandonedLeak(t *testing.T) {
+	var tests = []struct {
+		name   string
+		stream string
+		// start starts a stream and returns a function which receives its first value.
+		start func(ctx context.Context, c *Client) (func(), error)
+	}{
+		{
+			name:   "Completion",
+			stream: completionStream,
+			start: func(ctx context.Context, c *Client) (func(), error) {
+				var resps, _, err = c.NewStreamingCompletion(ctx, &Request{})
+				return func() { <-resps }, err
+			},
+		},
+		{
+			name:   "Message",
+			stream: multiBlockStream,
+			start: func(ctx context.Context, c *Client) (func(), error) {
+				var _, text, _, err = c.NewStreamingMessageRequest(ctx, &v3.Request[v3.Message]{})
+				return func() { <-text }, err
+			},
+		},
+		{
+			name:   "ShortHand",
+			stream: multiBlockStream,
+			start: func(ctx context.Context, c *Client) (func(), error) {
+				var _, text, _, err = c.NewStreamingShortHandMessageRequest(ctx, &v3.Request[v3.ShortHandMessage]{})
+				return func() { <-text }, err
+			},
+		},
+		{
+			name:   "Blocks",
+			stream: multiBlockStream,
+			start: func(ctx context.Context, c *Client) (func(), error) {
+				var _, blocks, _, err = c.NewStreamingMessageBlocks(ctx, &v3.Request[v3.Message]{})
+				return func() { <-blocks }, err
+			},
+		},
+		{
+			name:   "Tool Inputs",
+			stream: toolUseStream,
+			start: func(ctx context.Context, c *Client) (func(), error) {
+				var _, inputs, _, err = c.NewStreamingToolInputs(ctx, &v3.Request[v3.Message]{})
+				return func() { <-inputs }, err
+			},
+		},
+		{
+			name:   "Block Updates",
+			stream: multiBlockStream,
+			start: func(ctx context.Context, c *Client) (func(), error) {
+				var _, updates, _, err = c.NewStreamingMessageBlockUpdates(ctx, &v3.Request[v3.Message]{})
+				return func() { <-updates }, err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var before = runtime.NumGoroutine()
+			var c = newStreamTestClient(tt.stream)
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			var first, err = tt.start(ctx, c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			first()
+			cancel()
+
+			waitForGoroutines(t, before)
+		})
+	}
+}
+
+func TestWithMaxOutputTokens(t *testing.T) {
+	// The stream stalls after the second delta, so the response is only returned if the budget stops it.
+	var data = toolUseStream[:strings.Index(toolUseStream, "event: content_block_stop")]
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var resp = newTestResponse(http.StatusOK, "")
+		resp.Body = &stallingBody{ctx: r.Context(), data: strings.NewReader(data)}
+		return resp, nil
+	})}))
+
+	var ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var resp, err = c.NewMessageStreamedBatchResponse(ctx, &v3.Request[v3.Message]{}, WithMaxOutputTokens(3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != v3.StopReasonOutputBudget {
+		t.Errorf("StopReason = %v, want %v", resp.StopReason, v3.StopReasonOutputBudget)
+	}
+	if got := resp.Text(); got != "Let me check the weather." {
+		t.Errorf("Text() = %q, want %q", got, "Let me check the weather.")
+	}
+}
+
+// TestLatestAliasResolvedModel checks that a response reports the snapshot which served a request for a "-latest"
+// alias, rather than the alias itself.
+func TestLatestAliasResolvedModel(t *testing.T) {
+	var body string
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var b, err = io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+		if strings.Contains(body, `"stream":true`) {
+			var resp = newTestResponse(http.StatusOK, toolUseStream)
+			resp.Header.Set("Content-Type", "text/event-stream")
+			return resp, nil
+		}
+		return newTestResponse(http.StatusOK, `{"id":"msg_1","role":"assistant","model":"claude-3-5-sonnet-20241022","content":[],"usage":{"input_tokens":1,"output_tokens":1}}`), nil
+	})}))
+
+	var req = &v3.Request[v3.Message]{Model: v3.Claude3Dot5SonnetLatest, MaxTokens: 16}
+
+	var resp, err = c.NewMessageRequest(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(body, `"model":"claude-3-5-sonnet-latest"`) {
+		t.Errorf("request body = %s, want the alias", body)
+	}
+	if resp.Model != v3.Claude3Dot5Sonnet20241022 {
+		t.Errorf("Model = %v, want %v", resp.Model, v3.Claude3Dot5Sonnet20241022)
+	}
+
+	if resp, err = c.NewMessageStreamedBatchResponse(context.Background(), req); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Model != v3.Claude3Dot5Sonnet20241022 {
+		t.Errorf("streamed Model = %v, want %v", resp.Model, v3.Claude3Dot5Sonnet20241022)
+	}
+}
+
+func TestWithSkipBadEvents(t *testing.T) {
+	// The first delta is malformed, and the second refers to a content block which wasn't started.
+	var stream = strings.Replace(toolUseStream, `"text":"Let me check"}}`, `"text":"Let me check"`, 1)
+	stream = strings.Replace(stream, `"index":0,"delta":{"type":"text_delta","text":" the weather."}`, `"index":5,"delta":{"type":"text_delta","text":" the weather."}`, 1)
+
+	var tests = []struct {
+		name    string
+		opts    []StreamOption
+		expErrs int
+		fatal   bool
+	}{
+		{name: "Default", fatal: true, expErrs: 1},
+		{name: "Skip", opts: []StreamOption{WithSkipBadEvents()}, expErrs: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = newStreamTestClient(stream)
+
+			var resp, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{}, tt.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []error
+			for texts != nil || errs != nil {
+				select {
+				case _, ok := <-texts:
+					if !ok {
+						texts = nil
+					}
+				case err, ok := <-errs:
+					if !ok {
+						errs = nil
+						continue
+					}
+					got = append(got, err)
+				}
+			}
+
+			if len(got) != tt.expErrs {
+				t.Fatalf("received errors %v, want %d", got, tt.expErrs)
+			}
+			for _, err := range got {
+				var se *StreamError
+				if !errors.As(err, &se) || se.IsFatal() != tt.fatal {
+					t.Errorf("error = %v, want a StreamError with IsFatal() = %v", err, tt.fatal)
+				}
+			}
+			if !tt.fatal && len(resp.ToolUses()) != 1 {
+				t.Errorf("Content = %v, want the events after the bad ones to be applied", resp.Content)
+			}
+		})
+	}
+}
+
+func TestStreamingMessageRequestParsing(t *testing.T) {
+	var newStream = func() *anthropictest.Stream {
+		return anthropictest.NewStream("msg_1", "claude-3-7-sonnet-20250219").
+			Thinking("EqQBCgIYAhIM", "Let me ", "think.").
+			Text("Checking", " the weather.").
+			ToolUse("toolu_1", "get_weather", `{"city":`, ` "Paris"}`).
+			StopReason("tool_use").
+			Usage(25, 89)
+	}
+
+	var tests = []struct {
+		name   string
+		stream *anthropictest.Stream
+		chunk  int
+	}{
+		{name: "Plain", stream: newStream()},
+		{name: "Multiline data", stream: newStream().MultilineData()},
+		{name: "CRLF", stream: newStream().CRLF()},
+		{name: "Chunked", stream: newStream().MultilineData().CRLF(), chunk: 7},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var resp = newTestResponse(http.StatusOK, "")
+				resp.Body = io.NopCloser(tt.stream.Reader(tt.chunk))
+				return resp, nil
+			})}))
+
+			var resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := resp.Text(); got != "Checking the weather." {
+				t.Errorf("Text() = %q, want %q", got, "Checking the weather.")
+			}
+			if th := resp.ContentByType(v3.ContentTypeThinking); len(th) != 1 || th[0].Thinking != "Let me think." || th[0].Signature != "EqQBCgIYAhIM" {
+				t.Errorf("thinking = %+v, want the signed thinking block", th)
+			}
+			if tu := resp.ToolUses(); len(tu) != 1 || string(tu[0].Input) != `{"city": "Paris"}` {
+				t.Errorf("ToolUses() = %+v, want get_weather with its input", tu)
+			}
+			if resp.StopReason != v3.StopReasonToolUse || resp.Usage.InputTokens != 25 || resp.Usage.OutputTokens != 89 {
+				t.Errorf("StopReason = %v, Usage = %+v", resp.StopReason, resp.Usage)
+			}
+		})
+	}
+}
+
+func TestStreamingOverloadedError(t *testing.T) {
+	var stream = toolUseStream[:strings.Index(toolUseStream, "event: content_block_stop")] + `event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`
+	var c = newStreamTestClient(stream)
+
+	var _, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+
+	var re *ResponseError
+	if !errors.As(err, &re) {
+		t.Fatalf("error = %v, want a *ResponseError", err)
+	}
+	if re.Err.Code != StatusOverloaded || !re.Retryable() {
+		t.Errorf("Code = %d, Retryable() = %v, want %d, true", re.Err.Code, re.Retryable(), StatusOverloaded)
+	}
+}
+
+func TestStreamingMessageDeltaFinalState(t *testing.T) {
+	const stream = `event: message_start
+data: {"type":"message_start","message":{"id":"msg_1","type":"message","role":"assistant","content":[],"model":"claude-3-5-sonnet-20241022","usage":{"input_tokens":10,"output_tokens":1,"cache_read_input_tokens":5}}}
+
+event: content_block_start
+data: {"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}
+
+event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Done."}}
+
+event: content_block_stop
+data: {"type":"content_block_stop","index":0}
+
+event: message_delta
+data: {"type":"message_delta","delta":{"stop_reason":"stop_sequence","stop_sequence":"END","container":{"id":"container_1","expires_at":"2025-05-01T12:00:00Z"}},"usage":{"output_tokens":7,"cache_creation_input_tokens":3,"service_tier":"priority"}}
+
+event: message_stop
+data: {"type":"message_stop"}
+
+`
+	var c = newStreamTestClient(stream)
+
+	var resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.StopReason != v3.StopReasonStopSequence || resp.StopSequence == nil || *resp.StopSequence != "END" {
+		t.Errorf("StopReason, StopSequence = %v, %v, want stop_sequence, END", resp.StopReason, resp.StopSequence)
+	}
+	if resp.Container == nil || resp.Container.ID != "container_1" || resp.Container.ExpiresAt.IsZero() {
+		t.Errorf("Container = %+v, want container_1 with its expiry", resp.Container)
+	}
+	var want = v3.Usage{InputTokens: 10, OutputTokens: 7, CacheCreationInputTokens: 3, CacheReadInputTokens: 5, ServiceTier: "priority"}
+	if *resp.Usage != want {
+		t.Errorf("Usage = %+v, want %+v", *resp.Usage, want)
+	}
+}
+
+func TestWithResponseValidation(t *testing.T) {
+	var tests = []struct {
+		name    string
+		stream  string
+		wantErr error
+	}{
+		{name: "Consistent", stream: toolUseStream},
+		{name: "Missing Stop Reason", stream: strings.Replace(toolUseStream, `"stop_reason":"tool_use"`, `"stop_reason":null`, 1), wantErr: v3.ErrInconsistentResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = newStreamTestClient(tt.stream)
+
+			if _, err := c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{}); err != nil {
+				t.Fatalf("without the option, error = %v, want nil", err)
+			}
+			if _, err := c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{}, WithResponseValidation()); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestStreamingUnknownEvent(t *testing.T) {
+	var i = strings.Index(toolUseStream, "event: content_block_stop")
+	var stream = toolUseStream[:i] + `event: content_block_annotation
+data: {"type":"content_block_annotation","index":0}
+
+` + toolUseStream[i:]
+	var c = newStreamTestClient(stream)
+
+	var resp, err = c.NewMessageStreamedBatchResponse(context.Background(), &v3.Request[v3.Message]{})
+	if err != nil {
+		t.Fatalf("NewMessageStreamedBatchResponse() error = %v, want unknown events to be ignored", err)
+	}
+	if len(resp.ToolUses()) != 1 {
+		t.Errorf("Content = %v, want the events after the unknown one to be applied", resp.Content)
+	}
+}
+
+func TestStreamingMessageRequestPartialResponse(t *testing.T) {
+	const streamErr = `event: error
+data: {"type":"error","error":{"type":"overloaded_error","message":"Overloaded"}}
+
+`
+	var tests = []struct {
+		name     string
+		cut      string
+		expText  string
+		expInput string
+		expTools int
+	}{
+		{name: "Mid Text", cut: `event: content_block_delta
+data: {"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" the weather."}}`, expText: "Let me check"},
+		{name: "Mid Tool Input", cut: `event: content_block_delta
+data: {"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"ncisco`, expText: "Let me check the weather.", expInput: "{}", expTools: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = newStreamTestClient(toolUseStream[:strings.Index(toolUseStream, tt.cut)] + streamErr)
+
+			var resp, texts, errs, err = c.NewStreamingMessageRequest(context.Background(), &v3.Request[v3.Message]{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var sb strings.Builder
+			for text := range texts {
+				sb.WriteString(text)
+			}
+			if err = <-errs; err == nil {
+				t.Fatal("expected an error")
+			}
+
+			if got := resp.Text(); got != tt.expText || sb.String() != tt.expText {
+				t.Errorf("Text() = %q, received %q, want %q", got, sb.String(), tt.expText)
+			}
+			if resp.StopReason != v3.StopReasonUnknown {
+				t.Errorf("StopReason = %v, want %v", resp.StopReason, v3.StopReasonUnknown)
+			}
+			var tools = resp.ToolUses()
+			if len(tools) != tt.expTools {
+				t.Fatalf("ToolUses() = %v, want %d", tools, tt.expTools)
+			}
+			if tt.expTools > 0 && string(tools[0].Input) != tt.expInput {
+				t.Errorf("Input = %s, want %s", tools[0].Input, tt.expInput)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// ErrInvalidToolFunc is returned by ToolFromFunc when the function doesn't have a supported signature.
+var ErrInvalidToolFunc = errors.New("tool function must be func([context.Context,] T) (string, error) where T is a struct or pointer to struct")
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// ToolFromFunc returns a tool named |name| whose input schema is built from the argument of |fn| (see v3.SchemaFor),
+// and a handler which decodes the tool's input into that argument and calls |fn|. Declaring and executing a tool from
+// the same function means the two can't drift apart. |fn| must have the signature
+//
+//	func([ctx context.Context,] in T) (string, error)
+//
+// where T is a struct or a pointer to a struct.
+func ToolFromFunc(name, description string, fn any) (*v3.Tool, ToolHandler, error) {
+	var fv = reflect.ValueOf(fn)
+	if !fv.IsValid() || fv.Kind() == reflect.Func && fv.IsNil() {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidToolFunc, fn)
+	}
+
+	var ft = fv.Type()
+	if ft.Kind() != reflect.Func || ft.NumIn() < 1 || ft.NumIn() > 2 || ft.NumOut() != 2 ||
+		ft.Out(0).Kind() != reflect.String || ft.Out(1) != errorType {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidToolFunc, ft)
+	}
+
+	var withCtx = ft.NumIn() == 2
+	if withCtx && ft.In(0) != contextType {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidToolFunc, ft)
+	}
+
+	var in = ft.In(ft.NumIn() - 1)
+	var ptr = in.Kind() == reflect.Pointer
+	var elem = in
+	if ptr {
+		elem = in.Elem()
+	}
+	if elem.Kind() != reflect.Struct {
+		return nil, nil, fmt.Errorf("%w: %s", ErrInvalidToolFunc, ft)
+	}
+
+	var schema, err = v3.SchemaFor(reflect.New(elem).Interface())
+	if err != nil {
+		return nil, nil, fmt.Errorf("tool %s: %w", name, err)
+	}
+
+	var tool = &v3.Tool{Name: name, Description: description, InputSchema: schema}
+	var handler = func(ctx context.Context, input json.RawMessage) (string, error) {
+		var arg = reflect.New(elem)
+		if len(input) > 0 {
+			if err := json.Unmarshal(input, arg.Interface()); err != nil {
+				return "", fmt.Errorf("invalid input: %w", err)
+			}
+		}
+		if !ptr {
+			arg = arg.Elem()
+		}
+
+		var args = []reflect.Value{arg}
+		if withCtx {
+			args = []reflect.Value{reflect.ValueOf(ctx), arg}
+		}
+
+		var out = fv.Call(args)
+		var res, _ = out[1].Interface().(error)
+
+		return out[0].String(), res
+	}
+
+	return tool, handler, nil
+}
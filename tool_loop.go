@@ -0,0 +1,221 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+var (
+	// ErrMaxTurns is returned by RunToolLoop when the model is still requesting tools after the maximum number of
+	// turns.
+	ErrMaxTurns = errors.New("tool loop exceeded max turns")
+	// ErrInvalidMaxTurns is returned by RunToolLoop when the maximum number of turns is less than 1.
+	ErrInvalidMaxTurns = errors.New("max turns must be at least 1")
+)
+
+// ToolHandler executes a tool: it's passed the tool_use block's input, and returns the content of the tool_result.
+// |ctx| is canceled when the tool's timeout expires or the loop's context is canceled; long-running handlers should
+// respect it.
+type ToolHandler func(ctx context.Context, input json.RawMessage) (string, error)
+
+// ContentToolHandler executes a tool whose result is more than text, e.g. a tool that renders a chart or takes a
+// screenshot. It returns the content of the tool_result, which may only contain "text" and "image" blocks (see
+// v3.NewImageContentFromBytes).
+type ContentToolHandler func(ctx context.Context, input json.RawMessage) ([]*v3.MessageContent, error)
+
+// ToolLoopOption configures optional behavior of RunToolLoop.
+type ToolLoopOption func(*toolLoopConfig)
+
+// toolLoopConfig holds the configuration built from RunToolLoop's ToolLoopOptions.
+type toolLoopConfig struct {
+	timeout         time.Duration
+	timeouts        map[string]time.Duration
+	contentHandlers map[string]ContentToolHandler
+	usage           *v3.Usage
+}
+
+// timeoutFor returns the timeout for the tool |name|, or 0 if it has none.
+func (cfg *toolLoopConfig) timeoutFor(name string) time.Duration {
+	if d, ok := cfg.timeouts[name]; ok {
+		return d
+	}
+
+	return cfg.timeout
+}
+
+// WithToolTimeout limits each tool handler to |d|. If a handler hasn't returned by then, an |is_error| result saying
+// the tool timed out is sent to the model, and the loop continues.
+func WithToolTimeout(d time.Duration) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.timeout = d
+	}
+}
+
+// WithToolTimeoutFor limits the handler for the tool |name| to |d|, overriding WithToolTimeout. A zero |d| disables
+// the timeout for the tool.
+func WithToolTimeoutFor(name string, d time.Duration) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		if cfg.timeouts == nil {
+			cfg.timeouts = make(map[string]time.Duration)
+		}
+		cfg.timeouts[name] = d
+	}
+}
+
+// WithContentToolHandler registers |h| as the handler for the tool |name|, taking precedence over any ToolHandler
+// registered for it. Use it for tools which return images.
+func WithContentToolHandler(name string, h ContentToolHandler) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		if cfg.contentHandlers == nil {
+			cfg.contentHandlers = make(map[string]ContentToolHandler)
+		}
+		cfg.contentHandlers[name] = h
+	}
+}
+
+// WithUsageTotal adds the usage of every request the loop makes to |usage|, so the cost of the intermediate tool-use
+// turns (which aren't returned) can be attributed to the conversation. The counts are summed across turns, including
+// cache reads: later turns typically read the conversation so far from the cache, and each read is billed. |usage| is
+// updated as the loop runs, so it also covers the turns made before an error; it should not be read until RunToolLoop
+// returns.
+func WithUsageTotal(usage *v3.Usage) ToolLoopOption {
+	return func(cfg *toolLoopConfig) {
+		cfg.usage = usage
+	}
+}
+
+// handlerFor returns the handler for the tool |name|, adapted to return either text or content blocks, or nil if no
+// handler is registered.
+func (cfg *toolLoopConfig) handlerFor(handlers map[string]ToolHandler, name string) func(context.Context, json.RawMessage) (string, []*v3.MessageContent, error) {
+	if h, ok := cfg.contentHandlers[name]; ok {
+		return func(ctx context.Context, input json.RawMessage) (string, []*v3.MessageContent, error) {
+			var blocks, err = h(ctx, input)
+			return "", blocks, err
+		}
+	}
+	if h, ok := handlers[name]; ok {
+		return func(ctx context.Context, input json.RawMessage) (string, []*v3.MessageContent, error) {
+			var out, err = h(ctx, input)
+			return out, nil, err
+		}
+	}
+
+	return nil
+}
+
+// RunToolLoop drives a simple agent loop: it sends |req|, and while the model stops to use tools it calls the
+// registered handler for each "tool_use" block, sends the results back as "tool_result" blocks, and repeats. It
+// returns the first response that doesn't stop for tool use. If a handler returns an error or times out (or no
+// handler is registered for the tool), the error is sent back to the model as an |is_error| result rather than
+// aborting the loop.
+//
+// At most |maxTurns| requests are made. If the model is still requesting tools after that, the last response is
+// returned along with ErrMaxTurns. An error wrapping ErrInvalidMaxTurns is returned, without making any requests, if
+// |maxTurns| is less than 1. |req| is not modified; the conversation is built up on a clone.
+func (c *Client) RunToolLoop(ctx context.Context, req *v3.Request[v3.Message], handlers map[string]ToolHandler, maxTurns int, opts ...ToolLoopOption) (*v3.Response, error) {
+	if maxTurns < 1 {
+		return nil, fmt.Errorf("%w (got %d)", ErrInvalidMaxTurns, maxTurns)
+	}
+
+	var cfg = &toolLoopConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	var r = req.Clone()
+
+	var resp *v3.Response
+	for turn := 0; turn < maxTurns; turn++ {
+		var err error
+		resp, err = c.NewMessageRequest(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.usage != nil {
+			cfg.usage.Add(resp.Usage)
+		}
+
+		if resp.StopReason != v3.StopReasonToolUse {
+			return resp, nil
+		}
+
+		var results []*v3.MessageContent
+		for _, block := range resp.Content {
+			if block.Type != "tool_use" {
+				continue
+			}
+
+			var result *v3.MessageContent
+			if result, err = runToolHandler(ctx, cfg.handlerFor(handlers, block.Name), block, cfg.timeoutFor(block.Name)); err != nil {
+				return nil, err
+			}
+			results = append(results, result)
+		}
+
+		r.Messages = append(r.Messages,
+			&v3.Message{Role: v3.RoleAssistant, Content: resp.Content},
+			&v3.Message{Role: v3.RoleUser, Content: results},
+		)
+	}
+
+	return resp, ErrMaxTurns
+}
+
+// runToolHandler calls |handler| for |block| and returns the resulting "tool_result" block. A nil |handler| means no
+// handler is registered for the tool. If |timeout| is positive, the handler is abandoned after |timeout| and an
+// |is_error| result is returned. An error is only returned if |ctx| is done.
+func runToolHandler(ctx context.Context, handler func(context.Context, json.RawMessage) (string, []*v3.MessageContent, error), block *v3.MessageContent, timeout time.Duration) (*v3.MessageContent, error) {
+	var result = &v3.MessageContent{
+		Type:      "tool_result",
+		ToolUseID: block.ID,
+	}
+
+	if handler == nil {
+		result.Content = fmt.Sprintf("unknown tool: %s", block.Name)
+		result.IsError = true
+		return result, nil
+	}
+
+	var toolCtx, cancel = context.WithCancel(ctx)
+	defer cancel()
+	if timeout > 0 {
+		toolCtx, cancel = context.WithTimeout(toolCtx, timeout)
+		defer cancel()
+	}
+
+	type output struct {
+		out    string
+		blocks []*v3.MessageContent
+		err    error
+	}
+	// Buffered so an abandoned handler doesn't leak blocked on the send.
+	var done = make(chan output, 1)
+	go func() {
+		var out, blocks, err = handler(toolCtx, block.Input)
+		done <- output{out: out, blocks: blocks, err: err}
+	}()
+
+	select {
+	case o := <-done:
+		if o.err != nil {
+			result.Content = o.err.Error()
+			result.IsError = true
+			return result, nil
+		}
+		result.Content = o.out
+		result.ContentBlocks = o.blocks
+	case <-toolCtx.Done():
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.Content = fmt.Sprintf("tool %s timed out after %s", block.Name, timeout)
+		result.IsError = true
+	}
+
+	return result, nil
+}
@@ -0,0 +1,340 @@
+package anthropic
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestRunToolLoopTimeout(t *testing.T) {
+	const toolUse = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"slow","input":{}}],"stop_reason":"tool_use","usage":{"input_tokens":1,"output_tokens":1}}`
+	const endTurn = `{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"Done."}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var last []byte
+	var calls int
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var err error
+		if last, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+
+		calls++
+		if calls == 1 {
+			return newTestResponse(http.StatusOK, toolUse), nil
+		}
+		return newTestResponse(http.StatusOK, endTurn), nil
+	})}))
+
+	var handlers = map[string]ToolHandler{
+		"slow": func(ctx context.Context, _ json.RawMessage) (string, error) {
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+
+	var resp, err = c.RunToolLoop(context.Background(), &v3.Request[v3.Message]{
+		Model:     v3.Claude3Haiku20240307,
+		Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Go."}}}},
+		MaxTokens: 16,
+	}, handlers, 3, WithToolTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StopReason != v3.StopReasonEndTurn {
+		t.Errorf("StopReason = %v, want %v", resp.StopReason, v3.StopReasonEndTurn)
+	}
+
+	var req = &v3.Request[v3.Message]{}
+	if err = json.Unmarshal(last, req); err != nil {
+		t.Fatal(err)
+	}
+	var result = req.Messages[len(req.Messages)-1].Content[0]
+	if result.Type != "tool_result" || !result.IsError || result.ToolUseID != "toolu_1" {
+		t.Errorf("tool result = %+v, want a timed out is_error result for toolu_1", result)
+	}
+}
+
+func TestRunToolLoopMaxTurns(t *testing.T) {
+	const toolUse = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"again","input":{}}],"stop_reason":"tool_use","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var tests = []struct {
+		name     string
+		maxTurns int
+		calls    int
+		expErr   error
+	}{
+		{name: "Zero", maxTurns: 0, expErr: ErrInvalidMaxTurns},
+		{name: "Negative", maxTurns: -1, expErr: ErrInvalidMaxTurns},
+		{name: "One", maxTurns: 1, calls: 1, expErr: ErrMaxTurns},
+		{name: "Two", maxTurns: 2, calls: 2, expErr: ErrMaxTurns},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls int
+			var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				calls++
+				return newTestResponse(http.StatusOK, toolUse), nil
+			})}))
+
+			var handlers = map[string]ToolHandler{
+				"again": func(context.Context, json.RawMessage) (string, error) { return "ok", nil },
+			}
+			var resp, err = c.RunToolLoop(context.Background(), &v3.Request[v3.Message]{
+				Model:     v3.Claude3Haiku20240307,
+				Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Go."}}}},
+				MaxTokens: 16,
+			}, handlers, tt.maxTurns)
+			if !errors.Is(err, tt.expErr) {
+				t.Fatalf("RunToolLoop() error = %v, wantErr %v", err, tt.expErr)
+			}
+			if calls != tt.calls {
+				t.Errorf("made %d requests, want %d", calls, tt.calls)
+			}
+			// The last response is returned along with ErrMaxTurns.
+			if errors.Is(err, ErrMaxTurns) && resp == nil {
+				t.Error("RunToolLoop() returned no response with ErrMaxTurns")
+			}
+		})
+	}
+}
+
+func TestRunToolLoopUsageTotal(t *testing.T) {
+	const toolUse = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"echo","input":{}}],"stop_reason":"tool_use","usage":{"input_tokens":10,"output_tokens":5,"cache_creation_input_tokens":100}}`
+	const endTurn = `{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"Done."}],"stop_reason":"end_turn","usage":{"input_tokens":20,"output_tokens":7,"cache_read_input_tokens":100}}`
+
+	var calls int
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		if calls++; calls == 1 {
+			return newTestResponse(http.StatusOK, toolUse), nil
+		}
+		return newTestResponse(http.StatusOK, endTurn), nil
+	})}))
+
+	var handlers = map[string]ToolHandler{
+		"echo": func(context.Context, json.RawMessage) (string, error) {
+			return "ok", nil
+		},
+	}
+
+	var usage v3.Usage
+	var resp, err = c.RunToolLoop(context.Background(), &v3.Request[v3.Message]{
+		Model:     v3.Claude3Haiku20240307,
+		Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Go."}}}},
+		MaxTokens: 16,
+	}, handlers, 3, WithUsageTotal(&usage))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = v3.Usage{InputTokens: 30, OutputTokens: 12, CacheCreationInputTokens: 100, CacheReadInputTokens: 100}
+	if usage != exp {
+		t.Errorf("usage = %+v, want %+v", usage, exp)
+	}
+	if resp.Usage.InputTokens != 20 {
+		t.Errorf("response usage = %+v, want the last turn's usage", resp.Usage)
+	}
+}
+
+func TestRunToolLoopCanceled(t *testing.T) {
+	const toolUse = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"slow","input":{}}],"stop_reason":"tool_use","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		return newTestResponse(http.StatusOK, toolUse), nil
+	})}))
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var handlers = map[string]ToolHandler{
+		"slow": func(ctx context.Context, _ json.RawMessage) (string, error) {
+			cancel()
+			<-ctx.Done()
+			return "", ctx.Err()
+		},
+	}
+
+	var _, err = c.RunToolLoop(ctx, &v3.Request[v3.Message]{
+		Model:     v3.Claude3Haiku20240307,
+		Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Go."}}}},
+		MaxTokens: 16,
+	}, handlers, 3)
+	if err != context.Canceled {
+		t.Errorf("RunToolLoop() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestToolFromFunc(t *testing.T) {
+	type weatherInput struct {
+		City string `json:"city" description:"The city name."`
+	}
+
+	var tool, handler, err = ToolFromFunc("get_weather", "Gets the weather.", func(ctx context.Context, in weatherInput) (string, error) {
+		return "Sunny in " + in.City, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = tool.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	if tool.InputSchema.Properties["city"] == nil || len(tool.InputSchema.Required) != 1 {
+		t.Errorf("InputSchema = %+v, want a required city property", tool.InputSchema)
+	}
+
+	var out string
+	if out, err = handler(context.Background(), json.RawMessage(`{"city":"Paris"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if out != "Sunny in Paris" {
+		t.Errorf("handler() = %q, want %q", out, "Sunny in Paris")
+	}
+
+}
+
+func TestToolFromFuncInvalid(t *testing.T) {
+	type weatherInput struct {
+		City string `json:"city"`
+	}
+
+	var tests = []struct {
+		name string
+		fn   any
+	}{
+		{name: "Nil", fn: nil},
+		{name: "Nil Func", fn: (func(weatherInput) (string, error))(nil)},
+		{name: "Not A Func", fn: "get_weather"},
+		{name: "Bad Signature", fn: func(s string) string { return s }},
+		{name: "Bad Context", fn: func(s string, in weatherInput) (string, error) { return s, nil }},
+		{name: "Non-Struct Input", fn: func(s string) (string, error) { return s, nil }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := ToolFromFunc("bad", "", tt.fn); !errors.Is(err, ErrInvalidToolFunc) {
+				t.Errorf("ToolFromFunc() error = %v, wantErr %v", err, ErrInvalidToolFunc)
+			}
+		})
+	}
+}
+
+func TestRunToolLoopContentHandler(t *testing.T) {
+	const toolUse = `{"id":"msg_1","type":"message","role":"assistant","content":[{"type":"tool_use","id":"toolu_1","name":"chart","input":{}}],"stop_reason":"tool_use","usage":{"input_tokens":1,"output_tokens":1}}`
+	const endTurn = `{"id":"msg_2","type":"message","role":"assistant","content":[{"type":"text","text":"Done."}],"stop_reason":"end_turn","usage":{"input_tokens":1,"output_tokens":1}}`
+
+	var last []byte
+	var calls int
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var err error
+		if last, err = io.ReadAll(r.Body); err != nil {
+			return nil, err
+		}
+
+		calls++
+		if calls == 1 {
+			return newTestResponse(http.StatusOK, toolUse), nil
+		}
+		return newTestResponse(http.StatusOK, endTurn), nil
+	})}))
+
+	var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	var chart = func(ctx context.Context, _ json.RawMessage) ([]*v3.MessageContent, error) {
+		var img, err = v3.NewImageContentFromBytes(png)
+		if err != nil {
+			return nil, err
+		}
+		return []*v3.MessageContent{{Type: "text", Text: "Revenue by month:"}, img}, nil
+	}
+
+	var _, err = c.RunToolLoop(context.Background(), &v3.Request[v3.Message]{
+		Model:     v3.Claude3Haiku20240307,
+		Messages:  []*v3.Message{{Role: v3.RoleUser, Content: []*v3.MessageContent{{Type: "text", Text: "Chart revenue."}}}},
+		MaxTokens: 16,
+	}, nil, 3, WithContentToolHandler("chart", chart))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var req = &v3.Request[v3.Message]{}
+	if err = json.Unmarshal(last, req); err != nil {
+		t.Fatal(err)
+	}
+	var result = req.Messages[len(req.Messages)-1].Content[0]
+	if result.Type != "tool_result" || result.IsError || len(result.ContentBlocks) != 2 || result.ContentBlocks[1].Type != "image" {
+		t.Errorf("tool result = %+v, want text and image content", result)
+	}
+}
+
+func TestDecodeToolUse(t *testing.T) {
+	type weatherInput struct {
+		City string `json:"city"`
+	}
+	type timeInput struct {
+		Zone string `json:"zone"`
+	}
+
+	var c = NewClient("key")
+	if err := c.RegisterTool(&v3.Tool{Name: "get_weather"}, weatherInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterTool(&v3.Tool{Name: "get_time"}, &timeInput{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.RegisterTool(nil, weatherInput{}); !errors.Is(err, ErrNilTool) {
+		t.Errorf("RegisterTool(nil) error = %v, wantErr %v", err, ErrNilTool)
+	}
+	if err := c.RegisterTool(&v3.Tool{}, weatherInput{}); !errors.Is(err, v3.ErrMissingToolName) {
+		t.Errorf("RegisterTool() error = %v, wantErr %v", err, v3.ErrMissingToolName)
+	}
+
+	var tools = c.RegisteredTools()
+	if len(tools) != 2 || tools[0].Name != "get_time" || tools[1].Name != "get_weather" {
+		t.Errorf("RegisteredTools() = %v, want get_time and get_weather", tools)
+	}
+
+	var tests = []struct {
+		name   string
+		block  *v3.MessageContent
+		exp    any
+		expErr error
+	}{
+		{
+			name:  "Value",
+			block: &v3.MessageContent{Type: "tool_use", Name: "get_weather", Input: json.RawMessage(`{"city":"Paris"}`)},
+			exp:   weatherInput{City: "Paris"},
+		},
+		{
+			name:  "Pointer",
+			block: &v3.MessageContent{Type: "tool_use", Name: "get_time", Input: json.RawMessage(`{"zone":"UTC"}`)},
+			exp:   &timeInput{Zone: "UTC"},
+		},
+		{
+			name:   "Unknown",
+			block:  &v3.MessageContent{Type: "tool_use", Name: "get_stock_price", Input: json.RawMessage(`{}`)},
+			expErr: ErrUnknownTool,
+		},
+		{
+			name:   "Not tool_use",
+			block:  &v3.MessageContent{Type: "text", Text: "Hi"},
+			expErr: ErrNotToolUse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = c.DecodeToolUse(tt.block)
+			if !errors.Is(err, tt.expErr) {
+				t.Fatalf("DecodeToolUse() error = %v, wantErr %v", err, tt.expErr)
+			}
+			if !reflect.DeepEqual(got, tt.exp) {
+				t.Errorf("DecodeToolUse() = %#v, want %#v", got, tt.exp)
+			}
+		})
+	}
+}
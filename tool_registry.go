@@ -0,0 +1,104 @@
+package anthropic
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+var (
+	// ErrUnknownTool is returned by DecodeToolUse when no tool is registered with the block's name.
+	ErrUnknownTool = errors.New("unknown tool")
+	// ErrNotToolUse is returned by DecodeToolUse when the block isn't a "tool_use" block.
+	ErrNotToolUse = errors.New("content is not a tool_use block")
+	// ErrNilTool is returned by RegisterTool when the tool is nil.
+	ErrNilTool = errors.New("tool is nil")
+)
+
+// registeredTool is a tool registered with RegisterTool, and the type its input is decoded into.
+type registeredTool struct {
+	tool *v3.Tool
+	typ  reflect.Type
+}
+
+// RegisterTool registers |tool| with the client, associating it with the type of |prototype| (e.g. WeatherInput{} or
+// &WeatherInput{}). DecodeToolUse then decodes the input of the tool's "tool_use" blocks into a new value of that type,
+// centralizing the mapping from tool names to input types. If |prototype| is nil, the input is returned as is, as a
+// json.RawMessage. Registering a tool with the same name replaces it. RegisteredTools returns the registered tools.
+// ErrNilTool is returned if |tool| is nil, and v3.ErrMissingToolName if it has no name.
+func (c *Client) RegisterTool(tool *v3.Tool, prototype any) error {
+	if tool == nil {
+		return ErrNilTool
+	}
+	if tool.Name == "" {
+		return v3.ErrMissingToolName
+	}
+
+	c.toolsMu.Lock()
+	defer c.toolsMu.Unlock()
+
+	if c.tools == nil {
+		c.tools = make(map[string]*registeredTool)
+	}
+	c.tools[tool.Name] = &registeredTool{tool: tool, typ: reflect.TypeOf(prototype)}
+
+	return nil
+}
+
+// RegisteredTools returns the tools registered with RegisterTool, sorted by name (so that requests using them are
+// byte-for-byte stable, which prompt caching requires). It's intended to populate a request's Tools.
+func (c *Client) RegisteredTools() []*v3.Tool {
+	c.toolsMu.RLock()
+	defer c.toolsMu.RUnlock()
+
+	var tools = make([]*v3.Tool, 0, len(c.tools))
+	for _, rt := range c.tools {
+		tools = append(tools, rt.tool)
+	}
+	sort.Slice(tools, func(i, j int) bool {
+		return tools[i].Name < tools[j].Name
+	})
+
+	return tools
+}
+
+// DecodeToolUse decodes the input of the "tool_use" |block| into a new value of the type registered for its tool with
+// RegisterTool, and returns it. The value has the same type as the registered prototype, so callers can use a type
+// switch (or assertion) on it. An error wrapping ErrUnknownTool is returned if the tool isn't registered.
+func (c *Client) DecodeToolUse(block *v3.MessageContent) (any, error) {
+	if block == nil || block.Type != v3.ContentTypeToolUse.String() {
+		return nil, ErrNotToolUse
+	}
+
+	c.toolsMu.RLock()
+	var rt, ok = c.tools[block.Name]
+	c.toolsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrUnknownTool, block.Name)
+	}
+	if rt.typ == nil {
+		return block.Input, nil
+	}
+
+	var ptr = rt.typ.Kind() == reflect.Pointer
+	var elem = rt.typ
+	if ptr {
+		elem = rt.typ.Elem()
+	}
+
+	var v = reflect.New(elem)
+	if len(block.Input) > 0 {
+		if err := json.Unmarshal(block.Input, v.Interface()); err != nil {
+			return nil, fmt.Errorf("tool %s: invalid input: %w", block.Name, err)
+		}
+	}
+	if !ptr {
+		v = v.Elem()
+	}
+
+	return v.Interface(), nil
+}
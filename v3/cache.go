@@ -0,0 +1,138 @@
+package v3
+
+const (
+	// MaxCacheBreakpoints is the maximum number of cache_control markers the API accepts in a request.
+	MaxCacheBreakpoints = 4
+	// MinCacheableTokens is the minimum length, in tokens, of a cacheable prefix for most models. Claude 3 Haiku models
+	// require 2048. Shorter prefixes are processed without caching, even if marked.
+	MinCacheableTokens = 1024
+)
+
+// cacheBoundary is a point in the prompt at which a cache breakpoint can be placed.
+type cacheBoundary struct {
+	// tokens is the estimated length of the prompt up to and including the boundary.
+	tokens int
+	// system is true if the boundary is in the system prompt.
+	system bool
+	// mark places a breakpoint at the boundary.
+	mark func()
+}
+
+// PlaceCacheBreakpoints marks the prefixes of |r| worth caching with cache_control, without exceeding
+// MaxCacheBreakpoints (including any markers already set, which are kept). It returns the number of breakpoints
+// placed. Only prefixes of at least |minTokens| tokens (see MinCacheableTokens) are marked, since shorter ones can't be
+// cached. Breakpoints are placed, in order of priority:
+//
+//  1. at the end of the last message, so the next turn of the conversation reads the whole prompt from the cache;
+//  2. at the end of the system prompt, which is typically shared by every conversation;
+//  3. at the ends of earlier messages, latest first, at least |minTokens| apart, so that long conversations still hit
+//     the cache when the API's lookback from a later breakpoint doesn't reach far enough.
+//
+// Sizes are estimated (see EstimateTokens); the tool definitions, which precede the system prompt, count towards each
+// prefix. If System is set, it's converted to a single SystemMessages block so it can be marked.
+func PlaceCacheBreakpoints(r *Request[Message], minTokens int) int {
+	var tokens, existing int
+	for _, t := range r.Tools {
+		if b, err := Marshal(t); err == nil {
+			tokens += EstimateTokens(string(b))
+		}
+	}
+
+	if r.System != nil && len(r.SystemMessages) == 0 {
+		r.SystemMessages = []*SystemMessage{{Type: "text", Text: *r.System}}
+		r.System = nil
+	}
+
+	var boundaries []cacheBoundary
+	for _, s := range r.SystemMessages {
+		tokens += EstimateTokens(s.Text)
+		if s.CacheControl != nil {
+			existing++
+			continue
+		}
+		var s = s
+		boundaries = append(boundaries, cacheBoundary{tokens: tokens, system: true, mark: func() {
+			s.CacheControl = &CacheControl{Type: "ephemeral"}
+		}})
+	}
+	for _, m := range r.Messages {
+		for _, c := range m.Content {
+			tokens += estimateBlockTokens(c)
+			if c != nil && c.CacheControl != nil {
+				existing++
+			}
+		}
+		if len(m.Content) == 0 {
+			continue
+		}
+		if last := m.Content[len(m.Content)-1]; last != nil && last.CacheControl == nil && !isThinkingBlock(last) {
+			boundaries = append(boundaries, cacheBoundary{tokens: tokens, mark: func() {
+				last.CacheControl = &CacheControl{Type: "ephemeral"}
+			}})
+		}
+	}
+
+	var slots = MaxCacheBreakpoints - existing
+	if slots <= 0 {
+		return 0
+	}
+
+	// Only boundaries with a long enough prefix are eligible, and the prefix only grows, so they're a suffix.
+	var first = len(boundaries)
+	for first > 0 && boundaries[first-1].tokens >= minTokens {
+		first--
+	}
+	var eligible = boundaries[first:]
+	if len(eligible) == 0 {
+		return 0
+	}
+
+	var chosen = make(map[int]bool)
+	chosen[len(eligible)-1] = true
+	var system = -1
+	for i, b := range eligible {
+		if b.system {
+			system = i
+		}
+	}
+	if system >= 0 && len(chosen) < slots {
+		chosen[system] = true
+	}
+
+	var floor = eligible[len(eligible)-1].tokens
+	for i := len(eligible) - 2; i >= 0 && len(chosen) < slots; i-- {
+		var b = eligible[i]
+		switch {
+		case chosen[i]:
+			floor = b.tokens
+		case b.system:
+			// Only the end of the system prompt is worth marking.
+		case floor-b.tokens >= minTokens && (system < 0 || b.tokens-eligible[system].tokens >= minTokens):
+			chosen[i] = true
+			floor = b.tokens
+		}
+	}
+
+	for i := range chosen {
+		eligible[i].mark()
+	}
+
+	return len(chosen)
+}
+
+// estimateBlockTokens returns a rough estimate of the number of tokens in the content block |c|.
+func estimateBlockTokens(c *MessageContent) int {
+	if c == nil {
+		return 0
+	}
+	if c.Type == "image" {
+		return MaxImageTokens
+	}
+
+	var b, err = Marshal(c)
+	if err != nil {
+		return 0
+	}
+
+	return EstimateTokens(string(b))
+}
@@ -0,0 +1,92 @@
+package v3
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPlaceCacheBreakpoints(t *testing.T) {
+	// Each block is about |minTokens| / 2 tokens.
+	const minTokens = 100
+	var text = func() *MessageContent {
+		return &MessageContent{Type: "text", Text: strings.Repeat("word ", 40)}
+	}
+	var conversation = func(turns int) []*Message {
+		var out []*Message
+		for i := 0; i < turns; i++ {
+			var role = RoleUser
+			if i%2 == 1 {
+				role = RoleAssistant
+			}
+			out = append(out, &Message{Role: role, Content: []*MessageContent{text()}})
+		}
+		return out
+	}
+
+	var tests = []struct {
+		name     string
+		system   *string
+		messages []*Message
+		// exp is the indices of the messages expected to be marked; -1 is the system prompt.
+		exp []int
+	}{
+		{name: "Too Short", messages: conversation(1), exp: nil},
+		{name: "Last Message", messages: conversation(3), exp: []int{2}},
+		{name: "System And Last Message", system: Optional(strings.Repeat("rule ", 100)), messages: conversation(3), exp: []int{-1, 2}},
+		{name: "Spaced", messages: conversation(11), exp: []int{4, 6, 8, 10}},
+		{name: "System And Spaced", system: Optional(strings.Repeat("rule ", 100)), messages: conversation(11), exp: []int{-1, 6, 8, 10}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &Request[Message]{Model: Claude3Dot7Sonnet20250219, System: tt.system, Messages: tt.messages, MaxTokens: 1024}
+
+			var n = PlaceCacheBreakpoints(r, minTokens)
+
+			var got []int
+			for _, s := range r.SystemMessages {
+				if s.CacheControl != nil {
+					got = append(got, -1)
+				}
+			}
+			for i, m := range r.Messages {
+				if m.Content[0].CacheControl != nil {
+					got = append(got, i)
+				}
+			}
+			if n != len(got) || len(got) != len(tt.exp) {
+				t.Fatalf("placed %d breakpoints at %v, want %v", n, got, tt.exp)
+			}
+			for i := range got {
+				if got[i] != tt.exp[i] {
+					t.Fatalf("breakpoints at %v, want %v", got, tt.exp)
+				}
+			}
+		})
+	}
+}
+
+func TestPlaceCacheBreakpointsExisting(t *testing.T) {
+	var r = &Request[Message]{
+		SystemMessages: []*SystemMessage{
+			{Type: "text", Text: strings.Repeat("rule ", 400), CacheControl: &CacheControl{Type: "ephemeral"}},
+			{Type: "text", Text: strings.Repeat("rule ", 400), CacheControl: &CacheControl{Type: "ephemeral"}},
+			{Type: "text", Text: strings.Repeat("rule ", 400), CacheControl: &CacheControl{Type: "ephemeral"}},
+		},
+		Messages: []*Message{
+			{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: strings.Repeat("word ", 400)}}},
+			{Role: RoleAssistant, Content: []*MessageContent{{Type: "text", Text: strings.Repeat("word ", 400)}}},
+			{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: strings.Repeat("word ", 400)}}},
+		},
+	}
+
+	if n := PlaceCacheBreakpoints(r, MinCacheableTokens); n != 1 {
+		t.Fatalf("placed %d breakpoints, want 1", n)
+	}
+	if r.Messages[2].Content[0].CacheControl == nil {
+		t.Errorf("last message isn't marked")
+	}
+	if n := PlaceCacheBreakpoints(r, MinCacheableTokens); n != 0 {
+		t.Errorf("placed %d more breakpoints, want 0", n)
+	}
+}
@@ -0,0 +1,77 @@
+package v3
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+)
+
+// ContentPart builds a single content block of a message. See Text, ImageFile, ImageBytes, and ImageURL.
+type ContentPart func() (*MessageContent, error)
+
+// ErrInvalidImageURL is returned when an image URL is not an absolute http(s) URL.
+var ErrInvalidImageURL = errors.New("image url must be an absolute http or https url")
+
+// Text returns a ContentPart for a text block containing |s|.
+func Text(s string) ContentPart {
+	return func() (*MessageContent, error) {
+		return &MessageContent{Type: "text", Text: s}, nil
+	}
+}
+
+// ImageFile returns a ContentPart for an image block containing the image at |path|. The file is read when the
+// message is built.
+func ImageFile(path string) ContentPart {
+	return func() (*MessageContent, error) {
+		var b, err = os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		var c *MessageContent
+		if c, err = NewImageContentFromBytes(b); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+
+		return c, nil
+	}
+}
+
+// ImageBytes returns a ContentPart for an image block containing the image |b|. See NewImageContentFromBytes.
+func ImageBytes(b []byte) ContentPart {
+	return func() (*MessageContent, error) {
+		return NewImageContentFromBytes(b)
+	}
+}
+
+// ImageURL returns a ContentPart for an image block which the API fetches from |u|.
+func ImageURL(u string) ContentPart {
+	return func() (*MessageContent, error) {
+		var parsed, err = url.Parse(u)
+		if err != nil || !parsed.IsAbs() || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidImageURL, u)
+		}
+
+		return &MessageContent{
+			Type:   "image",
+			Source: &MediaSource{Type: "url", URL: u},
+		}, nil
+	}
+}
+
+// NewUserMessageWith returns a user message whose content blocks are built from |parts|, in order. Order matters for
+// vision prompts (e.g. "Image 1: ... Image 2: ..."), so it's preserved exactly. The first error returned by a part is
+// returned, annotated with the part's position.
+func NewUserMessageWith(parts ...ContentPart) (*Message, error) {
+	var m = &Message{Role: RoleUser, Content: make([]*MessageContent, 0, len(parts))}
+	for i, p := range parts {
+		var c, err = p()
+		if err != nil {
+			return nil, fmt.Errorf("part %d: %w", i, err)
+		}
+		m.Content = append(m.Content, c)
+	}
+
+	return m, nil
+}
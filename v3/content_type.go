@@ -0,0 +1,83 @@
+package v3
+
+// ContentType represents the type of a content block. MessageContent.Type holds the string form, so blocks of types
+// this package doesn't know yet round-trip unchanged; ContentType gives the known types a checked name.
+type ContentType int
+
+const (
+	// ContentTypeUnknown represents an unknown content block type.
+	ContentTypeUnknown ContentType = iota
+	// ContentTypeText is a block of text.
+	ContentTypeText
+	// ContentTypeImage is an image.
+	ContentTypeImage
+	// ContentTypeDocument is a document, e.g. a PDF.
+	ContentTypeDocument
+	// ContentTypeToolUse is a request by the model to use a client tool.
+	ContentTypeToolUse
+	// ContentTypeToolResult is the result of a client tool.
+	ContentTypeToolResult
+	// ContentTypeThinking is the model's extended thinking.
+	ContentTypeThinking
+	// ContentTypeRedactedThinking is extended thinking which was encrypted for safety reasons.
+	ContentTypeRedactedThinking
+	// ContentTypeServerToolUse is the model's use of a server tool (e.g. web search or code execution).
+	ContentTypeServerToolUse
+	// ContentTypeWebSearchToolResult is the result of the web search server tool.
+	ContentTypeWebSearchToolResult
+	// ContentTypeCodeExecutionToolResult is the result of the code execution server tool.
+	ContentTypeCodeExecutionToolResult
+	// ContentTypeContainerUpload is a file uploaded to the code execution container.
+	ContentTypeContainerUpload
+)
+
+// String implements the fmt.Stringer interface.
+func (t ContentType) String() string {
+	return contentTypeToString[t]
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (t ContentType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+// On unrecognized value, it sets |t| to Unknown.
+func (t *ContentType) UnmarshalText(b []byte) error {
+	if val, ok := stringToContentType[(string(b))]; ok {
+		*t = val
+		return nil
+	}
+
+	*t = ContentTypeUnknown
+
+	return nil
+}
+
+var contentTypeToString = map[ContentType]string{
+	ContentTypeText:                    "text",
+	ContentTypeImage:                   "image",
+	ContentTypeDocument:                "document",
+	ContentTypeToolUse:                 "tool_use",
+	ContentTypeToolResult:              "tool_result",
+	ContentTypeThinking:                "thinking",
+	ContentTypeRedactedThinking:        "redacted_thinking",
+	ContentTypeServerToolUse:           "server_tool_use",
+	ContentTypeWebSearchToolResult:     "web_search_tool_result",
+	ContentTypeCodeExecutionToolResult: "code_execution_tool_result",
+	ContentTypeContainerUpload:         "container_upload",
+}
+
+var stringToContentType = map[string]ContentType{
+	"text":                       ContentTypeText,
+	"image":                      ContentTypeImage,
+	"document":                   ContentTypeDocument,
+	"tool_use":                   ContentTypeToolUse,
+	"tool_result":                ContentTypeToolResult,
+	"thinking":                   ContentTypeThinking,
+	"redacted_thinking":          ContentTypeRedactedThinking,
+	"server_tool_use":            ContentTypeServerToolUse,
+	"web_search_tool_result":     ContentTypeWebSearchToolResult,
+	"code_execution_tool_result": ContentTypeCodeExecutionToolResult,
+	"container_upload":           ContentTypeContainerUpload,
+}
@@ -0,0 +1,73 @@
+package v3
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrConversationTooLong is returned by TrimConversation when even the latest turn of a conversation doesn't fit in
+// the token budget.
+var ErrConversationTooLong = errors.New("conversation does not fit in the token budget")
+
+// TrimConversation drops the oldest turns of |req|'s Messages until the request fits in |maxTokens| tokens as counted
+// by |counter| (HeuristicTokenCounter if nil), e.g. to keep a sliding window of a long chat session's history. It
+// returns the latest messages which fit; neither |req| nor its Messages are modified.
+//
+// Everything else in |req| (its Model, system prompt, tools, etc.) is counted as is, so |req| should be the request
+// the messages are about to be sent in. This matters for counters which require a model, as the count_tokens endpoint
+// does.
+//
+// The conversation is only ever cut before a user message which doesn't contain tool results, so the trimmed
+// conversation still starts with a user message, keeps its roles alternating, and never separates a tool_use block
+// from its tool_result. An error wrapping ErrConversationTooLong is returned if no such cut fits.
+func TrimConversation(req *Request[Message], maxTokens int, counter TokenCounter) ([]*Message, error) {
+	if counter == nil {
+		counter = HeuristicTokenCounter{}
+	}
+
+	var msgs = req.Messages
+	// cuts are the indices the conversation can start at, in order. Keeping everything is always allowed.
+	var cuts = []int{0}
+	for i := 1; i < len(msgs); i++ {
+		if msgs[i].Role == RoleUser && !hasToolResult(msgs[i]) {
+			cuts = append(cuts, i)
+		}
+	}
+
+	// The count only shrinks as the cut moves later, so search for the earliest cut which fits.
+	var countErr error
+	var fits = func(i int) bool {
+		if countErr != nil {
+			return false
+		}
+		var r = *req
+		r.Messages = msgs[cuts[i]:]
+		var n, err = counter.Count(&r)
+		if err != nil {
+			countErr = err
+			return false
+		}
+		return n <= maxTokens
+	}
+	var i = sort.Search(len(cuts), fits)
+	if countErr != nil {
+		return nil, countErr
+	}
+	if i == len(cuts) {
+		return nil, fmt.Errorf("%w: the latest turn (from message %d) is over %d tokens", ErrConversationTooLong, cuts[len(cuts)-1], maxTokens)
+	}
+
+	return msgs[cuts[i]:], nil
+}
+
+// hasToolResult returns true if |m| contains a "tool_result" block.
+func hasToolResult(m *Message) bool {
+	for _, c := range m.Content {
+		if c != nil && c.Type == "tool_result" {
+			return true
+		}
+	}
+
+	return false
+}
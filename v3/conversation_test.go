@@ -0,0 +1,102 @@
+package v3
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestTrimConversation(t *testing.T) {
+	var text = func(role Role, s string) *Message {
+		return &Message{Role: role, Content: []*MessageContent{{Type: "text", Text: s}}}
+	}
+	// Each text message is 2 tokens (8 characters) by estimate.
+	var msgs = []*Message{
+		text(RoleUser, "question"),
+		{Role: RoleAssistant, Content: []*MessageContent{{Type: "tool_use", ID: "t1", Name: "search"}}},
+		{Role: RoleUser, Content: []*MessageContent{{Type: "tool_result", ToolUseID: "t1"}}},
+		text(RoleAssistant, "answer!!"),
+		text(RoleUser, "followup"),
+		text(RoleAssistant, "reply!!!"),
+	}
+	var req = &Request[Message]{Model: Claude3Dot5Sonnet20241022, Messages: msgs, SystemMessages: []*SystemMessage{{Type: "text", Text: "Be brief"}}}
+	var full, _ = HeuristicTokenCounter{}.Count(req)
+
+	var tests = []struct {
+		name      string
+		maxTokens int
+		want      []*Message
+		err       error
+	}{
+		{name: "Fits", maxTokens: 1000, want: msgs},
+		// Cutting before the tool_result would fit, but would separate it from its tool_use.
+		{name: "Drops Tool Turn Whole", maxTokens: full - 1, want: msgs[4:]},
+		{name: "Latest Turn", maxTokens: 6, want: msgs[4:]},
+		{name: "Too Long", maxTokens: 5, err: ErrConversationTooLong},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = TrimConversation(req, tt.maxTokens, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("TrimConversation() error = %v, wantErr %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("TrimConversation() = %d messages from %d, want %d", len(got), len(msgs)-len(got), len(tt.want))
+			}
+			if err == nil {
+				if err = ValidateToolPairing(got); err != nil {
+					t.Errorf("trimmed conversation has invalid tool pairing: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func TestTrimConversationCounterError(t *testing.T) {
+	var errCount = errors.New("count failed")
+	var req = &Request[Message]{Messages: []*Message{{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: "Hi"}}}}}
+
+	if _, err := TrimConversation(req, 100, failingCounter{errCount}); !errors.Is(err, errCount) {
+		t.Errorf("TrimConversation() error = %v, wantErr %v", err, errCount)
+	}
+}
+
+func TestTrimConversationCountsRequest(t *testing.T) {
+	var req = &Request[Message]{
+		Model:    Claude3Dot5Sonnet20241022,
+		Messages: []*Message{{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: "Hi"}}}},
+		Tools:    []*Tool{{Name: "search"}},
+	}
+
+	var counted []*Request[Message]
+	var counter = countFunc(func(r *Request[Message]) (int, error) {
+		counted = append(counted, r)
+		return 1, nil
+	})
+	if _, err := TrimConversation(req, 100, counter); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range counted {
+		if r.Model != req.Model || len(r.Tools) != 1 {
+			t.Errorf("counted request has Model %q and %d tools, want %q and 1", r.Model, len(r.Tools), req.Model)
+		}
+	}
+	if len(counted) == 0 {
+		t.Error("no requests were counted")
+	}
+}
+
+type countFunc func(*Request[Message]) (int, error)
+
+func (f countFunc) Count(r *Request[Message]) (int, error) {
+	return f(r)
+}
+
+type failingCounter struct {
+	err error
+}
+
+func (f failingCounter) Count(*Request[Message]) (int, error) {
+	return 0, f.err
+}
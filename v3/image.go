@@ -0,0 +1,168 @@
+package v3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image"
+	// Register the decoders for the formats supported by the API (other than WebP, which isn't in the standard
+	// library) so ImageDimensions can read their headers.
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+	"net/http"
+)
+
+var (
+	// ErrUnsupportedMediaType is returned when image data is not in one of the formats supported by the API.
+	ErrUnsupportedMediaType = errors.New("unsupported image media type")
+	// ErrTooManyImages indicates that a Request contains more than MaxImagesPerRequest images.
+	ErrTooManyImages = errors.New("too many images")
+	// ErrImageTooLarge indicates that an image in a Request is larger than MaxImageBytes.
+	ErrImageTooLarge = errors.New("image too large")
+)
+
+// supportedImageMediaTypes are the image media types accepted by the API.
+var supportedImageMediaTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+// NewImageContentFromBytes returns an image MessageContent from raw image bytes. The media type is detected from the
+// data itself, so callers don't need to know it ahead of time. An error wrapping ErrUnsupportedMediaType is returned
+// if the data is not a JPEG, PNG, GIF, or WebP image.
+func NewImageContentFromBytes(data []byte) (*MessageContent, error) {
+	var mediaType = http.DetectContentType(data)
+	if !supportedImageMediaTypes[mediaType] {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMediaType, mediaType)
+	}
+
+	return &MessageContent{
+		Type: "image",
+		Source: &MediaSource{
+			Type:      "base64",
+			MediaType: mediaType,
+			Data:      base64.StdEncoding.EncodeToString(data),
+		},
+	}, nil
+}
+
+const (
+	// MaxImageLongEdge is the longest edge, in pixels, an image can have before the API scales it down.
+	MaxImageLongEdge = 1568
+	// MaxImageTokens is the approximate number of tokens an image can use before the API scales it down.
+	MaxImageTokens = 1600
+	// pixelsPerImageToken is the approximate number of pixels per image token.
+	pixelsPerImageToken = 750
+	// MaxImagesPerRequest is the maximum number of images the API accepts in a single request. Other surfaces accept
+	// fewer (e.g. 20 on claude.ai).
+	MaxImagesPerRequest = 100
+	// MaxImageBytes is the maximum size, in bytes, of a base64 image accepted by the API, before encoding.
+	MaxImageBytes = 5 << 20
+)
+
+// ImageDimensions returns the width and height of the JPEG, PNG, or GIF image |data|, reading only its header.
+// An error wrapping ErrUnsupportedMediaType is returned for other formats (including WebP).
+func ImageDimensions(data []byte) (width, height int, err error) {
+	var c image.Config
+	if c, _, err = image.DecodeConfig(bytes.NewReader(data)); err != nil {
+		return 0, 0, fmt.Errorf("%w: %v", ErrUnsupportedMediaType, err)
+	}
+
+	return c.Width, c.Height, nil
+}
+
+// ImageTokens returns the approximate number of input tokens an image of |width| x |height| pixels uses, after any
+// scaling by the API.
+func ImageTokens(width, height int) int {
+	width, height = ScaledImageSize(width, height)
+	return width * height / pixelsPerImageToken
+}
+
+// WillDownsample returns true if the API will scale down an image of |width| x |height| pixels before the model sees
+// it. There is no parameter to disable this, so for detail-sensitive images (e.g. OCR of small text) callers should
+// check this and resize or crop (e.g. into tiles) deliberately rather than letting the server down-sample.
+func WillDownsample(width, height int) bool {
+	var w, h = ScaledImageSize(width, height)
+	return w != width || h != height
+}
+
+// ScaledImageSize returns the size the API scales an image of |width| x |height| pixels to: the largest size with the
+// same aspect ratio whose long edge is at most MaxImageLongEdge and which uses at most MaxImageTokens. Images that are
+// already small enough are returned unchanged.
+func ScaledImageSize(width, height int) (int, int) {
+	if width <= 0 || height <= 0 {
+		return width, height
+	}
+
+	var scale = 1.0
+	var long = width
+	if height > long {
+		long = height
+	}
+	if long > MaxImageLongEdge {
+		scale = float64(MaxImageLongEdge) / float64(long)
+	}
+	var maxPixels = float64(MaxImageTokens * pixelsPerImageToken)
+	if pixels := float64(width) * float64(height) * scale * scale; pixels > maxPixels {
+		scale *= math.Sqrt(maxPixels / pixels)
+	}
+	if scale == 1 {
+		return width, height
+	}
+
+	return int(float64(width) * scale), int(float64(height) * scale)
+}
+
+// validateImages returns an error if |msgs| contain more than MaxImagesPerRequest images (including those in tool
+// results), or a base64 image which is larger than MaxImageBytes once decoded.
+func validateImages(msgs []*Message) error {
+	var n int
+	var check func(i int, blocks []*MessageContent) error
+	check = func(i int, blocks []*MessageContent) error {
+		for _, c := range blocks {
+			if c == nil {
+				continue
+			}
+			if err := check(i, c.ContentBlocks); err != nil {
+				return err
+			}
+			if c.Type != "image" {
+				continue
+			}
+
+			if n++; n > MaxImagesPerRequest {
+				return fmt.Errorf("%w: more than %d", ErrTooManyImages, MaxImagesPerRequest)
+			}
+			if c.Source != nil && c.Source.Type == "base64" {
+				if size := decodedLen(c.Source.Data); size > MaxImageBytes {
+					return fmt.Errorf("%w: image %d (message %d) is %d bytes, more than %d", ErrImageTooLarge, n, i, size, MaxImageBytes)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	for i, m := range msgs {
+		if err := check(i, m.Content); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// decodedLen returns the length of the base64 data |s| once decoded, without decoding it.
+func decodedLen(s string) int {
+	var n = base64.StdEncoding.DecodedLen(len(s))
+	for i := len(s) - 1; i >= 0 && i >= len(s)-2 && s[i] == '='; i-- {
+		n--
+	}
+
+	return n
+}
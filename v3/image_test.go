@@ -0,0 +1,106 @@
+package v3
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/png"
+	"testing"
+)
+
+func TestImageDownsampling(t *testing.T) {
+	var tests = []struct {
+		name         string
+		width        int
+		height       int
+		downsampled  bool
+		scaledWidth  int
+		scaledHeight int
+	}{
+		{name: "Small", width: 1000, height: 1000, scaledWidth: 1000, scaledHeight: 1000},
+		{name: "Long Edge", width: 3136, height: 100, downsampled: true, scaledWidth: 1568, scaledHeight: 50},
+		{name: "Too Many Pixels", width: 1500, height: 1500, downsampled: true, scaledWidth: 1095, scaledHeight: 1095},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b bytes.Buffer
+			if err := png.Encode(&b, image.NewGray(image.Rect(0, 0, tt.width, tt.height))); err != nil {
+				t.Fatal(err)
+			}
+
+			var w, h, err = ImageDimensions(b.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if w != tt.width || h != tt.height {
+				t.Errorf("ImageDimensions() = %dx%d, want %dx%d", w, h, tt.width, tt.height)
+			}
+
+			if got := WillDownsample(w, h); got != tt.downsampled {
+				t.Errorf("WillDownsample() = %v, want %v", got, tt.downsampled)
+			}
+			if sw, sh := ScaledImageSize(w, h); sw != tt.scaledWidth || sh != tt.scaledHeight {
+				t.Errorf("ScaledImageSize() = %dx%d, want %dx%d", sw, sh, tt.scaledWidth, tt.scaledHeight)
+			}
+		})
+	}
+}
+
+func TestRequestValidateImages(t *testing.T) {
+	var image = func(size int) *MessageContent {
+		return &MessageContent{Type: "image", Source: &MediaSource{
+			Type:      "base64",
+			MediaType: "image/png",
+			Data:      base64.StdEncoding.EncodeToString(make([]byte, size)),
+		}}
+	}
+	var images = func(n int) []*MessageContent {
+		var out = make([]*MessageContent, n)
+		for i := range out {
+			out[i] = image(16)
+		}
+		return out
+	}
+
+	var tests = []struct {
+		name    string
+		content []*MessageContent
+		err     error
+	}{
+		{name: "Valid", content: images(MaxImagesPerRequest), err: nil},
+		{name: "Too Many", content: images(MaxImagesPerRequest + 1), err: ErrTooManyImages},
+		{name: "Largest", content: []*MessageContent{image(MaxImageBytes)}, err: nil},
+		{name: "Too Large", content: []*MessageContent{image(MaxImageBytes + 1)}, err: ErrImageTooLarge},
+		{
+			name: "Too Many With Tool Results",
+			content: append([]*MessageContent{{
+				Type:          "tool_result",
+				ToolUseID:     "toolu_1",
+				ContentBlocks: []*MessageContent{image(16)},
+			}}, images(MaxImagesPerRequest)...),
+			err: ErrTooManyImages,
+		},
+		{name: "URL", content: []*MessageContent{{Type: "image", Source: &MediaSource{Type: "url", URL: "https://example.com/a.png"}}}, err: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &Request[Message]{
+				Model:     Claude3Haiku20240307,
+				Messages:  []*Message{{Role: RoleUser, Content: tt.content}},
+				MaxTokens: 1024,
+			}
+			if tt.content[0].Type == "tool_result" {
+				r.Messages = append([]*Message{
+					{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: "Chart it."}}},
+					{Role: RoleAssistant, Content: []*MessageContent{{Type: "tool_use", ID: "toolu_1", Name: "chart", Input: []byte(`{}`)}}},
+				}, r.Messages...)
+			}
+			if err := r.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Request.Validate() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package v3
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoJSON is returned by ExtractJSON when text doesn't contain a valid JSON value.
+var ErrNoJSON = errors.New("no valid json found")
+
+// codeFence delimits a markdown code block.
+const codeFence = "```"
+
+// ExtractJSON returns the JSON value in the model output |s|. Output which should be bare JSON (e.g. when prefilled
+// with "{" or asked for JSON) is sometimes wrapped in a markdown code fence, with or without a language tag (e.g.
+// "```json"), and sometimes preceded by prose ("Here's the JSON:"). Surrounding whitespace is ignored; if |s| isn't
+// valid JSON as is, the contents of its fenced code blocks are tried in order. The first valid JSON value is returned;
+// if there's none, an error wrapping ErrNoJSON is returned.
+func ExtractJSON(s string) (json.RawMessage, error) {
+	var candidate = strings.TrimSpace(s)
+	if json.Valid([]byte(candidate)) {
+		return json.RawMessage(candidate), nil
+	}
+
+	for rest := s; ; {
+		var start = strings.Index(rest, codeFence)
+		if start < 0 {
+			break
+		}
+		rest = rest[start+len(codeFence):]
+
+		// Skip the language tag, if any, which runs to the end of the opening fence's line (unless the JSON starts on
+		// that line).
+		if nl := strings.IndexByte(rest, '\n'); nl >= 0 && !strings.ContainsAny(rest[:nl], "{[`") {
+			rest = rest[nl+1:]
+		}
+
+		var body = rest
+		if end := strings.Index(rest, codeFence); end >= 0 {
+			body, rest = rest[:end], rest[end+len(codeFence):]
+		} else {
+			// An unterminated fence (e.g. the response hit max_tokens or a stop sequence) runs to the end.
+			rest = ""
+		}
+
+		if candidate = strings.TrimSpace(body); json.Valid([]byte(candidate)) {
+			return json.RawMessage(candidate), nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: %q", ErrNoJSON, truncate(s, 64))
+}
+
+// truncate returns |s|, cut to at most |n| bytes (on a rune boundary) with "..." appended if it was longer.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
+	}
+
+	return s[:n] + "..."
+}
+
+// Marshal is like json.Marshal, but doesn't escape HTML characters (<, >, &) in strings. The escaping is unnecessary
+// for the API, and inflates prompts containing HTML or code.
+func Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	var enc = json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+
+	// Encode terminates the value with a newline.
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
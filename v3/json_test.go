@@ -0,0 +1,39 @@
+package v3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestExtractJSON(t *testing.T) {
+	var tests = []struct {
+		name string
+		in   string
+		exp  string
+		err  error
+	}{
+		{name: "Bare", in: ` {"a": 1}` + "\n", exp: `{"a": 1}`},
+		{name: "Array", in: `[1, 2]`, exp: `[1, 2]`},
+		{name: "Fenced With Tag", in: "```json\n{\"a\": 1}\n```", exp: `{"a": 1}`},
+		{name: "Fenced Without Tag", in: "```\n{\"a\": 1}\n```\n", exp: `{"a": 1}`},
+		{name: "Fenced On One Line", in: "```{\"a\": 1}```", exp: `{"a": 1}`},
+		{name: "Leading Prose", in: "Here's the JSON:\n\n```json\n{\"a\": 1}\n```\n\nLet me know if you need anything else.", exp: `{"a": 1}`},
+		{name: "Unterminated Fence", in: "```json\n{\"a\": 1}\n", exp: `{"a": 1}`},
+		{name: "Second Fence", in: "Run:\n```bash\ncurl example.com\n```\nto get:\n```json\n{\"a\": 1}\n```", exp: `{"a": 1}`},
+		{name: "Invalid", in: "```json\n{\"a\": 1\n```", err: ErrNoJSON},
+		{name: "Prose", in: "I can't help with that.", err: ErrNoJSON},
+		{name: "Empty", in: "", err: ErrNoJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = ExtractJSON(tt.in)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("ExtractJSON() error = %v, wantErr %v", err, tt.err)
+			}
+			if string(got) != tt.exp {
+				t.Errorf("ExtractJSON() = %s, want %s", got, tt.exp)
+			}
+		})
+	}
+}
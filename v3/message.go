@@ -1,6 +1,9 @@
 package v3
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+)
 
 // Message represents a message sent to the API.
 type Message struct {
@@ -19,38 +22,186 @@ type ShortHandMessage struct {
 	Content string `json:"content"`
 }
 
+// ToShortHand returns |m| in the shorthand format. The second return value is false (and the first nil) if |m| can't be
+// represented as a ShortHandMessage, i.e. its content is anything other than a single text block.
+func (m *Message) ToShortHand() (*ShortHandMessage, bool) {
+	if len(m.Content) != 1 || m.Content[0] == nil || m.Content[0].Type != "text" {
+		return nil, false
+	}
+
+	return &ShortHandMessage{Role: m.Role, Content: m.Content[0].Text}, true
+}
+
+// ToMessage returns |m| as a Message with a single text block.
+func (m *ShortHandMessage) ToMessage() *Message {
+	return &Message{
+		Role:    m.Role,
+		Content: []*MessageContent{{Type: "text", Text: m.Content}},
+	}
+}
+
 // MessageContent represents the content of a message.
 type MessageContent struct {
-	// Type is the type of the content. It can be either "text", "image", or "tool_use", or "tool_result"
-	// ("tool_result" is only used when there's an error with the tool usage by the model and the model is being instructed to fix it in a subsequent call).
+	// Type is the type of the content. It can be either "text", "image", "tool_use", "tool_result", "thinking",
+	// "redacted_thinking", "server_tool_use", "web_search_tool_result", "code_execution_tool_result", or
+	// "container_upload".
 	Type string `json:"type"`
 	// Text is the text content of the message. Leave this empty if passing an image.
 	Text string `json:"text,omitempty"`
 	// Source is the media source of the message. Leave this empty if passing text.
 	Source *MediaSource `json:"source,omitempty"`
+	// ID is the unique identifier of a "tool_use" block. It is referenced by the ToolUseID of the corresponding
+	// "tool_result" block.
+	ID string `json:"id,omitempty"`
 	// Name is the name of the tool used (if any) .
 	Name string `json:"name,omitempty"`
-	// Input is the input of for a specified tool (if any).
+	// Input is the input of for a specified tool (if any). This includes the input of a "server_tool_use" block, which
+	// (like its ID and Name) must be sent back unmodified when continuing a turn.
 	Input json.RawMessage `json:"input,omitempty"`
 	// Content is the result of a calling specified tool (if any).
-	Content string `json:"content,omitempty"`
-	// IsError is true only when there is an error with the first tool usage and the model is being instructed to try again.
+	Content string `json:"-"`
+	// ContentBlocks is the result of a "tool_result" block when it is more than text, e.g. an image of a chart a tool
+	// rendered. It is sent as the block's content, in place of Content. Only "text" and "image" blocks are allowed.
+	ContentBlocks []*MessageContent `json:"-"`
+	// CodeExecutionResult is the result of a "code_execution_tool_result" block. It is sent as the block's content.
+	CodeExecutionResult *CodeExecutionResult `json:"-"`
+	// RawContent is the content of a block whose content this package doesn't model, e.g. the results of a
+	// "web_search_tool_result" block (which include encrypted content the API needs back). It holds the block's
+	// content verbatim, and is sent as the block's content unchanged, so that server tool results survive a round trip
+	// (e.g. when continuing a paused turn).
+	RawContent json.RawMessage `json:"-"`
+	// FileID is the ID of the file uploaded to the code execution container by a "container_upload" block.
+	FileID string `json:"file_id,omitempty"`
+	// IsError is true when the tool call failed and Content describes the error rather than a result.
 	IsError bool `json:"is_error,omitempty"`
-	// ToolUseID is the ID of the tool usage, only used when the model is instructed to try again.
+	// ToolUseID is the ID of the "tool_use" block a "tool_result" block is responding to.
 	ToolUseID string `json:"tool_use_id,omitempty"`
+	// Thinking is Claude's reasoning in a "thinking" block. It must be sent back unmodified.
+	Thinking string `json:"thinking,omitempty"`
+	// Signature is the cryptographic signature of a "thinking" block, used by the API to verify that the block was
+	// generated by Claude. It must be sent back unmodified.
+	Signature string `json:"signature,omitempty"`
+	// Data is the encrypted reasoning of a "redacted_thinking" block. It must be sent back unmodified.
+	Data string `json:"data,omitempty"`
+	// CacheControl is the cache control of the block. If set, the prompt up to and including this block is cached.
+	// Thinking blocks can't be marked. See PlaceCacheBreakpoints.
+	CacheControl *CacheControl `json:"cache_control,omitempty"`
+}
+
+// marshalMessageContent is a type alias for MessageContent to allow custom JSON marshaling.
+type marshalMessageContent MessageContent
+
+// MarshalJSON implements a custom JSON marshaling for the MessageContent type. The "content" field is sent as
+// RawContent, CodeExecutionResult, or ContentBlocks if any is set, and as the Content string otherwise.
+func (c MessageContent) MarshalJSON() ([]byte, error) {
+	var aux = &struct {
+		marshalMessageContent
+		ContentField any `json:"content,omitempty"`
+	}{
+		marshalMessageContent: marshalMessageContent(c),
+	}
+
+	switch {
+	case c.RawContent != nil:
+		aux.ContentField = c.RawContent
+	case c.CodeExecutionResult != nil:
+		aux.ContentField = c.CodeExecutionResult
+	case c.ContentBlocks != nil:
+		aux.ContentField = c.ContentBlocks
+	case c.Content != "":
+		aux.ContentField = c.Content
+	}
+
+	return Marshal(aux)
+}
+
+// UnmarshalJSON implements a custom JSON unmarshaling for the MessageContent type. The "content" field is decoded into
+// CodeExecutionResult for "code_execution_tool_result" blocks; for "tool_result" blocks, into Content when it is a
+// string and into ContentBlocks when it is an array. The content of other blocks is kept verbatim in RawContent.
+func (c *MessageContent) UnmarshalJSON(b []byte) error {
+	var aux = &struct {
+		*marshalMessageContent
+		ContentField json.RawMessage `json:"content,omitempty"`
+	}{
+		marshalMessageContent: (*marshalMessageContent)(c),
+	}
+
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+
+	c.Content = ""
+	c.ContentBlocks = nil
+	c.CodeExecutionResult = nil
+	c.RawContent = nil
+
+	var raw = bytes.TrimSpace(aux.ContentField)
+	switch {
+	case len(raw) == 0 || bytes.Equal(raw, []byte("null")):
+		return nil
+	case c.Type == "code_execution_tool_result":
+		c.CodeExecutionResult = &CodeExecutionResult{}
+		return json.Unmarshal(raw, c.CodeExecutionResult)
+	case c.Type != "tool_result":
+		c.RawContent = append(json.RawMessage(nil), raw...)
+	case raw[0] == '"':
+		return json.Unmarshal(raw, &c.Content)
+	case raw[0] == '[':
+		return json.Unmarshal(raw, &c.ContentBlocks)
+	}
+
+	return nil
+}
+
+// CodeExecutionResult represents the result of running code with the code execution tool.
+type CodeExecutionResult struct {
+	// Type is the type of the result. It is either "code_execution_result" or "code_execution_tool_result_error".
+	Type string `json:"type"`
+	// Stdout is the standard output of the executed code.
+	Stdout string `json:"stdout"`
+	// Stderr is the standard error of the executed code.
+	Stderr string `json:"stderr"`
+	// ReturnCode is the exit code of the executed code.
+	ReturnCode int `json:"return_code"`
+	// Content lists the files generated by the executed code.
+	Content []*CodeExecutionOutput `json:"content,omitempty"`
+	// ErrorCode is the reason the code could not be executed. Only set if Type is "code_execution_tool_result_error".
+	ErrorCode string `json:"error_code,omitempty"`
+}
+
+// FileIDs returns the IDs of the files generated by the executed code. They can be downloaded with the Files API.
+func (r *CodeExecutionResult) FileIDs() []string {
+	var out []string
+	for _, o := range r.Content {
+		if o != nil && o.FileID != "" {
+			out = append(out, o.FileID)
+		}
+	}
+
+	return out
+}
+
+// CodeExecutionOutput represents a file generated by the code execution tool.
+type CodeExecutionOutput struct {
+	// Type is the type of the output. Currently only "code_execution_output" is supported.
+	Type string `json:"type"`
+	// FileID is the ID of the generated file.
+	FileID string `json:"file_id"`
 }
 
 // MediaSource represents the media source of a message.
 type MediaSource struct {
-	// Type is the type of the media source.
+	// Type is the type of the media source: "base64" or "url".
 	Type string `json:"type"`
-	// MediaType is the media type of the media source.
-	MediaType string `json:"media_type"`
-	// Data is the data of the media source.
-	Data string `json:"data"`
+	// MediaType is the media type of the media source. Only used by "base64" sources.
+	MediaType string `json:"media_type,omitempty"`
+	// Data is the data of the media source. Only used by "base64" sources.
+	Data string `json:"data,omitempty"`
+	// URL is the URL of the media. Only used by "url" sources.
+	URL string `json:"url,omitempty"`
 }
 
-// CacheControl represents the cache control of a system message.
+// CacheControl marks the end of a cacheable prefix of the prompt.
 type CacheControl struct {
 	// Type is the type of the cache control. Currently only "ephemeral" is supported.
 	Type string `json:"type"`
@@ -58,11 +209,90 @@ type CacheControl struct {
 
 // SystemMessage represents a system message.
 type SystemMessage struct {
-	// Type is the type of the system message. Currently only "text" is supported.
+	// Type is the type of the system message. The API only accepts "text" blocks in the system prompt; images and
+	// documents must be sent in a user message instead.
 	Type string `json:"type"`
 	// Text is the text content of the system message.
 	Text string `json:"text"`
-	// CacheControl is the cache control of the system message. If set, the system message will not be cached (assuming
-	// the prompt caching beta header is included in the request.
+	// CacheControl is the cache control of the system message. If set, the prompt up to and including this block is
+	// cached. Each block carries its own marker, so a system prompt split into several sections can be cached at
+	// more than one boundary.
 	CacheControl *CacheControl `json:"cache_control,omitempty"`
 }
+
+// Clone returns a deep copy of |m|.
+func (m *Message) Clone() *Message {
+	if m == nil {
+		return nil
+	}
+
+	var out = &Message{Role: m.Role}
+	if m.Content != nil {
+		out.Content = make([]*MessageContent, len(m.Content))
+		for i, c := range m.Content {
+			out.Content[i] = c.Clone()
+		}
+	}
+
+	return out
+}
+
+// Clone returns a deep copy of |c|.
+func (c *MessageContent) Clone() *MessageContent {
+	if c == nil {
+		return nil
+	}
+
+	var out = *c
+	if c.Source != nil {
+		var s = *c.Source
+		out.Source = &s
+	}
+	if c.Input != nil {
+		out.Input = append(json.RawMessage(nil), c.Input...)
+	}
+	if c.RawContent != nil {
+		out.RawContent = append(json.RawMessage(nil), c.RawContent...)
+	}
+	if c.CacheControl != nil {
+		var cc = *c.CacheControl
+		out.CacheControl = &cc
+	}
+	if c.ContentBlocks != nil {
+		out.ContentBlocks = make([]*MessageContent, len(c.ContentBlocks))
+		for i, b := range c.ContentBlocks {
+			out.ContentBlocks[i] = b.Clone()
+		}
+	}
+	if c.CodeExecutionResult != nil {
+		var r = *c.CodeExecutionResult
+		if r.Content != nil {
+			r.Content = make([]*CodeExecutionOutput, len(c.CodeExecutionResult.Content))
+			for i, o := range c.CodeExecutionResult.Content {
+				if o == nil {
+					continue
+				}
+				var oo = *o
+				r.Content[i] = &oo
+			}
+		}
+		out.CodeExecutionResult = &r
+	}
+
+	return &out
+}
+
+// Clone returns a deep copy of |s|.
+func (s *SystemMessage) Clone() *SystemMessage {
+	if s == nil {
+		return nil
+	}
+
+	var out = *s
+	if s.CacheControl != nil {
+		var cc = *s.CacheControl
+		out.CacheControl = &cc
+	}
+
+	return &out
+}
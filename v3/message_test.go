@@ -0,0 +1,157 @@
+package v3
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestMessageContentJSON(t *testing.T) {
+	var tests = []struct {
+		name string
+		json string
+	}{
+		{
+			name: "Text",
+			json: `{"type":"text","text":"Hello"}`,
+		},
+		{
+			name: "Tool Result",
+			json: `{"type":"tool_result","tool_use_id":"toolu_1","content":"72 degrees"}`,
+		},
+		{
+			name: "Tool Result With Image",
+			json: `{"type":"tool_result","tool_use_id":"toolu_1","content":[{"type":"text","text":"Chart:"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgo="}}]}`,
+		},
+		{
+			name: "Code Execution Result",
+			json: `{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"done\n","stderr":"","return_code":0,"content":[{"type":"code_execution_output","file_id":"file_1"}]}}`,
+		},
+		{
+			name: "Server Tool Use",
+			json: `{"type":"server_tool_use","id":"srvtoolu_1","name":"web_search","input":{"query":"weather in San Francisco"}}`,
+		},
+		{
+			name: "Web Search Result",
+			json: `{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":[{"type":"web_search_result","url":"https://example.com/weather","title":"Weather","encrypted_content":"EqgfCioIARgB","page_age":"April 30, 2025"}]}`,
+		},
+		{
+			name: "Web Search Error",
+			json: `{"type":"web_search_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"web_search_tool_result_error","error_code":"max_uses_exceeded"}}`,
+		},
+		{
+			name: "Container Upload",
+			json: `{"type":"container_upload","file_id":"file_2"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c = &MessageContent{}
+			if err := json.Unmarshal([]byte(tt.json), c); err != nil {
+				t.Fatal(err)
+			}
+
+			var b, err = json.Marshal(c)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.json {
+				t.Errorf("round trip = %s, want %s", b, tt.json)
+			}
+		})
+	}
+}
+
+func TestCodeExecutionResultFileIDs(t *testing.T) {
+	var c = &MessageContent{}
+	var in = `{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"","stderr":"","return_code":0,"content":[{"type":"code_execution_output","file_id":"file_1"},{"type":"code_execution_output","file_id":"file_2"}]}}`
+	if err := json.Unmarshal([]byte(in), c); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids = c.CodeExecutionResult.FileIDs()
+	if len(ids) != 2 || ids[0] != "file_1" || ids[1] != "file_2" {
+		t.Errorf("FileIDs() = %v, want [file_1 file_2]", ids)
+	}
+}
+
+func TestMessageContentCloneNilOutput(t *testing.T) {
+	var c = &MessageContent{}
+	var in = `{"type":"code_execution_tool_result","tool_use_id":"srvtoolu_1","content":{"type":"code_execution_result","stdout":"","stderr":"","return_code":0,"content":[null,{"type":"code_execution_output","file_id":"file_1"}]}}`
+	if err := json.Unmarshal([]byte(in), c); err != nil {
+		t.Fatal(err)
+	}
+
+	var clone = c.Clone()
+	var out = clone.CodeExecutionResult.Content
+	if len(out) != 2 || out[0] != nil || out[1] == nil || out[1] == c.CodeExecutionResult.Content[1] {
+		t.Fatalf("Clone() content = %v, want a nil entry and a copy of file_1", out)
+	}
+	if ids := clone.CodeExecutionResult.FileIDs(); len(ids) != 1 || ids[0] != "file_1" {
+		t.Errorf("FileIDs() = %v, want [file_1]", ids)
+	}
+}
+
+func TestMessageToShortHand(t *testing.T) {
+	var tests = []struct {
+		name    string
+		content []*MessageContent
+		exp     *ShortHandMessage
+	}{
+		{name: "Single Text Block", content: []*MessageContent{{Type: "text", Text: "Hi"}}, exp: &ShortHandMessage{Role: RoleUser, Content: "Hi"}},
+		{name: "Multiple Blocks", content: []*MessageContent{{Type: "text", Text: "Hi"}, {Type: "text", Text: "there"}}},
+		{name: "Non-Text Block", content: []*MessageContent{{Type: "image", Source: &MediaSource{Type: "base64"}}}},
+		{name: "Nil Block", content: []*MessageContent{nil}},
+		{name: "No Blocks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, ok = (&Message{Role: RoleUser, Content: tt.content}).ToShortHand()
+			if ok != (tt.exp != nil) {
+				t.Fatalf("ToShortHand() ok = %v, want %v", ok, tt.exp != nil)
+			}
+			if tt.exp != nil && *got != *tt.exp {
+				t.Errorf("ToShortHand() = %+v, want %+v", got, tt.exp)
+			}
+			if !ok && got != nil {
+				t.Errorf("ToShortHand() = %+v, want nil", got)
+			}
+		})
+	}
+}
+
+func TestNewUserMessageWith(t *testing.T) {
+	var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+	var m, err = NewUserMessageWith(
+		Text("Image 1:"),
+		ImageBytes(png),
+		Text("Image 2:"),
+		ImageURL("https://example.com/cat.jpg"),
+		Text("Compare them."),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b []byte
+	if b, err = json.Marshal(m); err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `{"role":"user","content":[` +
+		`{"type":"text","text":"Image 1:"},` +
+		`{"type":"image","source":{"type":"base64","media_type":"image/png","data":"iVBORw0KGgoAAAANSUhEUg=="}},` +
+		`{"type":"text","text":"Image 2:"},` +
+		`{"type":"image","source":{"type":"url","url":"https://example.com/cat.jpg"}},` +
+		`{"type":"text","text":"Compare them."}]}`
+	if string(b) != exp {
+		t.Errorf("NewUserMessageWith() = %s, want %s", b, exp)
+	}
+
+	if _, err = NewUserMessageWith(Text("a"), ImageURL("cat.jpg")); !errors.Is(err, ErrInvalidImageURL) {
+		t.Errorf("NewUserMessageWith() error = %v, wantErr %v", err, ErrInvalidImageURL)
+	}
+}
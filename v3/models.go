@@ -1,5 +1,11 @@
 package v3
 
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
 // Model represents all models.
 type Model int
 
@@ -24,6 +30,25 @@ const (
 
 	// Claude3Dot5Sonnet20241022 This version shows significant improvements in coding capabilities, improving performance on SWE-bench Verified from 33.4% to 49.0%, scoring higher than all publicly available models 2. The upgraded Claude 3.5 Sonnet delivers these improvements while maintaining the same price and speed as its predecessor .
 	Claude3Dot5Sonnet20241022
+
+	// Claude3Dot5Haiku20241022 is Anthropic's fastest 3.5 model.
+	Claude3Dot5Haiku20241022
+
+	// Claude3Dot7Sonnet20250219 is Anthropic's first hybrid reasoning model, supporting extended thinking.
+	Claude3Dot7Sonnet20250219
+
+	// Claude3OpusLatest is an alias which the API resolves to the latest Claude 3 Opus snapshot. Responses report the
+	// snapshot which served the request, so a response's Model may differ from the requested alias.
+	Claude3OpusLatest
+
+	// Claude3Dot5SonnetLatest is an alias which the API resolves to the latest Claude 3.5 Sonnet snapshot.
+	Claude3Dot5SonnetLatest
+
+	// Claude3Dot5HaikuLatest is an alias which the API resolves to the latest Claude 3.5 Haiku snapshot.
+	Claude3Dot5HaikuLatest
+
+	// Claude3Dot7SonnetLatest is an alias which the API resolves to the latest Claude 3.7 Sonnet snapshot.
+	Claude3Dot7SonnetLatest
 )
 
 // String implements the fmt.Stringer interface.
@@ -31,12 +56,19 @@ func (c Model) String() string {
 	return completionToString[c]
 }
 
+// BedrockString returns the string representation of the model for use with AWS Bedrock.
+func (c Model) BedrockString() string {
+	return bedrockToString[c]
+}
+
 // MarshalText implements the encoding.TextMarshaler interface.
 func (c Model) MarshalText() ([]byte, error) {
 	return []byte(c.String()), nil
 }
 
-// UnmarshalText implements the encoding.TextUnmarshaler interface.
+// UnmarshalText implements the encoding.TextUnmarshaler interface. In addition to the canonical model ids, it accepts
+// the aliases "opus", "sonnet", and "haiku" for the latest model of each tier; MarshalText always emits the canonical
+// id (e.g. "sonnet" is marshaled as "claude-3-7-sonnet-latest").
 // On unrecognized value, it sets |e| to Unknown.
 func (c *Model) UnmarshalText(b []byte) error {
 	if val, ok := stringToCompletion[(string(b))]; ok {
@@ -55,6 +87,12 @@ var completionToString = map[Model]string{
 	Claude3Haiku20240307:      "claude-3-haiku-20240307",
 	Claude3Dot5Sonnet20240620: "claude-3-5-sonnet-20240620",
 	Claude3Dot5Sonnet20241022: "claude-3-5-sonnet-20241022",
+	Claude3Dot5Haiku20241022:  "claude-3-5-haiku-20241022",
+	Claude3Dot7Sonnet20250219: "claude-3-7-sonnet-20250219",
+	Claude3OpusLatest:         "claude-3-opus-latest",
+	Claude3Dot5SonnetLatest:   "claude-3-5-sonnet-latest",
+	Claude3Dot5HaikuLatest:    "claude-3-5-haiku-latest",
+	Claude3Dot7SonnetLatest:   "claude-3-7-sonnet-latest",
 }
 
 var stringToCompletion = map[string]Model{
@@ -63,4 +101,171 @@ var stringToCompletion = map[string]Model{
 	"claude-3-haiku-20240307":    Claude3Haiku20240307,
 	"claude-3-5-sonnet-20240620": Claude3Dot5Sonnet20240620,
 	"claude-3-5-sonnet-20241022": Claude3Dot5Sonnet20241022,
+	"claude-3-5-haiku-20241022":  Claude3Dot5Haiku20241022,
+	"claude-3-7-sonnet-20250219": Claude3Dot7Sonnet20250219,
+	"claude-3-opus-latest":       Claude3OpusLatest,
+	"claude-3-5-sonnet-latest":   Claude3Dot5SonnetLatest,
+	"claude-3-5-haiku-latest":    Claude3Dot5HaikuLatest,
+	"claude-3-7-sonnet-latest":   Claude3Dot7SonnetLatest,
+
+	// Vendor-neutral aliases for the latest model of each tier, accepted (e.g. in config files) but never emitted.
+	"opus":   Claude3OpusLatest,
+	"sonnet": Claude3Dot7SonnetLatest,
+	"haiku":  Claude3Dot5HaikuLatest,
+}
+
+var bedrockToString = map[Model]string{
+	Claude3Opus20240229:       "anthropic.claude-3-opus-20240229-v1:0",
+	Claude3Sonnet20240229:     "anthropic.claude-3-sonnet-20240229-v1:0",
+	Claude3Haiku20240307:      "anthropic.claude-3-haiku-20240307-v1:0",
+	Claude3Dot5Sonnet20240620: "anthropic.claude-3-5-sonnet-20240620-v1:0",
+	Claude3Dot5Sonnet20241022: "anthropic.claude-3-5-sonnet-20241022-v2:0",
+	Claude3Dot5Haiku20241022:  "anthropic.claude-3-5-haiku-20241022-v1:0",
+	Claude3Dot7Sonnet20250219: "anthropic.claude-3-7-sonnet-20250219-v1:0",
+}
+
+const (
+	// BetaMaxTokens35Sonnet is the beta which raises Claude 3.5 Sonnet (20240620)'s max output tokens to 8192.
+	BetaMaxTokens35Sonnet = "max-tokens-3-5-sonnet-2024-07-15"
+	// BetaOutput128k is the beta which raises Claude 3.7 Sonnet's max output tokens to 128K.
+	BetaOutput128k = "output-128k-2025-02-19"
+)
+
+// ErrMaxTokensExceeded indicates that a request's MaxTokens exceeds the model's maximum output tokens.
+var ErrMaxTokensExceeded = errors.New("max_tokens exceeds the model's maximum output tokens")
+
+// outputLimit is a model's maximum output tokens, and the beta (if any) which raises it.
+type outputLimit struct {
+	max     int
+	beta    string
+	betaMax int
+}
+
+var maxOutputTokens = map[Model]outputLimit{
+	Claude3Opus20240229:       {max: 4096},
+	Claude3Sonnet20240229:     {max: 4096},
+	Claude3Haiku20240307:      {max: 4096},
+	Claude3Dot5Sonnet20240620: {max: 4096, beta: BetaMaxTokens35Sonnet, betaMax: 8192},
+	Claude3Dot5Sonnet20241022: {max: 8192},
+	Claude3Dot5Haiku20241022:  {max: 8192},
+	Claude3Dot7Sonnet20250219: {max: 64000, beta: BetaOutput128k, betaMax: 128000},
+	Claude3OpusLatest:         {max: 4096},
+	Claude3Dot5SonnetLatest:   {max: 8192},
+	Claude3Dot5HaikuLatest:    {max: 8192},
+	Claude3Dot7SonnetLatest:   {max: 64000, beta: BetaOutput128k, betaMax: 128000},
+}
+
+// MaxOutputTokens returns the maximum output tokens of |c| when the beta headers |betas| are sent, or 0 if it isn't
+// known.
+func (c Model) MaxOutputTokens(betas []string) int {
+	var l, ok = maxOutputTokens[c]
+	if !ok {
+		return 0
+	}
+
+	for _, b := range betas {
+		if l.beta != "" && b == l.beta {
+			return l.betaMax
+		}
+	}
+
+	return l.max
+}
+
+// ValidateMaxTokens returns an error if |n| isn't a valid MaxTokens for a request to |c| which sends the beta headers
+// |betas|: ErrInvalidMaxTokens if it isn't positive, or an error wrapping ErrMaxTokensExceeded if it's greater than
+// the model's maximum output tokens. If the model's limit isn't known, only the former is checked.
+func (c Model) ValidateMaxTokens(n int, betas []string) error {
+	if n <= 0 {
+		return ErrInvalidMaxTokens
+	}
+
+	if limit := c.MaxOutputTokens(betas); limit > 0 && n > limit {
+		return fmt.Errorf("%w: %d > %d for %s", ErrMaxTokensExceeded, n, limit, c)
+	}
+
+	return nil
+}
+
+// Tier is a model's class within its generation, from the fastest and cheapest to the most capable.
+type Tier int
+
+const (
+	// TierUnknown represents an unknown tier.
+	TierUnknown Tier = iota
+	// TierInstant is the tier of the legacy Claude Instant models.
+	TierInstant
+	// TierHaiku is the fastest and cheapest tier.
+	TierHaiku
+	// TierSonnet balances intelligence and speed.
+	TierSonnet
+	// TierOpus is the most capable tier.
+	TierOpus
+)
+
+// String implements the fmt.Stringer interface.
+func (t Tier) String() string {
+	return tierToString[t]
+}
+
+var tierToString = map[Tier]string{
+	TierUnknown: "unknown",
+	TierInstant: "instant",
+	TierHaiku:   "haiku",
+	TierSonnet:  "sonnet",
+	TierOpus:    "opus",
+}
+
+// modelFamily is a model's generation and tier, and whether it's a "-latest" alias rather than a snapshot.
+type modelFamily struct {
+	generation string
+	tier       Tier
+	alias      bool
+}
+
+var modelFamilies = map[Model]modelFamily{
+	Claude3Opus20240229:       {generation: "3", tier: TierOpus},
+	Claude3Sonnet20240229:     {generation: "3", tier: TierSonnet},
+	Claude3Haiku20240307:      {generation: "3", tier: TierHaiku},
+	Claude3Dot5Sonnet20240620: {generation: "3.5", tier: TierSonnet},
+	Claude3Dot5Sonnet20241022: {generation: "3.5", tier: TierSonnet},
+	Claude3Dot5Haiku20241022:  {generation: "3.5", tier: TierHaiku},
+	Claude3Dot7Sonnet20250219: {generation: "3.7", tier: TierSonnet},
+	Claude3OpusLatest:         {generation: "3", tier: TierOpus, alias: true},
+	Claude3Dot5SonnetLatest:   {generation: "3.5", tier: TierSonnet, alias: true},
+	Claude3Dot5HaikuLatest:    {generation: "3.5", tier: TierHaiku, alias: true},
+	Claude3Dot7SonnetLatest:   {generation: "3.7", tier: TierSonnet, alias: true},
+}
+
+// Tier returns the tier of |c|, or TierUnknown if it isn't known.
+func (c Model) Tier() Tier {
+	return modelFamilies[c].tier
+}
+
+// FallbackModels returns the models of |m|'s generation in lower tiers, most capable first, for degrading gracefully
+// under load (e.g. Opus → Sonnet → Haiku). Only snapshots (not "-latest" aliases) are returned; where a tier has
+// several, the latest comes first. It returns an empty slice for UnknownModel, or if |m| is already in its
+// generation's lowest tier.
+func FallbackModels(m Model) []Model {
+	var f, ok = modelFamilies[m]
+	if !ok {
+		return []Model{}
+	}
+
+	var out = []Model{}
+	for candidate, cf := range modelFamilies {
+		if cf.generation == f.generation && cf.tier < f.tier && !cf.alias {
+			out = append(out, candidate)
+		}
+	}
+	// Most capable tier first; within a tier, the latest snapshot (the highest Model value) first.
+	sort.Slice(out, func(i, j int) bool {
+		var ti, tj = out[i].Tier(), out[j].Tier()
+		if ti != tj {
+			return ti > tj
+		}
+		return out[i] > out[j]
+	})
+
+	return out
 }
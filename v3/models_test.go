@@ -0,0 +1,96 @@
+package v3
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestModelValidateMaxTokens(t *testing.T) {
+	var tests = []struct {
+		name  string
+		model Model
+		n     int
+		betas []string
+		err   error
+	}{
+		{name: "Within Limit", model: Claude3Haiku20240307, n: 4096},
+		{name: "Over Limit", model: Claude3Haiku20240307, n: 4097, err: ErrMaxTokensExceeded},
+		{name: "Not Positive", model: Claude3Haiku20240307, n: 0, err: ErrInvalidMaxTokens},
+		{name: "Raised By Beta", model: Claude3Dot5Sonnet20240620, n: 8192, betas: []string{BetaMaxTokens35Sonnet}},
+		{name: "Without Beta", model: Claude3Dot5Sonnet20240620, n: 8192, err: ErrMaxTokensExceeded},
+		{name: "Other Model's Beta", model: Claude3Dot7Sonnet20250219, n: 128000, betas: []string{BetaMaxTokens35Sonnet}, err: ErrMaxTokensExceeded},
+		{name: "128K Beta", model: Claude3Dot7Sonnet20250219, n: 128000, betas: []string{BetaOutput128k}},
+		{name: "Unknown Model", model: UnknownModel, n: 1 << 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.model.ValidateMaxTokens(tt.n, tt.betas); !errors.Is(err, tt.err) {
+				t.Errorf("ValidateMaxTokens() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestFallbackModels(t *testing.T) {
+	var tests = []struct {
+		name  string
+		model Model
+		exp   []Model
+	}{
+		{name: "Opus", model: Claude3Opus20240229, exp: []Model{Claude3Sonnet20240229, Claude3Haiku20240307}},
+		{name: "Sonnet 3.5", model: Claude3Dot5Sonnet20241022, exp: []Model{Claude3Dot5Haiku20241022}},
+		{name: "Haiku", model: Claude3Haiku20240307, exp: []Model{}},
+		{name: "Unknown", model: UnknownModel, exp: []Model{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got = FallbackModels(tt.model)
+			if got == nil || len(got) != len(tt.exp) {
+				t.Fatalf("FallbackModels() = %v, want %v", got, tt.exp)
+			}
+			for i := range got {
+				if got[i] != tt.exp[i] {
+					t.Errorf("FallbackModels() = %v, want %v", got, tt.exp)
+				}
+			}
+		})
+	}
+
+	if got := Claude3Opus20240229.Tier(); got != TierOpus {
+		t.Errorf("Tier() = %v, want %v", got, TierOpus)
+	}
+}
+
+func TestModelTextAliases(t *testing.T) {
+	var tests = []struct {
+		in  string
+		exp Model
+		out string
+	}{
+		{in: "claude-3-5-haiku-20241022", exp: Claude3Dot5Haiku20241022, out: "claude-3-5-haiku-20241022"},
+		{in: "opus", exp: Claude3OpusLatest, out: "claude-3-opus-latest"},
+		{in: "sonnet", exp: Claude3Dot7SonnetLatest, out: "claude-3-7-sonnet-latest"},
+		{in: "haiku", exp: Claude3Dot5HaikuLatest, out: "claude-3-5-haiku-latest"},
+		{in: "Sonnet", exp: UnknownModel, out: ""},
+		{in: "gpt-4", exp: UnknownModel, out: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			var m Model
+			if err := m.UnmarshalText([]byte(tt.in)); err != nil {
+				t.Fatal(err)
+			}
+			if m != tt.exp {
+				t.Errorf("UnmarshalText(%q) = %v, want %v", tt.in, m, tt.exp)
+			}
+
+			var b, _ = m.MarshalText()
+			if string(b) != tt.out {
+				t.Errorf("MarshalText() = %q, want %q", b, tt.out)
+			}
+		})
+	}
+}
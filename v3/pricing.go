@@ -0,0 +1,60 @@
+package v3
+
+// Pricing is a model's list price, in US dollars per million tokens. Prompt cache writes and reads are priced relative
+// to Input (see Usage.BilledInputTokens).
+type Pricing struct {
+	// Input is the price of a million input tokens.
+	Input float64
+	// Output is the price of a million output tokens.
+	Output float64
+}
+
+// tokensPerPricingUnit is the number of tokens Pricing is quoted for.
+const tokensPerPricingUnit = 1_000_000
+
+var modelPricing = map[Model]Pricing{
+	Claude3Opus20240229:       {Input: 15, Output: 75},
+	Claude3Sonnet20240229:     {Input: 3, Output: 15},
+	Claude3Haiku20240307:      {Input: 0.25, Output: 1.25},
+	Claude3Dot5Sonnet20240620: {Input: 3, Output: 15},
+	Claude3Dot5Sonnet20241022: {Input: 3, Output: 15},
+	Claude3Dot5Haiku20241022:  {Input: 0.8, Output: 4},
+	Claude3Dot7Sonnet20250219: {Input: 3, Output: 15},
+	Claude3OpusLatest:         {Input: 15, Output: 75},
+	Claude3Dot5SonnetLatest:   {Input: 3, Output: 15},
+	Claude3Dot5HaikuLatest:    {Input: 0.8, Output: 4},
+	Claude3Dot7SonnetLatest:   {Input: 3, Output: 15},
+}
+
+// Pricing returns the list price of |c|, and false if it isn't known. Prices change; treat the result as an estimate.
+func (c Model) Pricing() (Pricing, bool) {
+	var p, ok = modelPricing[c]
+	return p, ok
+}
+
+// Cost returns the estimated cost, in US dollars, of a request to |model| with usage |u|, accounting for prompt
+// caching. It returns 0 if |model|'s pricing isn't known.
+func (u *Usage) Cost(model Model) float64 {
+	var p, ok = model.Pricing()
+	if !ok {
+		return 0
+	}
+
+	return (u.BilledInputTokens()*p.Input + float64(u.OutputTokens)*p.Output) / tokensPerPricingUnit
+}
+
+// EstimateSavings returns how much, in US dollars, prompt caching saved a request to |model| with usage |usage|,
+// compared to sending the same input uncached: cache reads save 90% of the input price, less the 25% surcharge on
+// cache writes. The result is negative for a request which only wrote to the cache (a cold request), since the write
+// pays off only once the cache is read. It returns 0 if |model|'s pricing isn't known.
+func EstimateSavings(model Model, usage Usage) float64 {
+	var p, ok = model.Pricing()
+	if !ok {
+		return 0
+	}
+
+	var saved = float64(usage.CacheReadInputTokens) * (1 - cacheReadMultiplier)
+	var surcharge = float64(usage.CacheCreationInputTokens) * (cacheWriteMultiplier - 1)
+
+	return (saved - surcharge) * p.Input / tokensPerPricingUnit
+}
@@ -0,0 +1,39 @@
+package v3
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEstimateSavings(t *testing.T) {
+	var tests = []struct {
+		name  string
+		model Model
+		usage Usage
+		exp   float64
+	}{
+		// 1M reads save 90% of $3.
+		{name: "Warm", model: Claude3Dot5Sonnet20241022, usage: Usage{CacheReadInputTokens: 1_000_000}, exp: 2.7},
+		// 1M writes cost 25% more than $3.
+		{name: "Cold", model: Claude3Dot5Sonnet20241022, usage: Usage{CacheCreationInputTokens: 1_000_000}, exp: -0.75},
+		{name: "Both", model: Claude3Opus20240229, usage: Usage{CacheReadInputTokens: 2_000_000, CacheCreationInputTokens: 1_000_000}, exp: 27 - 3.75},
+		{name: "Uncached", model: Claude3Haiku20240307, usage: Usage{InputTokens: 1_000_000}},
+		{name: "Unknown model", model: UnknownModel, usage: Usage{CacheReadInputTokens: 1_000_000}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EstimateSavings(tt.model, tt.usage); math.Abs(got-tt.exp) > 1e-9 {
+				t.Errorf("EstimateSavings() = %v, want %v", got, tt.exp)
+			}
+		})
+	}
+}
+
+func TestUsageCost(t *testing.T) {
+	var u = &Usage{InputTokens: 1_000_000, OutputTokens: 100_000, CacheReadInputTokens: 1_000_000}
+	// $3 input + $0.30 cache reads + $1.50 output.
+	if got := u.Cost(Claude3Dot7Sonnet20250219); math.Abs(got-4.8) > 1e-9 {
+		t.Errorf("Cost() = %v, want 4.8", got)
+	}
+}
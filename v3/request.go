@@ -1,8 +1,12 @@
 package v3
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
+	"unicode"
 )
 
 // RequestMessage represents a message sent to the API.
@@ -20,7 +24,9 @@ type Request[T RequestMessage] struct {
 	// Messages is a list of messages to send to the API. Required.
 	Messages []*T `json:"messages"`
 	// System is the system prompt. A system prompt is a way of providing context and instructions to Claude, such as
-	// specifying a particular goal or role. At most one of System or SystemMessages should be provided.
+	// specifying a particular goal or role. It is serialized as a plain string, which is all most requests need; use
+	// SystemMessages instead when the prompt needs to be split into blocks (e.g. to set cache boundaries). At most one
+	// of System or SystemMessages should be provided.
 	// https://docs.anthropic.com/claude/docs/system-prompts
 	// Optional.
 	System *string `json:"-"`
@@ -56,14 +62,220 @@ type Request[T RequestMessage] struct {
 	// TopK specifies to only sample from the top K options for each subsequent token. Used to remove "long tail" low
 	// probability responses.
 	// Optional. Defaults to -1, which disables it. You should either alter Temperature or TopP, but not both.
-	TopK *int `json:"topK,omitempty"`
+	TopK *int `json:"top_k,omitempty"`
 	// TopP does nucleus sampling, in which we compute the cumulative distribution over all the options for each
 	// subsequent token in decreasing probability order and cut it off once it reaches a particular probability
 	// specified by TopP.
+	// It is a probability between 0 and 1.
 	//	Optional: Defaults to -1, which disables it. You should either alter Temperature or TopP, but not both.
-	TopP *int `json:"topP,omitempty"`
+	TopP *float64 `json:"top_p,omitempty"`
 	// Metadata is an object describing metadata about the request. Optional.
 	Metadata *Metadata `json:"metadata,omitempty"`
+	// Thinking enables extended thinking. When enabled, its budget must be at least MinThinkingBudgetTokens and less
+	// than MaxTokens, Temperature must be unset (or 1), TopK must be unset, TopP must be unset or at least 0.95, and
+	// ToolChoice must not force tool use. Use NewThinking to construct it.
+	// Optional.
+	Thinking *Thinking `json:"thinking,omitempty"`
+	// Container is the ID of a code execution container to reuse (see Response.Container), preserving its files and
+	// state from earlier requests and saving its setup time. If unset, a new container is created when the code
+	// execution tool is used.
+	// Optional.
+	Container string `json:"container,omitempty"`
+	// Betas are beta features to enable for this request, in addition to any enabled for the client. They aren't part
+	// of the request body sent to the Anthropic API, which takes them in the |anthropic-beta| header; Bedrock takes
+	// them in the body (see BedrockMessageRequestBody in the root package).
+	// Optional.
+	Betas []string `json:"-"`
+}
+
+var (
+	// ErrUnknownModel indicates that a Request does not specify a known model.
+	ErrUnknownModel = errors.New("model must be specified")
+	// ErrEmptyMessages indicates that a Request does not contain any messages.
+	ErrEmptyMessages = errors.New("messages cannot be empty")
+	// ErrInvalidMaxTokens indicates that a Request's MaxTokens is not positive.
+	ErrInvalidMaxTokens = errors.New("max_tokens must be greater than 0")
+	// ErrSystemConflict indicates that both System and SystemMessages were provided.
+	ErrSystemConflict = errors.New("only one of System or SystemMessages should be provided")
+	// ErrInvalidSystemBlock indicates that a SystemMessage is not a text block.
+	ErrInvalidSystemBlock = errors.New("system messages must be text blocks")
+	// ErrInvalidPrefill indicates that an assistant prefill can't be added to a Request.
+	ErrInvalidPrefill = errors.New("invalid assistant prefill")
+)
+
+// Validate ensures that |r| is valid. It returns an error if |r| is invalid. Note: this only catches mistakes that can
+// be detected client side; a valid request can still be rejected by the API.
+//
+// Stop sequences which are legacy Text Completions turn markers (e.g. "\n\nHuman:") are allowed, but logged as a
+// warning.
+func (r *Request[T]) Validate() error {
+	if r.Model == UnknownModel {
+		return ErrUnknownModel
+	}
+	if len(r.Messages) == 0 {
+		return ErrEmptyMessages
+	}
+	if r.MaxTokens <= 0 {
+		return ErrInvalidMaxTokens
+	}
+	if err := ValidateStopSequences(r.StopSequences); err != nil {
+		return err
+	}
+	warnReservedStopSequences(r.StopSequences)
+	if r.System != nil && len(r.SystemMessages) > 0 {
+		return ErrSystemConflict
+	}
+	for _, m := range r.SystemMessages {
+		if m.Type != "text" {
+			return fmt.Errorf("%w: %q", ErrInvalidSystemBlock, m.Type)
+		}
+	}
+	for _, t := range r.Tools {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+	}
+	if err := r.validateThinking(); err != nil {
+		return err
+	}
+	if msgs, ok := any(r.Messages).([]*Message); ok {
+		if err := validateThinkingBlocks(msgs); err != nil {
+			return err
+		}
+		if err := ValidateToolPairing(msgs); err != nil {
+			return err
+		}
+		if err := validateImages(msgs); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Clone returns a deep copy of |r|. Messages, content, tools, and schemas are all copied, so the clone can be modified
+// (e.g. by appending to a message's content) without affecting |r|.
+func (r *Request[T]) Clone() *Request[T] {
+	if r == nil {
+		return nil
+	}
+
+	var out = *r
+	if r.Messages != nil {
+		out.Messages = make([]*T, len(r.Messages))
+		for i, m := range r.Messages {
+			switch v := any(m).(type) {
+			case *Message:
+				out.Messages[i] = any(v.Clone()).(*T)
+			case *ShortHandMessage:
+				if v != nil {
+					var sh = *v
+					out.Messages[i] = any(&sh).(*T)
+				}
+			}
+		}
+	}
+	if r.System != nil {
+		out.System = Optional(*r.System)
+	}
+	if r.SystemMessages != nil {
+		out.SystemMessages = make([]*SystemMessage, len(r.SystemMessages))
+		for i, m := range r.SystemMessages {
+			out.SystemMessages[i] = m.Clone()
+		}
+	}
+	if r.StopSequences != nil {
+		out.StopSequences = append([]string(nil), r.StopSequences...)
+	}
+	if r.Temperature != nil {
+		out.Temperature = Optional(*r.Temperature)
+	}
+	if r.ToolChoice != nil {
+		var tc = *r.ToolChoice
+		out.ToolChoice = &tc
+	}
+	if r.Tools != nil {
+		out.Tools = make([]*Tool, len(r.Tools))
+		for i, t := range r.Tools {
+			out.Tools[i] = t.Clone()
+		}
+	}
+	if r.TopK != nil {
+		out.TopK = Optional(*r.TopK)
+	}
+	if r.TopP != nil {
+		out.TopP = Optional(*r.TopP)
+	}
+	if r.Metadata != nil {
+		var md = *r.Metadata
+		out.Metadata = &md
+	}
+	if r.Thinking != nil {
+		var th = *r.Thinking
+		out.Thinking = &th
+	}
+	if r.Betas != nil {
+		out.Betas = append([]string(nil), r.Betas...)
+	}
+
+	return &out
+}
+
+// PrefillAssistant appends an assistant message with the text |prefix| to |r|, which the API uses as the start of
+// Claude's response. E.g. prefilling "{" is a lightweight way to get a JSON object back. The response's content won't
+// include the prefill, so callers should prepend |prefix| to it (e.g. prefix + resp.Text()).
+//
+// An error wrapping ErrInvalidPrefill is returned, and |r| isn't modified, if the last message isn't a user message
+// (the prefill must directly follow it), if |prefix| is empty or ends with whitespace (which the API rejects), or if
+// extended thinking is enabled (which doesn't support prefilling).
+func (r *Request[T]) PrefillAssistant(prefix string) error {
+	switch {
+	case prefix == "" || strings.TrimRightFunc(prefix, unicode.IsSpace) != prefix:
+		return fmt.Errorf("%w: prefix must be non-empty without trailing whitespace", ErrInvalidPrefill)
+	case r.Thinking != nil && r.Thinking.Type == thinkingEnabled:
+		return fmt.Errorf("%w: not supported with extended thinking", ErrInvalidPrefill)
+	case len(r.Messages) == 0 || roleOf(r.Messages[len(r.Messages)-1]) != RoleUser:
+		return fmt.Errorf("%w: must follow a user message", ErrInvalidPrefill)
+	}
+
+	var m = new(T)
+	switch v := any(m).(type) {
+	case *Message:
+		*v = Message{Role: RoleAssistant, Content: []*MessageContent{{Type: "text", Text: prefix}}}
+	case *ShortHandMessage:
+		*v = ShortHandMessage{Role: RoleAssistant, Content: prefix}
+	}
+	r.Messages = append(r.Messages, m)
+
+	return nil
+}
+
+// SerializedSize returns the size, in bytes, of the JSON body |r| is sent as (streaming requests are a few bytes
+// larger), without sending it. It can be used to decide whether to send large content inline or upload it first. Note
+// that it marshals |r|; callers which also need the body should marshal it themselves and use its length instead.
+func (r *Request[T]) SerializedSize() (int, error) {
+	var b, err = Marshal(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return len(b), nil
+}
+
+// roleOf returns the role of |m|.
+func roleOf[T RequestMessage](m *T) Role {
+	switch v := any(m).(type) {
+	case *Message:
+		if v != nil {
+			return v.Role
+		}
+	case *ShortHandMessage:
+		if v != nil {
+			return v.Role
+		}
+	}
+
+	return RoleUnknown
 }
 
 // marshalRequest is a type alias for Request to allow custom JSON marshaling.
@@ -80,21 +292,50 @@ func (r Request[T]) MarshalJSON() ([]byte, error) {
 	}
 
 	if r.System != nil && len(r.SystemMessages) > 0 {
-		return nil, fmt.Errorf("only one of System or SystemMessages should be provided")
+		return nil, ErrSystemConflict
 	}
 
 	var err error
 	if r.System != nil {
-		aux.SystemField, err = json.Marshal(r.System)
+		aux.SystemField, err = Marshal(r.System)
 	} else if len(r.SystemMessages) > 0 {
-		aux.SystemField, err = json.Marshal(r.SystemMessages)
+		aux.SystemField, err = Marshal(r.SystemMessages)
 	}
 
 	if err != nil {
 		return nil, err
 	}
 
-	return json.Marshal(aux)
+	return Marshal(aux)
+}
+
+// UnmarshalJSON implements a custom JSON unmarshaling for the Request type. The "system" field is decoded into System
+// when it is a plain string and into SystemMessages when it is an array of blocks.
+func (r *Request[T]) UnmarshalJSON(b []byte) error {
+	var aux = &struct {
+		*marshalRequest[T]
+		SystemField json.RawMessage `json:"system,omitempty"`
+	}{
+		marshalRequest: (*marshalRequest[T])(r),
+	}
+
+	if err := json.Unmarshal(b, aux); err != nil {
+		return err
+	}
+
+	r.System = nil
+	r.SystemMessages = nil
+
+	var sys = bytes.TrimSpace(aux.SystemField)
+	switch {
+	case len(sys) == 0 || bytes.Equal(sys, []byte("null")):
+		return nil
+	case sys[0] == '"':
+		r.System = new(string)
+		return json.Unmarshal(sys, r.System)
+	default:
+		return json.Unmarshal(sys, &r.SystemMessages)
+	}
 }
 
 // Optional returns a pointer to |v|. Used to easily assign literals to optional parameters.
@@ -103,6 +344,12 @@ func Optional[T any](v T) *T {
 }
 
 // Metadata is an object describing metadata about the request.
+//
+// UserID is the only field the API accepts; requests with any other metadata fields are rejected (the API doesn't
+// allow extra inputs), which is why there's no way to set them. Metadata isn't echoed back in responses either. To
+// correlate requests with your own traces, log the |request-id| the API assigns to each request (see
+// Response.RequestID), which Anthropic can look up, alongside your trace id; to send the trace id with the request
+// (e.g. for a gateway), use a header (see WithRequestIDHeader and Client.AddRequestHeaders in the root package).
 type Metadata struct {
 	// UserID is a UUID, hash value, or other external identifier for the user who is associated with the request.
 	// Anthropic may use this id to help detect abuse. Do not include any identifying information such as name, email
@@ -0,0 +1,380 @@
+package v3
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestRequestClone(t *testing.T) {
+	var req = &Request[Message]{
+		Model: Claude3Haiku20240307,
+		Messages: []*Message{
+			{Role: RoleUser, Content: make([]*MessageContent, 1, 4)},
+		},
+		System:    Optional("You are a test."),
+		MaxTokens: 100,
+		Tools: []*Tool{
+			{Name: "get_weather", InputSchema: &Schema{
+				Type:       SchemaTypeObject,
+				Properties: map[string]*Schema{"location": {Type: SchemaTypeString}},
+				Required:   []string{"location"},
+			}},
+		},
+		Metadata: &Metadata{UserID: "user"},
+	}
+	req.Messages[0].Content[0] = &MessageContent{Type: "text", Text: "Hello"}
+
+	var before, err = json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var clone = req.Clone()
+	clone.Messages[0].Content = append(clone.Messages[0].Content, &MessageContent{Type: "text", Text: "Appended"})
+	clone.Messages[0].Content[0].Text = "Changed"
+	clone.Messages = append(clone.Messages, &Message{Role: RoleAssistant})
+	*clone.System = "Changed"
+	clone.Tools[0].InputSchema.Properties["unit"] = &Schema{Type: SchemaTypeString}
+	clone.Tools[0].InputSchema.Required[0] = "unit"
+	clone.Metadata.UserID = "changed"
+
+	var after []byte
+	after, err = json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(before) != string(after) {
+		t.Errorf("modifying clone changed original:\nbefore: %s\nafter:  %s", before, after)
+	}
+	if got := req.Messages[0].Content[:2][1]; got != nil {
+		t.Errorf("append to clone wrote into original backing array: %+v", got)
+	}
+}
+
+func TestRequestMarshalSampling(t *testing.T) {
+	var b, err = json.Marshal(&Request[Message]{TopK: Optional(5), TopP: Optional(0.95)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fields map[string]json.RawMessage
+	if err = json.Unmarshal(b, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if string(fields["top_k"]) != "5" || string(fields["top_p"]) != "0.95" {
+		t.Errorf("marshaled request = %s, want top_k 5 and top_p 0.95", b)
+	}
+}
+
+func TestRequestMarshalSystem(t *testing.T) {
+	var tests = []struct {
+		name string
+		req  *Request[ShortHandMessage]
+		exp  string
+	}{
+		{
+			name: "No System",
+			req:  &Request[ShortHandMessage]{},
+			exp:  ``,
+		},
+		{
+			name: "String System",
+			req:  &Request[ShortHandMessage]{System: Optional("Be brief.")},
+			exp:  `"Be brief."`,
+		},
+		{
+			name: "Block System",
+			req: &Request[ShortHandMessage]{SystemMessages: []*SystemMessage{
+				{Type: "text", Text: "Be brief.", CacheControl: &CacheControl{Type: "ephemeral"}},
+			}},
+			exp: `[{"type":"text","text":"Be brief.","cache_control":{"type":"ephemeral"}}]`,
+		},
+		{
+			name: "Multiple Cached Sections",
+			req: &Request[ShortHandMessage]{SystemMessages: []*SystemMessage{
+				{Type: "text", Text: "Instructions.", CacheControl: &CacheControl{Type: "ephemeral"}},
+				{Type: "text", Text: "Reference."},
+				{Type: "text", Text: "Examples.", CacheControl: &CacheControl{Type: "ephemeral"}},
+			}},
+			exp: `[{"type":"text","text":"Instructions.","cache_control":{"type":"ephemeral"}},{"type":"text","text":"Reference."},{"type":"text","text":"Examples.","cache_control":{"type":"ephemeral"}}]`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b, err = json.Marshal(tt.req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var fields map[string]json.RawMessage
+			if err = json.Unmarshal(b, &fields); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(fields["system"]); got != tt.exp {
+				t.Errorf("system = %s, want %s", got, tt.exp)
+			}
+
+			var out = &Request[ShortHandMessage]{}
+			if err = json.Unmarshal(b, out); err != nil {
+				t.Fatal(err)
+			}
+
+			var rt []byte
+			rt, err = json.Marshal(out)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(rt) != string(b) {
+				t.Errorf("round trip = %s, want %s", rt, b)
+			}
+		})
+	}
+}
+
+func TestRequestValidate(t *testing.T) {
+	var valid = func() *Request[ShortHandMessage] {
+		return &Request[ShortHandMessage]{
+			Model:     Claude3Haiku20240307,
+			Messages:  []*ShortHandMessage{{Role: RoleUser, Content: "Hi"}},
+			MaxTokens: 2048,
+		}
+	}
+
+	var tests = []struct {
+		name   string
+		modify func(r *Request[ShortHandMessage])
+		err    error
+	}{
+		{name: "Valid", modify: func(r *Request[ShortHandMessage]) {}, err: nil},
+		{name: "Unknown Model", modify: func(r *Request[ShortHandMessage]) { r.Model = UnknownModel }, err: ErrUnknownModel},
+		{name: "No Messages", modify: func(r *Request[ShortHandMessage]) { r.Messages = nil }, err: ErrEmptyMessages},
+		{name: "No Max Tokens", modify: func(r *Request[ShortHandMessage]) { r.MaxTokens = 0 }, err: ErrInvalidMaxTokens},
+		{
+			name: "Both System Forms",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.System = Optional("a")
+				r.SystemMessages = []*SystemMessage{{Type: "text", Text: "b"}}
+			},
+			err: ErrSystemConflict,
+		},
+		{
+			name: "Image System Block",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.SystemMessages = []*SystemMessage{{Type: "image"}}
+			},
+			err: ErrInvalidSystemBlock,
+		},
+		{
+			name: "Thinking With Default Temperature",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.Temperature = Optional(1.0)
+			},
+			err: nil,
+		},
+		{
+			name: "Thinking With Zero Temperature",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.Temperature = Optional(0.0)
+			},
+			err: ErrThinkingTemperature,
+		},
+		{
+			name: "Thinking With TopK",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.TopK = Optional(5)
+			},
+			err: ErrThinkingSampling,
+		},
+		{
+			name: "Thinking With Low TopP",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.TopP = Optional(0.9)
+			},
+			err: ErrThinkingSampling,
+		},
+		{
+			name: "Thinking With High TopP",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.TopP = Optional(0.95)
+			},
+			err: nil,
+		},
+		{
+			name:   "Stop Sequences",
+			modify: func(r *Request[ShortHandMessage]) { r.StopSequences = []string{"END", "\n\nHuman:"} },
+			err:    nil,
+		},
+		{
+			name:   "Whitespace Stop Sequence",
+			modify: func(r *Request[ShortHandMessage]) { r.StopSequences = []string{"END", " \n"} },
+			err:    ErrInvalidStopSequence,
+		},
+		{
+			name:   "Too Many Stop Sequences",
+			modify: func(r *Request[ShortHandMessage]) { r.StopSequences = make([]string, MaxStopSequences+1) },
+			err:    ErrTooManyStopSequences,
+		},
+		{
+			name:   "Thinking Budget Too Small",
+			modify: func(r *Request[ShortHandMessage]) { r.Thinking = NewThinking(1000) },
+			err:    ErrThinkingBudget,
+		},
+		{
+			name:   "Thinking Budget Not Less Than Max Tokens",
+			modify: func(r *Request[ShortHandMessage]) { r.Thinking = NewThinking(2048) },
+			err:    ErrThinkingBudget,
+		},
+		{
+			name: "Thinking With Auto Tool Choice",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.ToolChoice = &ToolChoice{Type: "auto"}
+			},
+			err: nil,
+		},
+		{
+			name: "Thinking With Forced Tool Choice",
+			modify: func(r *Request[ShortHandMessage]) {
+				r.Thinking = NewThinking(1024)
+				r.ToolChoice = &ToolChoice{Type: "tool", Name: "get_weather"}
+			},
+			err: ErrThinkingToolChoice,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = valid()
+			tt.modify(r)
+			if err := r.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Request.Validate() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestValidateThinkingBlocks(t *testing.T) {
+	var thinking = &MessageContent{Type: "thinking", Thinking: "Let me think.", Signature: "sig"}
+	var text = &MessageContent{Type: "text", Text: "Hi"}
+
+	var tests = []struct {
+		name string
+		msgs []*Message
+		err  error
+	}{
+		{
+			name: "Valid",
+			msgs: []*Message{
+				{Role: RoleUser, Content: []*MessageContent{text}},
+				{Role: RoleAssistant, Content: []*MessageContent{thinking, {Type: "redacted_thinking", Data: "abc"}, text}},
+			},
+			err: nil,
+		},
+		{
+			name: "User Thinking Block",
+			msgs: []*Message{{Role: RoleUser, Content: []*MessageContent{thinking}}},
+			err:  ErrThinkingBlockRole,
+		},
+		{
+			name: "Thinking After Text",
+			msgs: []*Message{{Role: RoleAssistant, Content: []*MessageContent{text, thinking}}},
+			err:  ErrThinkingBlockOrder,
+		},
+		{
+			name: "Missing Signature",
+			msgs: []*Message{{Role: RoleAssistant, Content: []*MessageContent{{Type: "thinking", Thinking: "Hmm."}}}},
+			err:  ErrThinkingBlockSignature,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := validateThinkingBlocks(tt.msgs); !errors.Is(err, tt.err) {
+				t.Errorf("validateThinkingBlocks() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRequestPrefillAssistant(t *testing.T) {
+	var user = &Message{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: "List three colors as JSON."}}}
+	var assistant = &Message{Role: RoleAssistant, Content: []*MessageContent{{Type: "text", Text: "Sure."}}}
+
+	var tests = []struct {
+		name     string
+		messages []*Message
+		thinking *Thinking
+		prefix   string
+		expErr   error
+	}{
+		{name: "Valid", messages: []*Message{user}, prefix: "{"},
+		{name: "After assistant", messages: []*Message{user, assistant}, prefix: "{", expErr: ErrInvalidPrefill},
+		{name: "Empty messages", prefix: "{", expErr: ErrInvalidPrefill},
+		{name: "Trailing whitespace", messages: []*Message{user}, prefix: "{\n", expErr: ErrInvalidPrefill},
+		{name: "Thinking", messages: []*Message{user}, thinking: NewThinking(1024), prefix: "{", expErr: ErrInvalidPrefill},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = &Request[Message]{Messages: tt.messages, Thinking: tt.thinking}
+			var err = r.PrefillAssistant(tt.prefix)
+			if !errors.Is(err, tt.expErr) {
+				t.Fatalf("PrefillAssistant() error = %v, wantErr %v", err, tt.expErr)
+			}
+			if err != nil {
+				if len(r.Messages) != len(tt.messages) {
+					t.Errorf("PrefillAssistant() modified the messages on error")
+				}
+				return
+			}
+
+			var last = r.Messages[len(r.Messages)-1]
+			if last.Role != RoleAssistant || last.Content[0].Text != tt.prefix {
+				t.Errorf("last message = %+v, want an assistant prefill of %q", last, tt.prefix)
+			}
+		})
+	}
+
+	var sh = &Request[ShortHandMessage]{Messages: []*ShortHandMessage{{Role: RoleUser, Content: "Hi"}}}
+	if err := sh.PrefillAssistant("["); err != nil {
+		t.Fatal(err)
+	}
+	if last := sh.Messages[1]; last.Role != RoleAssistant || last.Content != "[" {
+		t.Errorf("last message = %+v, want an assistant prefill of %q", last, "[")
+	}
+}
+
+func TestRequestSerializedSize(t *testing.T) {
+	var r = &Request[ShortHandMessage]{
+		Model:     Claude3Haiku20240307,
+		Messages:  []*ShortHandMessage{{Role: RoleUser, Content: "<b>Hi</b>"}},
+		MaxTokens: 16,
+	}
+
+	var got, err = r.SerializedSize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b []byte
+	if b, err = Marshal(r); err != nil {
+		t.Fatal(err)
+	}
+	if got != len(b) {
+		t.Errorf("SerializedSize() = %d, want %d", got, len(b))
+	}
+
+	r.System = Optional("Be brief.")
+	r.SystemMessages = []*SystemMessage{{Type: "text", Text: "Be brief."}}
+	if _, err = r.SerializedSize(); !errors.Is(err, ErrSystemConflict) {
+		t.Errorf("SerializedSize() error = %v, want %v", err, ErrSystemConflict)
+	}
+}
@@ -1,22 +1,27 @@
 package v3
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
 // Response represents the response from the API.
 type Response struct {
 	// ID is the unique identifier of the message.
 	ID string `json:"id"`
 	// Content generated by the model. This is an array of content blocks, each of which has a type
-	// that determines its shape. Currently, the only type in responses is "text".
+	// that determines its shape (see ContentType). It may be empty, e.g. if the model refused immediately or was
+	// prefilled with a complete answer, so don't assume Content[0] exists.
 	Content []*MessageContent `json:"content"`
 	// Model is the model that performed the completion.
 	Model Model `json:"model"`
 	// Role is the conversational role of the generated message. This will always be "assistant".
 	Role Role `json:"role"`
-	// StopReason is the reason that Claude stopped. This may be one the following values:
-	//
-	// "end_turn": the model reached a natural stopping point.
-	// "max_tokens": we exceeded the requested max_tokens or the model's maximum.
-	// "stop_sequence": one of your provided custom stop_sequences was generated.
-	StopReason string `json:"stop_reason"`
+	// StopReason is the reason that Claude stopped. See the StopReason constants for the possible values.
+	StopReason StopReason `json:"stop_reason"`
 	// StopSequence represents which custom stop sequence was generated, if any.
 	// This value will be a non-null string if one of your custom stop sequences was generated.
 	StopSequence *string `json:"stop_sequence"`
@@ -24,12 +29,214 @@ type Response struct {
 	Type string `json:"type"`
 	// Usage represents the usage of the API.
 	Usage *Usage `json:"usage"`
+	// Container is the code execution container used by the request, if any. Its ID can be set as the Container of
+	// the next request to reuse it until it expires.
+	Container *Container `json:"container,omitempty"`
+	// RequestID is the id the API assigned to the request (its |request-id| response header), e.g. for correlating a
+	// response with Anthropic's logs. It isn't part of the response body; it's set by the client in the root package.
+	RequestID string `json:"-"`
+	// ClientRequestID is the id the client generated for the request, if it was configured to (see
+	// WithRequestIDHeader in the root package).
+	ClientRequestID string `json:"-"`
+}
+
+// Container is a code execution container.
+type Container struct {
+	// ID is the unique identifier of the container.
+	ID string `json:"id"`
+	// ExpiresAt is when the container expires. Requests which reference it afterwards are rejected.
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// ErrInconsistentResponse indicates that a Response isn't internally consistent. See Response.Validate.
+var ErrInconsistentResponse = errors.New("inconsistent response")
+
+// Validate ensures that |r| is internally consistent, e.g. as a safety net for a response reconstructed from a stream.
+// It returns an error wrapping ErrInconsistentResponse if a content block is missing (i.e. the blocks' indices have a
+// gap), if a tool_use (or server_tool_use) block lacks an id or name or has input which isn't valid JSON, or if the
+// stop reason is missing (StopReasonUnknown), isn't one this package knows, or contradicts the
+// content: StopReasonToolUse without a tool_use block, or StopReasonStopSequence without a stop sequence.
+func (r *Response) Validate() error {
+	var toolUses int
+	for i, c := range r.Content {
+		if c == nil {
+			return fmt.Errorf("%w: content block %d is missing", ErrInconsistentResponse, i)
+		}
+		if c.Type != ContentTypeToolUse.String() && c.Type != ContentTypeServerToolUse.String() {
+			continue
+		}
+		if c.Type == ContentTypeToolUse.String() {
+			toolUses++
+		}
+		if c.ID == "" || c.Name == "" {
+			return fmt.Errorf("%w: %s block %d is missing its id or name", ErrInconsistentResponse, c.Type, i)
+		}
+		if len(c.Input) > 0 && !json.Valid(c.Input) {
+			return fmt.Errorf("%w: %s block %d has invalid input %q", ErrInconsistentResponse, c.Type, i, c.Input)
+		}
+	}
+
+	switch {
+	case r.StopReason == StopReasonUnknown:
+		return fmt.Errorf("%w: missing stop reason", ErrInconsistentResponse)
+	case !r.StopReason.known():
+		return fmt.Errorf("%w: unknown stop reason %q", ErrInconsistentResponse, r.StopReason)
+	case r.StopReason == StopReasonToolUse && toolUses == 0:
+		return fmt.Errorf("%w: stop reason is tool_use, but there's no tool_use block", ErrInconsistentResponse)
+	case r.StopReason == StopReasonStopSequence && r.StopSequence == nil:
+		return fmt.Errorf("%w: stop reason is stop_sequence, but there's no stop sequence", ErrInconsistentResponse)
+	}
+
+	return nil
+}
+
+// RefusalReason returns the text accompanying a refusal, if any. It returns an empty string if Claude didn't refuse
+// (i.e. StopReason is not StopReasonRefusal) or if the refusal wasn't accompanied by any text.
+func (r *Response) RefusalReason() string {
+	if r.StopReason != StopReasonRefusal {
+		return ""
+	}
+
+	var parts []string
+	for _, c := range r.Content {
+		if c.Type == "text" && c.Text != "" {
+			parts = append(parts, c.Text)
+		}
+	}
+
+	return strings.TrimSpace(strings.Join(parts, ""))
+}
+
+// StoppedBySequence returns the custom stop sequence which ended generation, and true, if |r| stopped because one was
+// generated. Otherwise, it returns an empty string and false.
+func (r *Response) StoppedBySequence() (string, bool) {
+	if r.StopReason != StopReasonStopSequence || r.StopSequence == nil {
+		return "", false
+	}
+
+	return *r.StopSequence, true
+}
+
+// ContentByType returns the content blocks of |r| of type |t|, in order.
+func (r *Response) ContentByType(t ContentType) []*MessageContent {
+	var out []*MessageContent
+	for _, c := range r.Content {
+		if c != nil && c.Type == t.String() {
+			out = append(out, c)
+		}
+	}
+
+	return out
+}
+
+// Text returns the text of |r|'s text blocks, concatenated.
+func (r *Response) Text() string {
+	var sb strings.Builder
+	for _, c := range r.ContentByType(ContentTypeText) {
+		sb.WriteString(c.Text)
+	}
+
+	return sb.String()
+}
+
+// ToolUses returns |r|'s "tool_use" blocks, i.e. the client tools the model wants to use.
+func (r *Response) ToolUses() []*MessageContent {
+	return r.ContentByType(ContentTypeToolUse)
 }
 
 // Usage represents the usage of the API.
 type Usage struct {
-	// InputTokens is the number of tokens used as input to the model.
+	// InputTokens is the number of tokens used as input to the model. When prompt caching is in use, this excludes
+	// tokens that were written to or read from the cache.
 	InputTokens int `json:"input_tokens"`
 	// OutputTokens is the number of tokens generated by the model.
 	OutputTokens int `json:"output_tokens"`
+	// CacheCreationInputTokens is the number of input tokens written to the prompt cache.
+	CacheCreationInputTokens int `json:"cache_creation_input_tokens,omitempty"`
+	// CacheReadInputTokens is the number of input tokens read from the prompt cache.
+	CacheReadInputTokens int `json:"cache_read_input_tokens,omitempty"`
+	// ServiceTier is the service tier the request was processed with, e.g. "standard", "priority", or "batch".
+	ServiceTier string `json:"service_tier,omitempty"`
+}
+
+const (
+	// cacheWriteMultiplier is the price of a cache write relative to a regular input token.
+	cacheWriteMultiplier = 1.25
+	// cacheReadMultiplier is the price of a cache read relative to a regular input token.
+	cacheReadMultiplier = 0.1
+)
+
+// Add adds the token counts of |other| to |u|, e.g. to total the usage of the requests of a multi-turn conversation.
+// Each count is summed, including cache reads: every turn re-reads the cached prefix, and each read is billed.
+func (u *Usage) Add(other *Usage) {
+	if other == nil {
+		return
+	}
+
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.CacheCreationInputTokens += other.CacheCreationInputTokens
+	u.CacheReadInputTokens += other.CacheReadInputTokens
+}
+
+// TotalTokens returns InputTokens + OutputTokens. It does not include CacheCreationInputTokens or
+// CacheReadInputTokens; use BilledInputTokens to account for prompt caching.
+func (u *Usage) TotalTokens() int {
+	return u.InputTokens + u.OutputTokens
+}
+
+// BilledInputTokens returns the number of input tokens |u| is billed as, expressed in regular input tokens. Cache
+// writes are billed at 1.25x and cache reads at 0.1x the price of a regular input token, so a request that wrote
+// 1000 tokens to the cache and read none is billed as InputTokens + 1250. Output tokens are not included.
+func (u *Usage) BilledInputTokens() float64 {
+	return float64(u.InputTokens) +
+		float64(u.CacheCreationInputTokens)*cacheWriteMultiplier +
+		float64(u.CacheReadInputTokens)*cacheReadMultiplier
+}
+
+// CacheStatus reports how the request used the prompt cache. |written| is true if CacheCreationInputTokens > 0, i.e.
+// the prompt up to a cache breakpoint wasn't cached yet and was written to the cache (a cold request). |read| is true
+// if CacheReadInputTokens > 0, i.e. a cached prefix was reused (a warm request). Both can be true when a request reads
+// an earlier breakpoint and writes a later one. Neither is true if the request had no cache breakpoints, or if the
+// prefix before the breakpoint was shorter than the model's minimum cacheable length; if a repeated request keeps
+// reporting writes, its breakpoints likely cover content that changes between requests.
+func (u *Usage) CacheStatus() (read, written bool) {
+	return u.CacheReadInputTokens > 0, u.CacheCreationInputTokens > 0
+}
+
+// OpenAIUsage is usage in the format of OpenAI's chat completions API, for systems (e.g. cost dashboards) which expect
+// its field names. See Usage.OpenAI.
+type OpenAIUsage struct {
+	// PromptTokens is the number of input tokens, including those written to or read from the prompt cache.
+	PromptTokens int `json:"prompt_tokens"`
+	// CompletionTokens is the number of output tokens.
+	CompletionTokens int `json:"completion_tokens"`
+	// TotalTokens is PromptTokens + CompletionTokens.
+	TotalTokens int `json:"total_tokens"`
+	// PromptTokensDetails breaks down PromptTokens.
+	PromptTokensDetails *OpenAIPromptTokensDetails `json:"prompt_tokens_details,omitempty"`
+}
+
+// OpenAIPromptTokensDetails breaks down the PromptTokens of an OpenAIUsage.
+type OpenAIPromptTokensDetails struct {
+	// CachedTokens is the number of input tokens read from the prompt cache.
+	CachedTokens int `json:"cached_tokens"`
+}
+
+// OpenAI returns |u| in the format of OpenAI's chat completions API. Unlike InputTokens, OpenAI's prompt_tokens
+// includes cached tokens, so PromptTokens is the sum of InputTokens, CacheCreationInputTokens, and
+// CacheReadInputTokens; cache reads are also reported as PromptTokensDetails.CachedTokens. Note that OpenAI has no
+// equivalent of cache writes, which are billed at a premium (see BilledInputTokens).
+func (u *Usage) OpenAI() OpenAIUsage {
+	var prompt = u.InputTokens + u.CacheCreationInputTokens + u.CacheReadInputTokens
+	var out = OpenAIUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: u.OutputTokens,
+		TotalTokens:      prompt + u.OutputTokens,
+	}
+	if u.CacheReadInputTokens > 0 {
+		out.PromptTokensDetails = &OpenAIPromptTokensDetails{CachedTokens: u.CacheReadInputTokens}
+	}
+
+	return out
 }
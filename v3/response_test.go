@@ -0,0 +1,234 @@
+package v3
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestUsageTokens(t *testing.T) {
+	var u = &Usage{}
+	if err := json.Unmarshal([]byte(`{"input_tokens":10,"output_tokens":20,"cache_creation_input_tokens":1000,"cache_read_input_tokens":200}`), u); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := u.TotalTokens(); got != 30 {
+		t.Errorf("TotalTokens() = %d, want 30", got)
+	}
+	if got := u.BilledInputTokens(); got != 1280 {
+		t.Errorf("BilledInputTokens() = %v, want 1280", got)
+	}
+}
+
+func TestUsageCacheStatus(t *testing.T) {
+	var tests = []struct {
+		name    string
+		usage   *Usage
+		read    bool
+		written bool
+	}{
+		{name: "Uncached", usage: &Usage{InputTokens: 10}},
+		{name: "Cold", usage: &Usage{CacheCreationInputTokens: 2048}, written: true},
+		{name: "Warm", usage: &Usage{CacheReadInputTokens: 2048}, read: true},
+		{name: "Both", usage: &Usage{CacheReadInputTokens: 2048, CacheCreationInputTokens: 1024}, read: true, written: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var read, written = tt.usage.CacheStatus()
+			if read != tt.read || written != tt.written {
+				t.Errorf("CacheStatus() = (%v, %v), want (%v, %v)", read, written, tt.read, tt.written)
+			}
+		})
+	}
+}
+
+func TestUsageOpenAI(t *testing.T) {
+	var tests = []struct {
+		name  string
+		usage *Usage
+		want  string
+	}{
+		{
+			name:  "Uncached",
+			usage: &Usage{InputTokens: 10, OutputTokens: 20},
+			want:  `{"prompt_tokens":10,"completion_tokens":20,"total_tokens":30}`,
+		},
+		{
+			name:  "Cached",
+			usage: &Usage{InputTokens: 10, OutputTokens: 20, CacheCreationInputTokens: 1000, CacheReadInputTokens: 200},
+			want:  `{"prompt_tokens":1210,"completion_tokens":20,"total_tokens":1230,"prompt_tokens_details":{"cached_tokens":200}}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var b, err = json.Marshal(tt.usage.OpenAI())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(b) != tt.want {
+				t.Errorf("OpenAI() = %s, want %s", b, tt.want)
+			}
+		})
+	}
+}
+
+func TestResponseValidate(t *testing.T) {
+	var valid = func() *Response {
+		return &Response{
+			Content: []*MessageContent{
+				{Type: "text", Text: "Let me check."},
+				{Type: "tool_use", ID: "toolu_1", Name: "get_weather", Input: json.RawMessage(`{"location":"Paris"}`)},
+			},
+			StopReason: StopReasonToolUse,
+		}
+	}
+
+	var tests = []struct {
+		name   string
+		modify func(r *Response)
+		err    error
+	}{
+		{name: "Valid", modify: func(r *Response) {}},
+		{name: "Missing Block", modify: func(r *Response) { r.Content[0] = nil }, err: ErrInconsistentResponse},
+		{name: "Invalid Input", modify: func(r *Response) { r.Content[1].Input = json.RawMessage(`{"location":`) }, err: ErrInconsistentResponse},
+		{name: "Missing Tool Name", modify: func(r *Response) { r.Content[1].Name = "" }, err: ErrInconsistentResponse},
+		{name: "Missing Stop Reason", modify: func(r *Response) { r.StopReason = StopReasonUnknown }, err: ErrInconsistentResponse},
+		{name: "Unrecognized Stop Reason", modify: func(r *Response) { r.StopReason = "something_new" }, err: ErrInconsistentResponse},
+		{name: "Tool Use Without Block", modify: func(r *Response) { r.Content = r.Content[:1] }, err: ErrInconsistentResponse},
+		{name: "Stop Sequence Without Sequence", modify: func(r *Response) { r.StopReason = StopReasonStopSequence }, err: ErrInconsistentResponse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r = valid()
+			tt.modify(r)
+			if err := r.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Response.Validate() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestResponseContentByType(t *testing.T) {
+	var r = &Response{Content: []*MessageContent{
+		{Type: "thinking", Thinking: "Hmm."},
+		{Type: "text", Text: "Let me "},
+		{Type: "text", Text: "check."},
+		{Type: "tool_use", ID: "toolu_1", Name: "get_weather"},
+	}}
+
+	if got := r.Text(); got != "Let me check." {
+		t.Errorf("Text() = %q, want %q", got, "Let me check.")
+	}
+	if got := r.ToolUses(); len(got) != 1 || got[0].ID != "toolu_1" {
+		t.Errorf("ToolUses() = %v, want [toolu_1]", got)
+	}
+	if got := r.ContentByType(ContentTypeThinking); len(got) != 1 || got[0].Thinking != "Hmm." {
+		t.Errorf("ContentByType(thinking) = %v", got)
+	}
+	if got := r.ContentByType(ContentTypeImage); len(got) != 0 {
+		t.Errorf("ContentByType(image) = %v, want none", got)
+	}
+}
+
+func TestResponseStopReason(t *testing.T) {
+	var tests = []struct {
+		name string
+		in   string
+		exp  StopReason
+	}{
+		{name: "Known", in: `{"stop_reason":"end_turn"}`, exp: StopReasonEndTurn},
+		{name: "Refusal", in: `{"stop_reason":"refusal"}`, exp: StopReasonRefusal},
+		{name: "Null", in: `{"stop_reason":null}`, exp: StopReasonUnknown},
+		{name: "Unrecognized", in: `{"stop_reason":"something_new"}`, exp: "something_new"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp = &Response{}
+			if err := json.Unmarshal([]byte(tt.in), resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.StopReason != tt.exp {
+				t.Errorf("StopReason = %q, want %q", resp.StopReason, tt.exp)
+			}
+		})
+	}
+
+	// StopReason used to be a plain string, and comparing it with string literals must keep working.
+	if resp := (&Response{StopReason: StopReasonToolUse}); resp.StopReason != "tool_use" {
+		t.Errorf("StopReason = %q, want %q", resp.StopReason, "tool_use")
+	}
+}
+
+func TestResponseRefusalReason(t *testing.T) {
+	var text = func(s string) *MessageContent { return &MessageContent{Type: "text", Text: s} }
+
+	var tests = []struct {
+		name string
+		resp *Response
+		exp  string
+	}{
+		{name: "Refusal", resp: &Response{StopReason: StopReasonRefusal, Content: []*MessageContent{text("I can't help "), text("with that. ")}}, exp: "I can't help with that."},
+		{name: "Refusal Without Text", resp: &Response{StopReason: StopReasonRefusal, Content: []*MessageContent{{Type: "tool_use", ID: "toolu_1", Name: "search"}}}},
+		{name: "Not A Refusal", resp: &Response{StopReason: StopReasonEndTurn, Content: []*MessageContent{text("Sure.")}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.resp.RefusalReason(); got != tt.exp {
+				t.Errorf("RefusalReason() = %q, want %q", got, tt.exp)
+			}
+		})
+	}
+}
+
+func TestResponseStoppedBySequence(t *testing.T) {
+	var tests = []struct {
+		name string
+		resp *Response
+		seq  string
+		ok   bool
+	}{
+		{name: "Sequence", resp: &Response{StopReason: StopReasonStopSequence, StopSequence: Optional("</answer>")}, seq: "</answer>", ok: true},
+		{name: "EndTurn", resp: &Response{StopReason: StopReasonEndTurn}},
+		{name: "Missing sequence", resp: &Response{StopReason: StopReasonStopSequence}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seq, ok = tt.resp.StoppedBySequence()
+			if seq != tt.seq || ok != tt.ok {
+				t.Errorf("StoppedBySequence() = (%q, %v), want (%q, %v)", seq, ok, tt.seq, tt.ok)
+			}
+		})
+	}
+}
+
+func TestResponseContainer(t *testing.T) {
+	var resp = &Response{}
+	var in = `{"id":"msg_1","type":"message","role":"assistant","content":[],"container":{"id":"container_1","expires_at":"2025-05-23T21:13:31.749448Z"}}`
+	if err := json.Unmarshal([]byte(in), resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Container == nil || resp.Container.ID != "container_1" || resp.Container.ExpiresAt.IsZero() {
+		t.Fatalf("Container = %+v, want container_1 with its expiry", resp.Container)
+	}
+
+	var req = &Request[Message]{
+		Model:     Claude3Dot7Sonnet20250219,
+		Messages:  []*Message{{Role: RoleUser, Content: []*MessageContent{{Type: "text", Text: "Continue."}}}},
+		MaxTokens: 1024,
+		Container: resp.Container.ID,
+	}
+	var b, err = json.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(b), `"container":"container_1"`) {
+		t.Errorf("request = %s, want the container id", b)
+	}
+}
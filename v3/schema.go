@@ -0,0 +1,137 @@
+package v3
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// ErrUnsupportedSchemaType indicates that a Go type has no JSON schema equivalent (e.g. a channel or a function).
+var ErrUnsupportedSchemaType = errors.New("unsupported schema type")
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+)
+
+// SchemaFor returns the JSON schema of |v|'s type, which is typically a struct used to decode a tool's input. Struct
+// fields are named by their |json| tags (fields tagged "-" and unexported fields are skipped, and embedded structs
+// are flattened). A field is required unless it is a pointer or its tag has the "omitempty" option. A field's
+// |description| tag sets its Description, and its |enum| tag sets its Enum to the comma-separated values.
+func SchemaFor(v any) (*Schema, error) {
+	var t = reflect.TypeOf(v)
+	if t == nil {
+		return nil, fmt.Errorf("%w: nil", ErrUnsupportedSchemaType)
+	}
+
+	return schemaForType(t, make(map[reflect.Type]bool))
+}
+
+// schemaForType returns the schema for |t|. |visiting| holds the struct types being expanded, to reject recursive
+// types, which can't be expressed without "$ref".
+func schemaForType(t reflect.Type, visiting map[reflect.Type]bool) (*Schema, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeType:
+		return &Schema{Type: SchemaTypeString}, nil
+	case t == rawMessageType:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSchemaType, t)
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &Schema{Type: SchemaTypeString}, nil
+	case reflect.Bool:
+		return &Schema{Type: SchemaTypeBoolean}, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: SchemaTypeInteger}, nil
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: SchemaTypeNumber}, nil
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// encoding/json encodes []byte as a base64 string.
+			return &Schema{Type: SchemaTypeString}, nil
+		}
+		var items, err = schemaForType(t.Elem(), visiting)
+		if err != nil {
+			return nil, err
+		}
+		return &Schema{Type: SchemaTypeArray, Items: items}, nil
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String {
+			return nil, fmt.Errorf("%w: %s: map keys must be strings", ErrUnsupportedSchemaType, t)
+		}
+		return &Schema{Type: SchemaTypeObject, Properties: map[string]*Schema{}}, nil
+	case reflect.Struct:
+		if visiting[t] {
+			return nil, fmt.Errorf("%w: %s is recursive", ErrUnsupportedSchemaType, t)
+		}
+		visiting[t] = true
+		defer delete(visiting, t)
+
+		var s = &Schema{Type: SchemaTypeObject, Properties: make(map[string]*Schema)}
+		if err := addFields(s, t, visiting); err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSchemaType, t)
+	}
+}
+
+// addFields adds the fields of the struct type |t| to the object schema |s|.
+func addFields(s *Schema, t reflect.Type, visiting map[reflect.Type]bool) error {
+	for i := 0; i < t.NumField(); i++ {
+		var f = t.Field(i)
+
+		var tag = f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		var name, opts, _ = strings.Cut(tag, ",")
+
+		if f.Anonymous && name == "" {
+			var ft = f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if err := addFields(s, ft, visiting); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		var prop, err = schemaForType(f.Type, visiting)
+		if err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		prop.Description = f.Tag.Get("description")
+		if enum := f.Tag.Get("enum"); enum != "" {
+			for _, e := range strings.Split(enum, ",") {
+				prop.Enum = append(prop.Enum, e)
+			}
+		}
+
+		s.Properties[name] = prop
+		if f.Type.Kind() != reflect.Pointer && !strings.Contains(opts, "omitempty") {
+			s.Required = append(s.Required, name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package v3
+
+// StopReason represents the reason that Claude stopped generating. It's a string type, so it can be compared with (and
+// switched on) string literals such as "end_turn", as it could when Response.StopReason was a plain string. Code which
+// assigns it to (or passes it as) a string must convert it with string().
+type StopReason string
+
+const (
+	// StopReasonUnknown represents a missing (or not yet received) stop reason.
+	StopReasonUnknown StopReason = ""
+	// StopReasonEndTurn indicates that the model reached a natural stopping point.
+	StopReasonEndTurn StopReason = "end_turn"
+	// StopReasonMaxTokens indicates that the requested max_tokens or the model's maximum was exceeded.
+	StopReasonMaxTokens StopReason = "max_tokens"
+	// StopReasonStopSequence indicates that one of the provided custom stop_sequences was generated.
+	StopReasonStopSequence StopReason = "stop_sequence"
+	// StopReasonToolUse indicates that the model invoked one or more tools.
+	StopReasonToolUse StopReason = "tool_use"
+	// StopReasonPauseTurn indicates that a long-running turn was paused. The response can be sent back as-is in a
+	// subsequent request to let the model continue.
+	StopReasonPauseTurn StopReason = "pause_turn"
+	// StopReasonRefusal indicates that the model declined to generate a response for safety reasons.
+	StopReasonRefusal StopReason = "refusal"
+	// StopReasonOutputBudget indicates that the client stopped a stream because it exceeded its output token budget
+	// (see WithMaxOutputTokens in the root package). It is never returned by the API.
+	StopReasonOutputBudget StopReason = "output_budget"
+)
+
+// String implements the fmt.Stringer interface.
+func (s StopReason) String() string {
+	return string(s)
+}
+
+// known reports whether |s| is one of the StopReason constants, other than StopReasonUnknown.
+func (s StopReason) known() bool {
+	switch s {
+	case StopReasonEndTurn, StopReasonMaxTokens, StopReasonStopSequence, StopReasonToolUse, StopReasonPauseTurn,
+		StopReasonRefusal, StopReasonOutputBudget:
+		return true
+	}
+
+	return false
+}
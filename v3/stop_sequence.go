@@ -0,0 +1,50 @@
+package v3
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// MaxStopSequences is the maximum number of stop sequences ValidateStopSequences allows in a request. The API's own
+// limit isn't documented; this is a generous bound which catches runaway lists before they're sent.
+const MaxStopSequences = 64
+
+var (
+	// ErrInvalidStopSequence indicates that a stop sequence is empty or only whitespace, which the API rejects.
+	ErrInvalidStopSequence = errors.New("stop sequences must contain non-whitespace characters")
+	// ErrTooManyStopSequences indicates that a request has more than MaxStopSequences stop sequences.
+	ErrTooManyStopSequences = errors.New("too many stop sequences")
+)
+
+// reservedStopSequences are the turn markers of the legacy Text Completions prompt format. The Messages API doesn't
+// use them, so Claude is unlikely to generate them, and stopping on them usually means a prompt ported from that
+// format.
+var reservedStopSequences = []string{"\n\nHuman:", "\n\nAssistant:"}
+
+// ValidateStopSequences returns an error wrapping ErrInvalidStopSequence if any of |seqs| is empty or only whitespace,
+// or ErrTooManyStopSequences if there are more than MaxStopSequences of them.
+func ValidateStopSequences(seqs []string) error {
+	if len(seqs) > MaxStopSequences {
+		return fmt.Errorf("%w: %d > %d", ErrTooManyStopSequences, len(seqs), MaxStopSequences)
+	}
+	for i, s := range seqs {
+		if strings.TrimSpace(s) == "" {
+			return fmt.Errorf("%w (stop sequence %d is %q)", ErrInvalidStopSequence, i, s)
+		}
+	}
+
+	return nil
+}
+
+// warnReservedStopSequences logs a warning for each of |seqs| which is one of the legacy turn markers.
+func warnReservedStopSequences(seqs []string) {
+	for _, s := range seqs {
+		for _, r := range reservedStopSequences {
+			if s == r {
+				slog.Warn("stop sequence is a legacy text completions turn marker", "stop_sequence", s)
+			}
+		}
+	}
+}
@@ -0,0 +1,115 @@
+package v3
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Thinking configures extended thinking. When enabled, the response will contain "thinking" content blocks showing
+// Claude's reasoning before its final answer.
+// https://docs.anthropic.com/en/docs/build-with-claude/extended-thinking
+type Thinking struct {
+	// Type is the type of thinking configuration. Currently only "enabled" is supported.
+	Type string `json:"type"`
+	// BudgetTokens is the maximum number of tokens Claude may use for its internal reasoning. Required when Type is
+	// "enabled".
+	BudgetTokens int `json:"budget_tokens,omitempty"`
+}
+
+const (
+	// thinkingEnabled is the Type used to enable extended thinking.
+	thinkingEnabled = "enabled"
+	// MinThinkingBudgetTokens is the smallest BudgetTokens the API accepts.
+	MinThinkingBudgetTokens = 1024
+)
+
+var (
+	// ErrThinkingTemperature indicates that a temperature other than 1 was set on a request with thinking enabled.
+	ErrThinkingTemperature = errors.New("temperature must be unset or 1 when thinking is enabled")
+	// ErrThinkingSampling indicates that top_k was set, or top_p was set below 0.95, on a request with thinking enabled.
+	ErrThinkingSampling = errors.New("top_k must be unset and top_p must be unset or at least 0.95 when thinking is enabled")
+	// ErrThinkingBudget indicates that the thinking budget of a request is less than MinThinkingBudgetTokens, or isn't
+	// less than its max_tokens (which includes the thinking).
+	ErrThinkingBudget = errors.New("invalid thinking budget")
+	// ErrThinkingToolChoice indicates that a request with thinking enabled forces tool use (a tool_choice of "any" or
+	// "tool"), which the API doesn't support: only "auto" and "none" are.
+	ErrThinkingToolChoice = errors.New("tool_choice cannot force tool use when thinking is enabled")
+	// ErrThinkingBlockRole indicates that a thinking block was found in a message that isn't from the assistant. Thinking
+	// blocks are generated by Claude and can only appear in assistant turns.
+	ErrThinkingBlockRole = errors.New("thinking blocks can only appear in assistant messages")
+	// ErrThinkingBlockOrder indicates that a thinking block was found after other content in an assistant message. Claude
+	// always reasons before responding, and thinking blocks must be sent back in the order they were received.
+	ErrThinkingBlockOrder = errors.New("thinking blocks must precede all other content in an assistant message")
+	// ErrThinkingBlockSignature indicates that a thinking block is missing its signature, or that a redacted thinking
+	// block is missing its data. Thinking blocks must be sent back exactly as they were received; the API rejects
+	// blocks whose signature doesn't match their content.
+	ErrThinkingBlockSignature = errors.New("thinking blocks must be sent back unmodified, including their signature")
+)
+
+// NewThinking returns a Thinking configuration which enables extended thinking with the given token budget.
+func NewThinking(budgetTokens int) *Thinking {
+	return &Thinking{Type: thinkingEnabled, BudgetTokens: budgetTokens}
+}
+
+// enabled returns true if |t| enables extended thinking.
+func (t *Thinking) enabled() bool {
+	return t != nil && t.Type == thinkingEnabled
+}
+
+// validateThinking ensures that the thinking budget and the sampling parameters and tool choice of |r| are compatible
+// with its thinking configuration. Models reject any temperature other than 1, as well as top_k (and top_p values below
+// 0.95) and forced tool use, when thinking is enabled.
+func (r *Request[T]) validateThinking() error {
+	if !r.Thinking.enabled() {
+		return nil
+	}
+	if r.Thinking.BudgetTokens < MinThinkingBudgetTokens {
+		return fmt.Errorf("%w: thinking budget %d must be at least %d", ErrThinkingBudget, r.Thinking.BudgetTokens, MinThinkingBudgetTokens)
+	}
+	if r.Thinking.BudgetTokens >= r.MaxTokens {
+		return fmt.Errorf("%w: thinking budget %d must be less than max_tokens %d", ErrThinkingBudget, r.Thinking.BudgetTokens, r.MaxTokens)
+	}
+	if r.ToolChoice != nil && (r.ToolChoice.Type == "any" || r.ToolChoice.Type == "tool") {
+		return fmt.Errorf("%w (got %q)", ErrThinkingToolChoice, r.ToolChoice.Type)
+	}
+	if r.Temperature != nil && *r.Temperature != 1 {
+		return fmt.Errorf("%w (got %v)", ErrThinkingTemperature, *r.Temperature)
+	}
+	if r.TopK != nil || (r.TopP != nil && *r.TopP < 0.95) {
+		return ErrThinkingSampling
+	}
+
+	return nil
+}
+
+// isThinkingBlock returns true if |c| is a "thinking" or "redacted_thinking" block.
+func isThinkingBlock(c *MessageContent) bool {
+	return c.Type == "thinking" || c.Type == "redacted_thinking"
+}
+
+// validateThinkingBlocks ensures that any thinking blocks in |msgs| are being sent back correctly: only in assistant
+// messages, before any other content, and with their signature (or redacted data) intact. Note: a modified signature
+// cannot be detected client side, only a missing one.
+func validateThinkingBlocks(msgs []*Message) error {
+	for i, m := range msgs {
+		var seenOther bool
+		for _, c := range m.Content {
+			if !isThinkingBlock(c) {
+				seenOther = true
+				continue
+			}
+
+			if m.Role != RoleAssistant {
+				return fmt.Errorf("%w (message %d has role %s)", ErrThinkingBlockRole, i, m.Role)
+			}
+			if seenOther {
+				return fmt.Errorf("%w (message %d)", ErrThinkingBlockOrder, i)
+			}
+			if (c.Type == "thinking" && c.Signature == "") || (c.Type == "redacted_thinking" && c.Data == "") {
+				return fmt.Errorf("%w (message %d)", ErrThinkingBlockSignature, i)
+			}
+		}
+	}
+
+	return nil
+}
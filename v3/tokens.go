@@ -0,0 +1,54 @@
+package v3
+
+// charsPerToken is the rough number of characters per token for English text with Anthropic's tokenizer.
+const charsPerToken = 4
+
+// EstimateTokens returns a rough estimate of the number of tokens in |s|. It is a heuristic (roughly four characters
+// per token) and should not be relied upon for anything that needs an exact count.
+func EstimateTokens(s string) int {
+	if s == "" {
+		return 0
+	}
+
+	return (len([]rune(s)) + charsPerToken - 1) / charsPerToken
+}
+
+// TokenCounter counts the input tokens of requests. Implementations trade accuracy for speed: HeuristicTokenCounter
+// is instant but rough, while the count_tokens endpoint (see Client.TokenCounter in the root package) or a local
+// tokenizer are exact. Helpers which need token counts (e.g. to split input into requests) use a TokenCounter so
+// callers can choose.
+type TokenCounter interface {
+	// Count returns the number of input tokens |r| would use.
+	Count(r *Request[Message]) (int, error)
+}
+
+// HeuristicTokenCounter is a TokenCounter which estimates counts with EstimateTokens. It never returns an error.
+type HeuristicTokenCounter struct{}
+
+// Count implements TokenCounter. The text of the system prompt and of text blocks is estimated with EstimateTokens,
+// images count as MaxImageTokens, and tool definitions and other blocks are estimated from their JSON.
+func (HeuristicTokenCounter) Count(r *Request[Message]) (int, error) {
+	var tokens int
+	for _, t := range r.Tools {
+		if b, err := Marshal(t); err == nil {
+			tokens += EstimateTokens(string(b))
+		}
+	}
+	if r.System != nil {
+		tokens += EstimateTokens(*r.System)
+	}
+	for _, s := range r.SystemMessages {
+		tokens += EstimateTokens(s.Text)
+	}
+	for _, m := range r.Messages {
+		for _, c := range m.Content {
+			if c != nil && c.Type == "text" {
+				tokens += EstimateTokens(c.Text)
+				continue
+			}
+			tokens += estimateBlockTokens(c)
+		}
+	}
+
+	return tokens, nil
+}
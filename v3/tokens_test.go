@@ -0,0 +1,23 @@
+package v3
+
+import "testing"
+
+func TestHeuristicTokenCounter(t *testing.T) {
+	var r = &Request[Message]{
+		System: Optional("12345678"),
+		Messages: []*Message{
+			{Role: RoleUser, Content: []*MessageContent{
+				{Type: "text", Text: "1234"},
+				{Type: "image", Source: &MediaSource{Type: "base64", MediaType: "image/png", Data: "AAAA"}},
+			}},
+		},
+	}
+
+	var got, err = HeuristicTokenCounter{}.Count(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := 2 + 1 + MaxImageTokens; got != want {
+		t.Errorf("Count() = %d, want %d", got, want)
+	}
+}
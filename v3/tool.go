@@ -1,10 +1,82 @@
 package v3
 
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
 // Tool represents a tool that the model may use.
 type Tool struct {
 	Name        string  `json:"name"`
 	Description string  `json:"description"`
 	InputSchema *Schema `json:"input_schema"`
+	// RawInputSchema is a pre-built JSON Schema document sent verbatim as the tool's input schema. It is an escape
+	// hatch for schemas using constructs Schema doesn't model (e.g. "$defs", "allOf", or conditional schemas). At most
+	// one of InputSchema or RawInputSchema should be provided.
+	RawInputSchema json.RawMessage `json:"-"`
+}
+
+// marshalTool is a type alias for Tool to allow custom JSON marshaling.
+type marshalTool Tool
+
+// MarshalJSON implements a custom JSON marshaling for the Tool type, which sends RawInputSchema as the input schema
+// when it is provided.
+func (t Tool) MarshalJSON() ([]byte, error) {
+	if t.RawInputSchema == nil {
+		return Marshal(marshalTool(t))
+	}
+	if t.InputSchema != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInputSchemaConflict, t.Name)
+	}
+
+	return Marshal(&struct {
+		marshalTool
+		InputSchema json.RawMessage `json:"input_schema"`
+	}{
+		marshalTool: marshalTool(t),
+		InputSchema: t.RawInputSchema,
+	})
+}
+
+var (
+	// ErrMissingToolName indicates that a Tool does not have a name.
+	ErrMissingToolName = errors.New("tool name cannot be empty")
+	// ErrMissingInputSchema indicates that a Tool does not have an input schema.
+	ErrMissingInputSchema = errors.New("tool input schema cannot be nil")
+	// ErrInvalidSchema indicates that a Schema is not internally consistent.
+	ErrInvalidSchema = errors.New("invalid schema")
+	// ErrInputSchemaConflict indicates that both InputSchema and RawInputSchema were provided.
+	ErrInputSchemaConflict = errors.New("only one of InputSchema or RawInputSchema should be provided")
+)
+
+// Validate ensures that |t| is valid. It returns an error if the tool is missing a name or if its input schema is not
+// internally consistent (e.g. a required property that isn't defined). A RawInputSchema is only checked to be valid
+// JSON.
+func (t *Tool) Validate() error {
+	if t.Name == "" {
+		return ErrMissingToolName
+	}
+	if t.RawInputSchema != nil {
+		if t.InputSchema != nil {
+			return fmt.Errorf("%w: %s", ErrInputSchemaConflict, t.Name)
+		}
+		if !json.Valid(t.RawInputSchema) {
+			return fmt.Errorf("%w: tool %s: raw input schema is not valid JSON", ErrInvalidSchema, t.Name)
+		}
+		return nil
+	}
+	if t.InputSchema == nil {
+		return fmt.Errorf("%w: %s", ErrMissingInputSchema, t.Name)
+	}
+	if t.InputSchema.Type != SchemaTypeObject {
+		return fmt.Errorf("%w: tool %s: input schema must be of type object", ErrInvalidSchema, t.Name)
+	}
+	if err := t.InputSchema.validate("input_schema"); err != nil {
+		return fmt.Errorf("tool %s: %w", t.Name, err)
+	}
+
+	return nil
 }
 
 // Schema represents a basic JSON schema.
@@ -18,6 +90,41 @@ type Schema struct {
 	Required    []string           `json:"required,omitempty"`
 }
 
+// validate recursively checks that |s| is internally consistent. |path| is used to identify the offending schema in
+// the returned error.
+func (s *Schema) validate(path string) error {
+	switch s.Type {
+	case SchemaTypeObject:
+		if s.Properties == nil {
+			return fmt.Errorf("%w: %s: object schema must define properties", ErrInvalidSchema, path)
+		}
+	case SchemaTypeArray:
+		if s.Items == nil {
+			return fmt.Errorf("%w: %s: array schema must define items", ErrInvalidSchema, path)
+		}
+		if err := s.Items.validate(path + ".items"); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range s.Required {
+		if _, ok := s.Properties[name]; !ok {
+			return fmt.Errorf("%w: %s: required property %q is not defined in properties", ErrInvalidSchema, path, name)
+		}
+	}
+
+	for name, prop := range s.Properties {
+		if prop == nil {
+			return fmt.Errorf("%w: %s.properties.%s: schema cannot be nil", ErrInvalidSchema, path, name)
+		}
+		if err := prop.validate(path + ".properties." + name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ToolChoice represents how the model should use the provided tools. The model can use a specific tool, any available
 // tool, or decide by itself.
 type ToolChoice struct {
@@ -25,6 +132,11 @@ type ToolChoice struct {
 	Type string `json:"type"`
 	// Name is the name of the tool to use. Required if Type is "tool".
 	Name string `json:"name,omitempty"`
+	// DisableParallelToolUse, if true, limits Claude to at most one tool use per response (exactly one if Type is
+	// "any" or "tool"). The API has no parameter capping the total number of tool calls across a conversation; to
+	// bound that, limit the number of turns (e.g. the maxTurns argument of anthropic.RunToolLoop).
+	// Optional.
+	DisableParallelToolUse bool `json:"disable_parallel_tool_use,omitempty"`
 }
 
 // ToolChoiceType represents the type of tool choice.
@@ -136,3 +248,103 @@ var stringToSchemaType = map[string]SchemaType{
 	"array":   SchemaTypeArray,
 	"boolean": SchemaTypeBoolean,
 }
+
+// Clone returns a deep copy of |t|.
+func (t *Tool) Clone() *Tool {
+	if t == nil {
+		return nil
+	}
+
+	var out = *t
+	out.InputSchema = t.InputSchema.Clone()
+	if t.RawInputSchema != nil {
+		out.RawInputSchema = append(json.RawMessage(nil), t.RawInputSchema...)
+	}
+
+	return &out
+}
+
+// Clone returns a deep copy of |s|. Enum values are copied shallowly.
+func (s *Schema) Clone() *Schema {
+	if s == nil {
+		return nil
+	}
+
+	var out = *s
+	if s.Properties != nil {
+		out.Properties = make(map[string]*Schema, len(s.Properties))
+		for k, v := range s.Properties {
+			out.Properties[k] = v.Clone()
+		}
+	}
+	out.Items = s.Items.Clone()
+	if s.Enum != nil {
+		out.Enum = append([]interface{}(nil), s.Enum...)
+	}
+	if s.Required != nil {
+		out.Required = append([]string(nil), s.Required...)
+	}
+
+	return &out
+}
+
+var (
+	// ErrUnmatchedToolResult indicates that a "tool_result" block references a tool_use id that doesn't appear in the
+	// immediately preceding assistant message (or that was already answered).
+	ErrUnmatchedToolResult = errors.New("tool_result does not match a tool_use in the preceding assistant message")
+	// ErrMissingToolResult indicates that a "tool_use" block was not answered by a "tool_result" block in the
+	// following user message.
+	ErrMissingToolResult = errors.New("tool_use is missing a tool_result in the following user message")
+)
+
+// ValidateToolPairing ensures that the tool usage in |msgs| is well-formed: every "tool_result" block must reference
+// an unanswered "tool_use" block from the immediately preceding assistant message, and every "tool_use" block must be
+// answered by the following user message.
+func ValidateToolPairing(msgs []*Message) error {
+	// pending holds the ids of the tool_use blocks that haven't yet been answered.
+	var pending = make(map[string]bool)
+	for i, m := range msgs {
+		if m.Role == RoleAssistant {
+			if len(pending) > 0 {
+				return fmt.Errorf("%w: message %d: %s", ErrMissingToolResult, i-1, firstKey(pending))
+			}
+			for _, c := range m.Content {
+				if c.Type == "tool_use" {
+					pending[c.ID] = true
+				}
+			}
+			continue
+		}
+
+		for _, c := range m.Content {
+			if c.Type != "tool_result" {
+				continue
+			}
+			if !pending[c.ToolUseID] {
+				return fmt.Errorf("%w: message %d: %s", ErrUnmatchedToolResult, i, c.ToolUseID)
+			}
+			delete(pending, c.ToolUseID)
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%w: message %d: %s", ErrMissingToolResult, i, firstKey(pending))
+		}
+	}
+
+	if len(pending) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingToolResult, firstKey(pending))
+	}
+
+	return nil
+}
+
+// firstKey returns the lexically smallest key in |m|, so errors are deterministic.
+func firstKey(m map[string]bool) string {
+	var out string
+	for k := range m {
+		if out == "" || k < out {
+			out = k
+		}
+	}
+
+	return out
+}
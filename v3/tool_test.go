@@ -0,0 +1,214 @@
+package v3
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestToolValidate(t *testing.T) {
+	var tests = []struct {
+		name string
+		tool *Tool
+		err  error
+	}{
+		{
+			name: "Missing Name",
+			tool: &Tool{InputSchema: &Schema{Type: SchemaTypeObject, Properties: map[string]*Schema{}}},
+			err:  ErrMissingToolName,
+		},
+		{
+			name: "Missing Input Schema",
+			tool: &Tool{Name: "get_weather"},
+			err:  ErrMissingInputSchema,
+		},
+		{
+			name: "Non-Object Input Schema",
+			tool: &Tool{Name: "get_weather", InputSchema: &Schema{Type: SchemaTypeString}},
+			err:  ErrInvalidSchema,
+		},
+		{
+			name: "Object Without Properties",
+			tool: &Tool{Name: "get_weather", InputSchema: &Schema{Type: SchemaTypeObject}},
+			err:  ErrInvalidSchema,
+		},
+		{
+			name: "Required Property Not Defined",
+			tool: &Tool{Name: "get_weather", InputSchema: &Schema{
+				Type:       SchemaTypeObject,
+				Properties: map[string]*Schema{"location": {Type: SchemaTypeString}},
+				Required:   []string{"unit"},
+			}},
+			err: ErrInvalidSchema,
+		},
+		{
+			name: "Array Without Items",
+			tool: &Tool{Name: "get_weather", InputSchema: &Schema{
+				Type:       SchemaTypeObject,
+				Properties: map[string]*Schema{"days": {Type: SchemaTypeArray}},
+			}},
+			err: ErrInvalidSchema,
+		},
+		{
+			name: "Valid Tool",
+			tool: &Tool{Name: "get_weather", InputSchema: &Schema{
+				Type: SchemaTypeObject,
+				Properties: map[string]*Schema{
+					"location": {Type: SchemaTypeString},
+					"days":     {Type: SchemaTypeArray, Items: &Schema{Type: SchemaTypeInteger}},
+				},
+				Required: []string{"location"},
+			}},
+			err: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.tool.Validate(); !errors.Is(err, tt.err) {
+				t.Errorf("Tool.Validate() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestToolMarshalRawInputSchema(t *testing.T) {
+	var tool = &Tool{
+		Name:           "lookup",
+		Description:    "Look up a record.",
+		RawInputSchema: json.RawMessage(`{"type":"object","$defs":{"id":{"type":"string"}},"properties":{"id":{"$ref":"#/$defs/id"}}}`),
+	}
+
+	var b, err = json.Marshal(tool)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var exp = `{"name":"lookup","description":"Look up a record.","input_schema":{"type":"object","$defs":{"id":{"type":"string"}},"properties":{"id":{"$ref":"#/$defs/id"}}}}`
+	if string(b) != exp {
+		t.Errorf("json.Marshal() = %s, want %s", b, exp)
+	}
+
+	tool.InputSchema = &Schema{Type: SchemaTypeObject, Properties: map[string]*Schema{}}
+	if _, err = json.Marshal(tool); !errors.Is(err, ErrInputSchemaConflict) {
+		t.Errorf("json.Marshal() error = %v, wantErr %v", err, ErrInputSchemaConflict)
+	}
+}
+
+func TestValidateToolPairing(t *testing.T) {
+	var use = func(id string) *MessageContent { return &MessageContent{Type: "tool_use", ID: id, Name: "get_weather"} }
+	var result = func(id string) *MessageContent { return &MessageContent{Type: "tool_result", ToolUseID: id} }
+	var text = &MessageContent{Type: "text", Text: "Hi"}
+
+	var tests = []struct {
+		name string
+		msgs []*Message
+		err  error
+	}{
+		{
+			name: "Valid Parallel Tools",
+			msgs: []*Message{
+				{Role: RoleUser, Content: []*MessageContent{text}},
+				{Role: RoleAssistant, Content: []*MessageContent{text, use("a"), use("b")}},
+				{Role: RoleUser, Content: []*MessageContent{result("b"), result("a"), text}},
+			},
+			err: nil,
+		},
+		{
+			name: "Unknown ID",
+			msgs: []*Message{
+				{Role: RoleAssistant, Content: []*MessageContent{use("a")}},
+				{Role: RoleUser, Content: []*MessageContent{result("a"), result("c")}},
+			},
+			err: ErrUnmatchedToolResult,
+		},
+		{
+			name: "Result From Earlier Turn",
+			msgs: []*Message{
+				{Role: RoleAssistant, Content: []*MessageContent{use("a")}},
+				{Role: RoleUser, Content: []*MessageContent{result("a")}},
+				{Role: RoleAssistant, Content: []*MessageContent{text}},
+				{Role: RoleUser, Content: []*MessageContent{result("a")}},
+			},
+			err: ErrUnmatchedToolResult,
+		},
+		{
+			name: "Missing Result",
+			msgs: []*Message{
+				{Role: RoleAssistant, Content: []*MessageContent{use("a"), use("b")}},
+				{Role: RoleUser, Content: []*MessageContent{result("a")}},
+			},
+			err: ErrMissingToolResult,
+		},
+		{
+			name: "Unanswered Final Tool Use",
+			msgs: []*Message{
+				{Role: RoleUser, Content: []*MessageContent{text}},
+				{Role: RoleAssistant, Content: []*MessageContent{use("a")}},
+			},
+			err: ErrMissingToolResult,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateToolPairing(tt.msgs); !errors.Is(err, tt.err) {
+				t.Errorf("ValidateToolPairing() error = %v, wantErr %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestToolChoiceDisableParallelToolUse(t *testing.T) {
+	var b, err = json.Marshal(&ToolChoice{Type: "any", DisableParallelToolUse: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `{"type":"any","disable_parallel_tool_use":true}`
+	if string(b) != exp {
+		t.Errorf("json.Marshal() = %s, want %s", b, exp)
+	}
+}
+
+func TestSchemaFor(t *testing.T) {
+	type Location struct {
+		City    string `json:"city" description:"The city name."`
+		Country string `json:"country,omitempty"`
+	}
+	type Input struct {
+		Location
+		Unit    string   `json:"unit" enum:"celsius,fahrenheit"`
+		Days    *int     `json:"days"`
+		Tags    []string `json:"tags,omitempty"`
+		Ignored string   `json:"-"`
+		private string
+	}
+
+	var s, err = SchemaFor(Input{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b []byte
+	if b, err = json.Marshal(s); err != nil {
+		t.Fatal(err)
+	}
+
+	const exp = `{"type":"object","properties":{"city":{"type":"string","description":"The city name."},"country":{"type":"string"},"days":{"type":"integer"},"tags":{"type":"array","items":{"type":"string"}},"unit":{"type":"string","enum":["celsius","fahrenheit"]}},"required":["city","unit"]}`
+	if string(b) != exp {
+		t.Errorf("SchemaFor() = %s, want %s", b, exp)
+	}
+
+	var tool = &Tool{Name: "weather", InputSchema: s}
+	if err = tool.Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+
+	type Node struct {
+		Children []*Node `json:"children"`
+	}
+	if _, err = SchemaFor(Node{}); !errors.Is(err, ErrUnsupportedSchemaType) {
+		t.Errorf("SchemaFor() error = %v, wantErr %v", err, ErrUnsupportedSchemaType)
+	}
+}
@@ -0,0 +1,38 @@
+package anthropic
+
+import (
+	"context"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+// AskAboutImage asks |model| |question| about the image at |imagePath| and returns the text of its answer. The image
+// is sent before the question, as recommended for vision prompts, and MaxTokens is set to 4096 (or the model's maximum
+// output tokens, if that's lower). For more control (e.g. several images, a system prompt, or the usage), build the
+// message with v3.NewUserMessageWith and use NewMessageRequest.
+//
+// An error wrapping v3.ErrUnsupportedMediaType is returned if the file isn't a JPEG, PNG, GIF, or WebP image; an
+// error wrapping v3.ErrImageTooLarge if it's larger than v3.MaxImageBytes. API errors are returned as-is (see
+// ResponseError).
+func (c *Client) AskAboutImage(ctx context.Context, model v3.Model, imagePath, question string) (string, error) {
+	var msg, err = v3.NewUserMessageWith(v3.ImageFile(imagePath), v3.Text(question))
+	if err != nil {
+		return "", err
+	}
+
+	var req = &v3.Request[v3.Message]{
+		Model:     model,
+		MaxTokens: defaultMaxTokensFor(model, c.betas()),
+		Messages:  []*v3.Message{msg},
+	}
+	if err = req.Validate(); err != nil {
+		return "", err
+	}
+
+	var resp *v3.Response
+	if resp, err = c.NewMessageRequest(ctx, req); err != nil {
+		return "", err
+	}
+
+	return resp.Text(), nil
+}
@@ -0,0 +1,71 @@
+package anthropic
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v3 "github.com/fabiustech/anthropic/v3"
+)
+
+func TestAskAboutImage(t *testing.T) {
+	var dir = t.TempDir()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+	var imagePath = filepath.Join(dir, "image.png")
+	if err := os.WriteFile(imagePath, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	var textPath = filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(textPath, []byte("not an image"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var c = NewClient("key", WithHTTPClient(&http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		var req v3.Request[v3.Message]
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			return nil, err
+		}
+		var content = req.Messages[0].Content
+		if len(content) != 2 || content[0].Type != "image" || content[0].Source.MediaType != "image/png" || content[1].Text != "What is this?" {
+			t.Errorf("content = %+v, want the image followed by the question", content)
+		}
+		if req.MaxTokens != defaultMaxTokens {
+			t.Errorf("max_tokens = %d, want %d", req.MaxTokens, defaultMaxTokens)
+		}
+
+		return newTestResponse(http.StatusOK, `{"id":"msg","type":"message","role":"assistant","content":[{"type":"text","text":"A black square."}]}`), nil
+	})}))
+
+	var tests = []struct {
+		name    string
+		path    string
+		want    string
+		wantErr error
+	}{
+		{name: "Image", path: imagePath, want: "A black square."},
+		{name: "Unsupported", path: textPath, wantErr: v3.ErrUnsupportedMediaType},
+		{name: "Missing", path: filepath.Join(dir, "missing.png"), wantErr: os.ErrNotExist},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got, err = c.AskAboutImage(context.Background(), v3.Claude3Dot5Sonnet20241022, tt.path, "What is this?")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AskAboutImage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("AskAboutImage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}